@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"log/slog"
+	"math"
+)
+
+// Minimum number of ticks between two adjustments of the adaptive mode, for the recent load to reflect the last one.
+const adaptInterval = 5
+
+// adaptation holds the adaptive mode thresholds and the current stretch of the periods.
+type adaptation struct {
+	high    float64 // recent load above which the periods are stretched, 0 if disabled
+	low     float64 // recent load below which the periods are tightened again
+	max     float64 // maximum stretch factor
+	stretch float64 // current stretch factor, 0 or 1 if none
+	last    int     // tick of the last adjustment
+}
+
+// WithAdaptive stretches the periods of the tasks automatically when the recent load, as used by degraded mode,
+// exceeds high, doubling them up to maxFactor times their original period, and tightens them again, halving the
+// stretch, when it falls below low, to keep the scheduler below saturation. The periods are adjusted at most every
+// few ticks, so that the recent load reflects the last adjustment. Express tasks keep their period. Stretching
+// throttles the tasks, as Throttle would, overriding their manual throttling. A 0 or negative high disables the
+// adaptive mode, which is the default.
+func WithAdaptive(high, low, maxFactor float64) Option {
+	return func(s *scheduler) {
+		s.adapt.high, s.adapt.low, s.adapt.max = max(high, 0), min(low, high), max(maxFactor, 1)
+	}
+}
+
+// Get the current stretch factor of the periods by the adaptive mode, 1 if none.
+func (s *scheduler) Stretch() float64 {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	return max(s.adapt.stretch, 1)
+}
+
+// Adjust the stretch of the periods to the recent load, after a tick.
+func (s *scheduler) adjust() {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	a := &s.adapt
+	if a.high <= 0 || s.ticks-a.last < adaptInterval {
+		return
+	}
+	current := max(a.stretch, 1)
+	switch recent := s.degrade.recent; {
+	case recent >= a.high && current < a.max:
+		s.stretch(min(current*2, a.max))
+	case recent <= a.low && current > 1:
+		s.stretch(max(current/2, 1))
+	}
+}
+
+// unsafe change of the stretch of the periods of the tasks that are not express.
+func (s *scheduler) stretch(factor float64) {
+	var ee []*entry
+	for _, v := range s.tasks {
+		for _, e := range v {
+			if !e.express {
+				ee = append(ee, e)
+			}
+		}
+	}
+	for _, e := range ee {
+		if e.base == 0 {
+			e.base = e.period
+		}
+		s.move(e, max(1, int(math.Round(float64(e.base)*factor))))
+		if factor == 1 {
+			e.base = 0
+		}
+	}
+	s.adapt.stretch, s.adapt.last = factor, s.ticks
+	s.log(slog.LevelInfo, "periods stretched", "factor", factor)
+}
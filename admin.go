@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// Status is a snapshot of the scheduler state, served by the admin handlers.
+type Status struct {
+	Name        string       `json:"name,omitempty"` // name of the scheduler, see WithName
+	Running     bool         `json:"running"`        // scheduler started and not stopped
+	Paused      bool         `json:"paused"`         // ticks suspended, see Pause
+	Maintenance bool         `json:"maintenance"`    // maintenance mode, see EnterMaintenance
+	Degraded    bool         `json:"degraded"`       // degraded mode, see WithDegradation
+	Stats       Stats        `json:"stats"`          // scheduler statistics
+	Tasks       []TaskStatus `json:"tasks"`          // scheduled tasks, by increasing period
+}
+
+// TaskStatus describes a scheduled task in a Status.
+type TaskStatus struct {
+	ID       TaskID   `json:"id"`               // identity of the registration
+	Name     string   `json:"name,omitempty"`   // name of the task, if any
+	Task     string   `json:"task"`             // printed value of the task
+	Period   int      `json:"period"`           // period in ticks, 0 for one-shot and cron tasks
+	Tenant   string   `json:"tenant,omitempty"` // tenant of the task, if any
+	Tags     []string `json:"tags,omitempty"`   // tags of the task, if any
+	Paused   bool     `json:"paused"`           // runs suspended, see TaskHandle.Pause
+	Failures int      `json:"failures"`         // number of consecutive failed runs
+
+	Offset  *Offset            `json:"offset,omitempty"`  // effective offset of a periodic task
+	Trace   *TraceSnapshot     `json:"trace,omitempty"`   // run statistics of a traced task, see WithTracing
+	Metrics map[string]float64 `json:"metrics,omitempty"` // last values recorded with TaskEnv.Record
+}
+
+// Get a snapshot of the scheduler state and of its tasks.
+func (s *scheduler) Status() Status {
+	st := Status{
+		Name:    s.name,
+		Running: s.Lifetime().Running,
+		Paused:  s.Paused(),
+		Stats:   s.Stats(),
+		Tasks:   []TaskStatus{},
+	}
+
+	s.locktasks.Lock()
+	st.Maintenance, st.Degraded = s.maintenance, s.degraded
+	s.each(func(e *entry) {
+		var trace *TraceSnapshot
+		if tr := e.tracer(); tr != nil {
+			sn := tr.Snapshot()
+			trace = &sn
+		}
+		var offset *Offset
+		if i := s.index(e); i >= 0 {
+			o := s.offset(e, i)
+			offset = &o
+		}
+		st.Tasks = append(st.Tasks, TaskStatus{
+			ID:       e.id,
+			Name:     e.name,
+			Task:     fmt.Sprint(e.task),
+			Period:   e.period,
+			Tenant:   e.tenant,
+			Tags:     slices.Clone(e.tags),
+			Paused:   e.paused,
+			Failures: e.failures,
+			Offset:   offset,
+			Trace:    trace,
+			Metrics:  e.metrics.snapshot(),
+		})
+	})
+	s.locktasks.Unlock()
+
+	sort.SliceStable(st.Tasks, func(i, j int) bool { return st.Tasks[i].Period < st.Tasks[j].Period })
+	return st
+}
+
+// Handler serves the admin surface of s : GET requests get its Status as JSON, see InspectHandler, and POST
+// requests pause, resume, trigger or remove tasks, see ControlHandler.
+// Operations are not authenticated : expose it on an internal listener, or wrap its handlers with an AdminAuth.
+func Handler(s Scheduler) http.Handler {
+	return AdminHandler(s, nil)
+}
+
+// AdminHandler is Handler, with the POST operations authorized by auth. A nil auth allows all of them.
+func AdminHandler(s Scheduler, auth *AdminAuth) http.Handler {
+	inspect, control := InspectHandler(s), ControlHandler(s, auth)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			control.ServeHTTP(w, r)
+		} else {
+			inspect.ServeHTTP(w, r)
+		}
+	})
+}
+
+// InspectHandler serves the Status of s as JSON, read-only : it can be exposed broadly, along with metrics.
+// Wrap it with AdminAuth.Guard(OpStatus, ...) to restrict it.
+func InspectHandler(s Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeStatus(w, s)
+	})
+}
+
+// ControlHandler serves the operations changing s, as POST requests with the form values op and name :
+//
+//	op=pause, op=resume     pause or resume the task called name, or the ticks without name
+//	op=trigger              run the task called name at the next tick, out of its schedule
+//	op=remove               remove the task called name
+//
+// It replies with the Status of s after the operation. Each request is authorized for its operation by auth,
+// a nil auth allowing all of them : keep that handler internal, or guard it.
+func ControlHandler(s Scheduler, auth *AdminAuth) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		op, name := Operation(r.FormValue("op")), r.FormValue("name")
+		if auth != nil {
+			var ok bool
+			if r, ok = auth.check(w, r, op); !ok {
+				return
+			}
+		}
+		found := true
+		switch {
+		case op == OpPause && name == "":
+			s.Pause()
+		case op == OpResume && name == "":
+			s.Resume()
+		case op == OpPause:
+			found = s.PauseByName(name)
+		case op == OpResume:
+			found = s.ResumeByName(name)
+		case op == OpRemove:
+			found = s.RemoveByName(name)
+		case op == OpTrigger:
+			var t Task
+			if t, found = s.Lookup(name); found {
+				s.RunOnce(0, t)
+			}
+		default:
+			http.Error(w, fmt.Sprintf("unknown operation %q", op), http.StatusBadRequest)
+			return
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("%v : %q", ErrTaskNotFound, name), http.StatusNotFound)
+			return
+		}
+		writeStatus(w, s)
+	})
+}
+
+// Write the Status of s as JSON.
+func writeStatus(w http.ResponseWriter, s Scheduler) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Status())
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+)
+
+// Minimum number of traced runs before a task history is considered meaningful for anomaly detection.
+const anomalyMinRuns = 10
+
+// WithAnomalyDetection emits an EventAnomaly when the duration of a traced task run deviates from the average of
+// its previous runs by more than sigmas standard deviations. Only traced tasks are checked, once they have enough
+// history. A 0 or negative sigmas disables detection, which is the default.
+func WithAnomalyDetection(sigmas float64) Option {
+	return func(s *scheduler) {
+		s.anomaly.Store(math.Float64bits(max(sigmas, 0)))
+	}
+}
+
+// Prepare the anomaly check of the next run of e.
+// It returns nil if no check applies, or a function to call once the run is over.
+func (s *scheduler) anomalyDetector(e *entry) func() {
+	sigmas := math.Float64frombits(s.anomaly.Load())
+	if sigmas <= 0 {
+		return nil
+	}
+	tr := e.tracer()
+	if tr == nil || tr.Count() < anomalyMinRuns {
+		return nil
+	}
+	avg, dev := tr.AverageDuration(), tr.StandardDeviationDuration()
+	return func() {
+		d := tr.LastDuration()
+		if diff := math.Abs(float64(d - avg)); diff > sigmas*float64(dev) {
+			s.emit(Event{
+				Kind:     EventAnomaly,
+				Tick:     s.Ticks(),
+				Task:     e.task,
+				TaskID:   e.id,
+				Duration: d,
+				Err:      fmt.Errorf("run lasted %v, average is %v with a standard deviation of %v", d, avg, dev),
+			})
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrUnauthenticated is returned by an Authenticator that cannot identify the caller.
+var ErrUnauthenticated = errors.New("caller not authenticated")
+
+// Operation is an operation of the admin surface, checked by an Authorizer.
+type Operation string
+
+// Operations of the admin surface.
+const (
+	OpStatus  Operation = "status"  // read the status and statistics
+	OpPause   Operation = "pause"   // pause tasks or ticks
+	OpResume  Operation = "resume"  // resume tasks or ticks
+	OpTrigger Operation = "trigger" // run tasks out of schedule
+	OpRemove  Operation = "remove"  // remove tasks
+)
+
+// Authenticator identifies the caller of an admin request, returning its principal.
+type Authenticator func(r *http.Request) (principal string, err error)
+
+// Authorizer decides whether principal may perform op.
+type Authorizer func(principal string, op Operation) bool
+
+// AdminAuth guards the admin handlers. Requests are authenticated, then authorized for their operation.
+// A nil Authenticate rejects all requests, a nil Authorize allows any authenticated principal.
+type AdminAuth struct {
+	Authenticate Authenticator
+	Authorize    Authorizer
+}
+
+// key of the authenticated principal in the request context
+type principalKey struct{}
+
+// Guard wraps next, so that it only serves requests whose principal may perform op.
+// It replies 401 Unauthorized to unauthenticated requests, and 403 Forbidden to unauthorized ones.
+// next retrieves the principal with PrincipalFromContext(r.Context()).
+func (a AdminAuth) Guard(op Operation, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r, ok := a.check(w, r, op); ok {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Check that the principal of r may perform op, returning r with the principal in its context.
+// If it may not, the error reply is written to w and false is returned.
+func (a AdminAuth) check(w http.ResponseWriter, r *http.Request, op Operation) (*http.Request, bool) {
+	if a.Authenticate == nil {
+		http.Error(w, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+		return r, false
+	}
+	p, err := a.Authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return r, false
+	}
+	if a.Authorize != nil && !a.Authorize(p, op) {
+		http.Error(w, "operation "+string(op)+" not allowed", http.StatusForbidden)
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, p)), true
+}
+
+// PrincipalFromContext returns the principal authenticated by AdminAuth.Guard, if any.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(principalKey{}).(string)
+	return p, ok
+}
+
+// TokenAuth authenticates requests carrying an "Authorization: Bearer <token>" header, tokens mapping each
+// accepted token to its principal. Tokens are compared in constant time.
+func TokenAuth(tokens map[string]string) Authenticator {
+	return func(r *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return "", ErrUnauthenticated
+		}
+		principal, found := "", false
+		for t, p := range tokens { // compare with each token, not to leak which one matched
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				principal, found = p, true
+			}
+		}
+		if !found {
+			return "", ErrUnauthenticated
+		}
+		return principal, nil
+	}
+}
+
+// CertAuth authenticates requests made over mutual TLS, the principal being the common name of the verified
+// client certificate. The server must verify client certificates, see tls.Config.ClientAuth.
+func CertAuth() Authenticator {
+	return func(r *http.Request) (string, error) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return "", ErrUnauthenticated
+		}
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+	}
+}
+
+// AnyAuth authenticates requests with the first of authenticators that succeeds.
+func AnyAuth(authenticators ...Authenticator) Authenticator {
+	return func(r *http.Request) (string, error) {
+		for _, a := range authenticators {
+			if p, err := a(r); err == nil {
+				return p, nil
+			}
+		}
+		return "", ErrUnauthenticated
+	}
+}
+
+// AllowOps authorizes each principal of acl for the listed operations only.
+func AllowOps(acl map[string][]Operation) Authorizer {
+	return func(principal string, op Operation) bool {
+		for _, o := range acl[principal] {
+			if o == op {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"slices"
+	"time"
+)
+
+// Errors reporting a rejected Spec. They match ErrInvalid.
+var (
+	ErrInvalidPeriod = newError("period must be positive", ErrInvalid)
+	ErrNilTask       = newError("task is nil", ErrInvalid)
+)
+
+// Spec describes a periodic task and the options it is scheduled with.
+// Zero values select the defaults of Add.
+// Task and Policy are not serialized, tasks are identified by their name in JSON.
+type Spec struct {
+	Period      int           `json:"period"`                // run every Period ticks
+	Task        Task          `json:"-"`                     // task to run
+	Name        string        `json:"name,omitempty"`        // name of the task, if any
+	Tenant      string        `json:"tenant,omitempty"`      // tenant the task belongs to, if any
+	Timeout     time.Duration `json:"timeout,omitempty"`     // runtime budget of each run, 0 for no limit
+	Policy      ErrorPolicy   `json:"-"`                     // error policy, nil for the scheduler policy
+	Express     bool          `json:"express,omitempty"`     // keep running in degraded mode
+	Offset      int           `json:"offset,omitempty"`      // tick within the period the task runs at, if FixedOffset
+	FixedOffset bool          `json:"fixedOffset,omitempty"` // run at Offset instead of the implicit position within the period
+	Priority    int           `json:"priority,omitempty"`    // tasks due on the same tick run by decreasing priority
+	Exempt      bool          `json:"exempt,omitempty"`      // keep running in maintenance mode
+	Jitter      int           `json:"jitter,omitempty"`      // maximum random shift of the phase in ticks, drawn when added
+	RunKeys     bool          `json:"runKeys,omitempty"`     // record runs with idempotency keys in a RunStore, named tasks only
+	Commit      bool          `json:"commit,omitempty"`      // exactly-once mode, the task must be a CommitTask
+	Tags        []string      `json:"tags,omitempty"`        // tags the runs are accounted in, see TagStats
+	Key         string        `json:"key,omitempty"`         // concurrency key, tasks sharing it never run simultaneously
+}
+
+// Add the tasks described by specs, acquiring the task lock only once.
+// The returned slice has one error per spec, nil if the spec was scheduled.
+// A rejected spec does not prevent the following ones from being scheduled.
+func (s *scheduler) AddBatch(specs []Spec) []error {
+	errs := make([]error, len(specs))
+
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for i, sp := range specs {
+		_, errs[i] = s.addSpec(sp)
+	}
+	return errs
+}
+
+// unsafe add of a spec, checking it first.
+func (s *scheduler) addSpec(sp Spec) (*entry, error) {
+	switch {
+	case sp.Period <= 0:
+		return nil, ErrInvalidPeriod
+	case sp.Task == nil:
+		return nil, ErrNilTask
+	case s.named(sp.Name) != nil:
+		return nil, ErrDuplicateName
+	}
+	if _, ok := sp.Task.(CommitTask); sp.Commit && !ok {
+		return nil, ErrNotCommitTask
+	}
+	if sp.Tenant != "" {
+		if max := s.tenant(sp.Tenant).budget.MaxTasks; max > 0 && s.tenantTasks(sp.Tenant) >= max {
+			return nil, ErrTenantQuota
+		}
+	}
+	e := &entry{
+		task:     sp.Task,
+		name:     sp.Name,
+		tenant:   sp.Tenant,
+		timeout:  max(sp.Timeout, 0),
+		policy:   sp.Policy,
+		express:  sp.Express,
+		fixed:    sp.FixedOffset,
+		offset:   phase(sp.Offset, sp.Period),
+		priority: sp.Priority,
+		exempt:   sp.Exempt,
+		jitter:   max(sp.Jitter, 0),
+		keyed:    sp.RunKeys,
+		commit:   sp.Commit,
+		tags:     slices.Clone(sp.Tags),
+		key:      sp.Key,
+	}
+	s.addEntry(sp.Period, e)
+	return e, nil
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// The task runs normally.
+	BreakerClosed BreakerState = iota
+	// The task failed repeatedly, its runs are skipped until the cooldown is over.
+	BreakerOpen
+	// The cooldown is over, the next run probes the task : a success closes the breaker, a failure opens it again.
+	BreakerHalfOpen
+)
+
+func (b BreakerState) String() string {
+	switch b {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(b))
+	}
+}
+
+// Breaker is a task wrapping another one with a circuit breaker. See CircuitBreaker.
+type Breaker struct {
+	task      Task
+	threshold int
+	cooldown  int
+
+	lock     sync.Mutex
+	state    BreakerState
+	failures int // consecutive failures
+	opened   int // tick the breaker opened at
+	calls    int // number of runs, the clock outside of a scheduler
+}
+
+var _ TaskCtx = &Breaker{}
+
+// Return a task running t until it fails threshold times in a row. Its runs are then skipped, succeeding without
+// calling t, for cooldownTicks ticks, after which the next run probes t again. Ticks are read from the TaskEnv
+// of the run, outside of a scheduler each run counts as a tick.
+// Failures are still returned, pair the breaker with an error policy keeping the task, such as IgnoreErrors.
+func CircuitBreaker(t Task, threshold int, cooldownTicks int) *Breaker {
+	return &Breaker{task: t, threshold: max(threshold, 1), cooldown: max(cooldownTicks, 0)}
+}
+
+func (b *Breaker) Run() error {
+	return b.RunContext(context.Background())
+}
+
+func (b *Breaker) RunContext(ctx context.Context) error {
+	b.lock.Lock()
+	tick := b.calls
+	b.calls++
+	if env, ok := EnvFromContext(ctx); ok {
+		tick = env.Tick
+	}
+	if b.state == BreakerOpen {
+		if tick-b.opened < b.cooldown {
+			b.lock.Unlock()
+			return nil // skipped
+		}
+		b.state = BreakerHalfOpen
+	}
+	b.lock.Unlock()
+
+	err := runTask(ctx, b.task)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err == nil {
+		b.state, b.failures = BreakerClosed, 0
+		return nil
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.opened = BreakerOpen, tick
+	}
+	return err
+}
+
+// Get the state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+// Get the number of consecutive failures of the task.
+func (b *Breaker) Failures() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.failures
+}
+
+func (b *Breaker) String() string {
+	return fmt.Sprint(b.task)
+}
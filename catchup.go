@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// WithCatchUp runs, once, the periodic tasks that missed occurrences while no tick was processed : while the ticks
+// were paused, or when ticks were dropped because a tick overran or the process was suspended.
+// Each task runs a single catch-up run whatever the number of occurrences missed. At most maxRuns catch-up runs
+// are added to each tick, the others waiting for the next ticks. A 0 or negative maxRuns disables catch-up,
+// which is the default.
+// Missed ticks are measured with the monotonic clock : on systems where it stops during a suspend, the time
+// spent suspended is not caught up.
+func WithCatchUp(maxRuns int) Option {
+	return func(s *scheduler) {
+		s.catchup = max(maxRuns, 0)
+	}
+}
+
+// Number of catch-up runs waiting for a tick.
+func (s *scheduler) CatchUpBacklog() int {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	return len(s.backlog)
+}
+
+// Number of ticks missed between two ticks processed at last and now, ticking every duration.
+func missedTicks(last, now time.Time, duration time.Duration) int {
+	if last.IsZero() || duration <= 0 {
+		return 0
+	}
+	return max(int(now.Sub(last)/duration)-1, 0)
+}
+
+// Queue a catch-up run of the periodic entries having an occurrence during the ticks missed between a tick
+// processed at last and the tick now. Entries already queued are not queued again.
+func (s *scheduler) catchUp(last, now time.Time) {
+	s.lockstats.RLock()
+	missed, tick := missedTicks(last, now, s.duration), s.ticks
+	s.lockstats.RUnlock()
+	if missed == 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	if s.catchup == 0 {
+		return
+	}
+	var late []*entry
+	for _, p := range s.periods() {
+		for i, e := range s.tasks[p] {
+			if e.due(i, tick) < missed && s.runnable(e) && !slices.Contains(s.backlog, e) {
+				late = append(late, e)
+			}
+		}
+	}
+	byPriority(late)
+	s.backlog = append(s.backlog, late...)
+	if len(late) > 0 {
+		s.log(slog.LevelInfo, "catching up missed ticks", "tick", tick, "missed", missed, "tasks", len(late))
+	}
+}
+
+// unsafe addition to due of the next catch-up runs, up to the maximum per tick, not counting entries already due.
+func (s *scheduler) catchUpDue(due []*entry) []*entry {
+	n := 0
+	for n < len(s.backlog) && n < s.catchup {
+		if e := s.backlog[n]; !slices.Contains(due, e) {
+			due = append(due, e)
+		}
+		n++
+	}
+	s.backlog = s.backlog[n:]
+	return due
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a scheduler : the ticks, the durations it measures and the time of its events.
+// The default one is the system clock. A FakeClock advances only when told to, for deterministic tests.
+// The durations measured by tracers, and the timeouts of the tasks, always use the system clock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the ticks of a Clock on C, like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// Implemented by tickers that wait for a tick to be handled before delivering the next one.
+type tickAcker interface {
+	ticked()
+}
+
+// WithClock sets the clock of the scheduler, the system clock by default.
+func WithClock(c Clock) Option {
+	return func(s *scheduler) {
+		if c != nil {
+			s.clock = c
+		}
+	}
+}
+
+// Current time of the scheduler clock.
+func (s *scheduler) now() time.Time {
+	return s.clock.Now()
+}
+
+// Time elapsed on the scheduler clock since t.
+func (s *scheduler) since(t time.Time) time.Duration {
+	return s.clock.Now().Sub(t)
+}
+
+// The system clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock whose time only changes with Advance, safe for concurrent use.
+// Its tickers deliver every tick, without dropping any, each one once the scheduler handled the previous one.
+//
+//	clock := NewFakeClock(time.Now())
+//	s := New(WithClock(clock))
+//	s.Add(2, task)
+//	s.Start(time.Second)
+//	clock.Advance(4 * time.Second) // 4 ticks, task ran twice
+type FakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+var _ Clock = &FakeClock{}
+
+// Return a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// Return a ticker firing every d of virtual time. It panics if d is not positive, like time.NewTicker.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &fakeTicker{clock: c, c: make(chan time.Time), ack: make(chan struct{}), halt: make(chan struct{})}
+	t.period, t.next = d, c.now.Add(d)
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance the time by d, delivering the ticks due meanwhile in order.
+// For a scheduler, Advance returns once the ticks are handled : a tick that ran returns only after its tasks ended,
+// except when overrunning concurrently, where it returns once the tick started.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	end := c.now.Add(d)
+	for {
+		t := c.nextTicker(end)
+		if t == nil {
+			break
+		}
+		c.now, t.next = t.next, t.next.Add(t.period)
+		now, halt := c.now, t.halt
+		c.lock.Unlock()
+
+		select {
+		case t.c <- now:
+			select {
+			case <-t.ack:
+			case <-halt:
+			}
+		case <-halt: // stopped meanwhile
+		}
+		c.lock.Lock()
+	}
+	c.now = end
+	c.lock.Unlock()
+}
+
+// unsafe ticker firing first, no later than end, nil if none.
+func (c *FakeClock) nextTicker(end time.Time) *fakeTicker {
+	var first *fakeTicker
+	for _, t := range c.tickers {
+		if !t.next.After(end) && (first == nil || t.next.Before(first.next)) {
+			first = t
+		}
+	}
+	return first
+}
+
+// A ticker of a FakeClock.
+type fakeTicker struct {
+	clock   *FakeClock
+	c       chan time.Time
+	ack     chan struct{} // handled tick
+	halt    chan struct{} // closed on stop
+	period  time.Duration
+	next    time.Time // time of the next tick
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	if !t.stopped {
+		t.stopped = true
+		close(t.halt)
+		t.clock.tickers = slices.DeleteFunc(t.clock.tickers, func(tt *fakeTicker) bool { return tt == t })
+	}
+}
+
+// Reset the period of the ticker, the next tick fires d from now. A stopped ticker starts again.
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	t.period, t.next = d, t.clock.now.Add(d)
+	if t.stopped {
+		t.stopped, t.halt = false, make(chan struct{})
+		t.clock.tickers = append(t.clock.tickers, t)
+	}
+}
+
+func (t *fakeTicker) ticked() {
+	t.ack <- struct{}{}
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Errors of the exactly-once execution mode. ErrNotCommitted fails a run, ErrNotCommitTask rejects a task and
+// matches ErrInvalid.
+var (
+	ErrNotCommitted  = errors.New("run returned without commit")
+	ErrNotCommitTask = newError("task does not implement CommitTask", ErrInvalid)
+)
+
+// CommitTask is a task confirming its runs. A run is only done once the task calls commit, before returning :
+// typically right after the effect the run is about, such as a payment or a report, is durably recorded.
+type CommitTask interface {
+	Task
+	RunCommit(ctx context.Context, commit func()) error
+}
+
+// Add tasks sheduled to run every 'period' ticks in exactly-once mode : a run returning without calling commit
+// fails with ErrNotCommitted, and is retried according to policy, such as Retry or Backoff.
+// A nil policy uses the scheduler policy.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddExactlyOnce(period int, policy ErrorPolicy, t ...CommitTask) {
+	if period <= 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, policy: policy, commit: true})
+	}
+}
+
+// committer runs a CommitTask for a single run, failing the run if it is not committed.
+type committer struct {
+	task CommitTask
+	done atomic.Bool
+}
+
+// Return the task to run for a run of an entry : the task wrapped by the middleware if any, or else the task itself,
+// or a committer in exactly-once mode.
+func (e *entry) target() Task {
+	if e.run != nil {
+		return e.run
+	}
+	if ct, ok := e.task.(CommitTask); ok && e.commit {
+		return &committer{task: ct}
+	}
+	return e.task
+}
+
+func (c *committer) Run() error {
+	return c.RunContext(context.Background())
+}
+
+func (c *committer) RunContext(ctx context.Context) error {
+	err := c.task.RunCommit(ctx, func() { c.done.Store(true) })
+	if err == nil && !c.done.Load() {
+		return ErrNotCommitted
+	}
+	return err
+}
+
+// String is the printed value of the committed task, for logs and events.
+func (c *committer) String() string {
+	return fmt.Sprint(c.task)
+}
@@ -0,0 +1,1275 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Composite is a Scheduler made of several underlying schedulers, its parts, such as one per tick resolution or
+// per shard. Tasks are routed to a part when added, removals, settings and lifecycle fan out to all the parts, and
+// lookups and statistics gather the answers of the parts. Hooks, scopes and groups belong to the first part, the
+// primary, whose tick counter is the one of the composite : ticks of parts running at different resolutions do not
+// add up.
+type Composite struct {
+	parts     []Scheduler
+	durations []time.Duration // tick duration of each part, 0 for the duration given to Start
+	route     func(period int, t Task) int
+
+	lock  sync.Mutex // guards store, and the checks of the names and tenant budgets across the parts
+	store Store
+}
+
+var _ Scheduler = &Composite{}
+
+// Return a Composite of the parts, routing all the tasks to the first one until Route is set.
+// It panics without parts.
+func NewComposite(parts ...Scheduler) *Composite {
+	if len(parts) == 0 {
+		panic("a composite scheduler needs at least one part")
+	}
+	return &Composite{
+		parts:     append([]Scheduler(nil), parts...),
+		durations: make([]time.Duration, len(parts)),
+		route:     func(int, Task) int { return 0 },
+	}
+}
+
+// Set how tasks are routed : fn returns the index of the part a task of period is added to.
+// Tasks not run periodically, one-shot and cron tasks, are routed with a 0 period.
+// Out of range indexes route to the primary part.
+func (c *Composite) Route(fn func(period int, t Task) int) *Composite {
+	if fn != nil {
+		c.route = fn
+	}
+	return c
+}
+
+// Make part i tick every d, whatever the duration given to Start. 0 restores the duration given to Start.
+func (c *Composite) SetResolution(i int, d time.Duration) *Composite {
+	c.durations[i] = max(d, 0)
+	return c
+}
+
+// Get the parts, the primary first.
+func (c *Composite) Parts() []Scheduler {
+	return append([]Scheduler(nil), c.parts...)
+}
+
+// Index of the part a task of period is routed to.
+func (c *Composite) index(period int, t Task) int {
+	if i := c.route(period, t); i >= 0 && i < len(c.parts) {
+		return i
+	}
+	return 0
+}
+
+// Part a task of period is routed to.
+func (c *Composite) part(period int, t Task) Scheduler {
+	return c.parts[c.index(period, t)]
+}
+
+// Duration part i ticks at, when started with duration.
+func (c *Composite) resolution(i int, duration time.Duration) time.Duration {
+	if c.durations[i] > 0 {
+		return c.durations[i]
+	}
+	return duration
+}
+
+// Run fn on each part concurrently, and wait for all of them.
+func (c *Composite) each(fn func(i int, s Scheduler)) {
+	var wg sync.WaitGroup
+	for i, s := range c.parts {
+		wg.Add(1)
+		go func(i int, s Scheduler) {
+			defer wg.Done()
+			fn(i, s)
+		}(i, s)
+	}
+	wg.Wait()
+}
+
+// Check whether a part has a task named name.
+func (c *Composite) taken(name string) bool {
+	_, ok := c.Lookup(name)
+	return ok
+}
+
+// Check that the names of a plan are unique across the parts and within the plan, whatever the parts they are
+// routed to.
+func (c *Composite) unique(p SchedulePlan) error {
+	seen := map[string]bool{}
+	for i, sp := range p {
+		if sp.Name == "" {
+			continue
+		}
+		if seen[sp.Name] || c.taken(sp.Name) {
+			return fmt.Errorf("spec %d : %w", i, ErrDuplicateName)
+		}
+		seen[sp.Name] = true
+	}
+	return nil
+}
+
+// Add tasks to the part they are routed to.
+func (c *Composite) Add(period int, t ...Task) []*TaskHandle {
+	hs := make([]*TaskHandle, 0, len(t))
+	for _, tt := range t {
+		hs = append(hs, c.part(period, tt).Add(period, tt)...)
+	}
+	return hs
+}
+
+// Run a task once, delayTicks ticks of its part from now.
+func (c *Composite) RunOnce(delayTicks int, t Task) {
+	c.part(0, t).RunOnce(delayTicks, t)
+}
+
+// Run a task once, at the given tick of its part.
+func (c *Composite) RunAt(tick int, t Task) {
+	c.part(0, t).RunAt(tick, t)
+}
+
+// Run a task once, at the first tick of its part at or after t.
+func (c *Composite) ScheduleAt(t time.Time, task Task) error {
+	return c.part(0, task).ScheduleAt(t, task)
+}
+
+// Run a task once, at the first tick of its part at least d from now.
+func (c *Composite) ScheduleAfter(d time.Duration, task Task) error {
+	return c.part(0, task).ScheduleAfter(d, task)
+}
+
+// Add a cron task to the part it is routed to.
+func (c *Composite) AddCron(expr string, t Task) error {
+	return c.part(0, t).AddCron(expr, t)
+}
+
+// Add a cron task to the part it is routed to.
+func (c *Composite) AddSchedule(cs *CronSchedule, t Task) {
+	c.part(0, t).AddSchedule(cs, t)
+}
+
+// Get the next run time of a cron task, from the part it is scheduled in.
+func (c *Composite) NextCron(t Task) (time.Time, bool) {
+	for _, s := range c.parts {
+		if next, ok := s.NextCron(t); ok {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Add a chain of tasks to the part its first task is routed to.
+func (c *Composite) AddChain(period int, t ...Task) ([]*TaskHandle, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	return c.part(period, t[0]).AddChain(period, t...)
+}
+
+// Declare the dependencies of a task in the parts it is scheduled in. Dependencies apply within a part.
+func (c *Composite) DependsOn(t Task, on ...Task) error {
+	err, found := error(ErrUnknownTask), false
+	for _, s := range c.parts {
+		switch e := s.DependsOn(t, on...); {
+		case e == nil:
+			found = true
+		case !errors.Is(e, ErrTaskNotFound):
+			err = e
+		}
+	}
+	if found && errors.Is(err, ErrTaskNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Remove a task from all the parts.
+func (c *Composite) Remove(t Task) {
+	for _, s := range c.parts {
+		s.Remove(t)
+	}
+}
+
+// Remove the registration identified by id from the part it belongs to. IDs are unique across the parts.
+func (c *Composite) RemoveID(id TaskID) bool {
+	for _, s := range c.parts {
+		if s.RemoveID(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Change the period of a task in all the parts it is scheduled in. The task stays in its part.
+func (c *Composite) Reschedule(t Task, period int) bool {
+	found := false
+	for _, s := range c.parts {
+		found = s.Reschedule(t, period) || found
+	}
+	return found
+}
+
+// Wrap the tasks added from now on to any part with middleware.
+func (c *Composite) Use(mw ...Middleware) {
+	for _, s := range c.parts {
+		s.Use(mw...)
+	}
+}
+
+// Create a new composite with the same routing, resolutions and store, whose parts have the same tasks.
+func (c *Composite) New() Scheduler {
+	parts := make([]Scheduler, len(c.parts))
+	for i, s := range c.parts {
+		parts[i] = s.New()
+	}
+	cc := NewComposite(parts...)
+	cc.route = c.route
+	copy(cc.durations, c.durations)
+	cc.store = c.getStore()
+	return cc
+}
+
+// Start all the parts, each at its resolution or at duration.
+func (c *Composite) Start(duration time.Duration) {
+	for i, s := range c.parts {
+		s.Start(c.resolution(i, duration))
+	}
+}
+
+// Stop all the parts.
+func (c *Composite) Stop() {
+	c.each(func(_ int, s Scheduler) { s.Stop() })
+}
+
+// Stop all the parts, waiting for their running tasks until ctx is done. The errors of the parts are joined.
+func (c *Composite) StopContext(ctx context.Context) error {
+	errs := make([]error, len(c.parts))
+	c.each(func(i int, s Scheduler) { errs[i] = s.StopContext(ctx) })
+	return errors.Join(errs...)
+}
+
+// Stop all the parts, waiting for their running tasks up to d.
+func (c *Composite) StopWithTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	return c.StopContext(ctx)
+}
+
+// Run n ticks of each part in the calling goroutine, then stop them.
+func (c *Composite) RunFor(n int, duration time.Duration) {
+	c.each(func(i int, s Scheduler) { s.RunFor(n, c.resolution(i, duration)) })
+}
+
+// Run the parts until ctx is done, then stop them. It returns nil if a part was stopped first.
+func (c *Composite) RunUntil(ctx context.Context, duration time.Duration) error {
+	errs := make([]error, len(c.parts))
+	c.each(func(i int, s Scheduler) { errs[i] = s.RunUntil(ctx, c.resolution(i, duration)) })
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errs[0]
+}
+
+// Run the next tick of each part, in order.
+func (c *Composite) Step() {
+	for _, s := range c.parts {
+		s.Step()
+	}
+}
+
+// Reset the statistics of all the parts.
+func (c *Composite) ResetStats() {
+	for _, s := range c.parts {
+		s.ResetStats()
+	}
+}
+
+// Suspend the ticks of all the parts.
+func (c *Composite) Pause() {
+	for _, s := range c.parts {
+		s.Pause()
+	}
+}
+
+// Resume the ticks of all the parts.
+func (c *Composite) Resume() {
+	for _, s := range c.parts {
+		s.Resume()
+	}
+}
+
+// Check whether the ticks of all the parts are suspended.
+func (c *Composite) Paused() bool {
+	for _, s := range c.parts {
+		if !s.Paused() {
+			return false
+		}
+	}
+	return true
+}
+
+// Change the tick duration of the parts without a resolution of their own, even while running.
+func (c *Composite) SetDuration(d time.Duration) {
+	for i, s := range c.parts {
+		s.SetDuration(c.resolution(i, d))
+	}
+}
+
+// Aggregated statistics of the parts : runs, errors and overruns are summed, elapsed durations and the maximum tick
+// duration are the largest, and the load is the average of the loads weighted by the elapsed durations.
+// The ticks, the last tick and the hook durations are those of the primary part.
+func (c *Composite) Stats() Stats {
+	var st Stats
+	var busy float64
+	for i, s := range c.parts {
+		ps := s.Stats()
+		st.Runs += ps.Runs
+		st.Errors += ps.Errors
+		st.Overruns += ps.Overruns
+		st.Elapsed = max(st.Elapsed, ps.Elapsed)
+		st.ActualElapsed = max(st.ActualElapsed, ps.ActualElapsed)
+		st.MaxTick = max(st.MaxTick, ps.MaxTick)
+		if i == 0 {
+			st.Ticks, st.LastTick = ps.Ticks, ps.LastTick
+			st.BeforeHooks, st.AfterHooks = ps.BeforeHooks, ps.AfterHooks
+		}
+		busy += ps.Load * float64(ps.Elapsed)
+		st.Load += float64(ps.Elapsed) // total elapsed, divided below
+	}
+	if st.Load > 0 {
+		st.Load = busy / st.Load
+	}
+	return st
+}
+
+// Number of ticks of the primary part. The parts tick at their own resolution, see their Ticks.
+func (c *Composite) Ticks() int {
+	return c.parts[0].Ticks()
+}
+
+// Largest calculated elapsed duration of the parts.
+func (c *Composite) Elapsed() time.Duration {
+	var el time.Duration
+	for _, s := range c.parts {
+		el = max(el, s.Elapsed())
+	}
+	return el
+}
+
+// Largest actual elapsed duration of the parts.
+func (c *Composite) ActualElapsed() time.Duration {
+	var el time.Duration
+	for _, s := range c.parts {
+		el = max(el, s.ActualElapsed())
+	}
+	return el
+}
+
+// Lifetime counters of the parts : the starts and stop reason of the primary, the longest uptime, and running if
+// any part is.
+func (c *Composite) Lifetime() Lifetime {
+	var lt Lifetime
+	for i, s := range c.parts {
+		pl := s.Lifetime()
+		if i == 0 {
+			lt.Starts, lt.StopReason = pl.Starts, pl.StopReason
+		}
+		lt.Uptime = max(lt.Uptime, pl.Uptime)
+		lt.Running = lt.Running || pl.Running
+	}
+	return lt
+}
+
+// Load of the parts, see Stats.
+func (c *Composite) Load() float64 {
+	return c.Stats().Load
+}
+
+// Number of tasks currently scheduled in all the parts, periodic, one-shot and cron ones.
+func (c *Composite) Tasks() int {
+	n := 0
+	for _, s := range c.parts {
+		n += s.Tasks()
+	}
+	return n
+}
+
+// Overruns of all the parts.
+func (c *Composite) Overruns() int {
+	return c.Stats().Overruns
+}
+
+// The k slowest traced tasks of all the parts, by average duration.
+func (c *Composite) TopSlow(k int) []TaskStat {
+	var all []TaskStat
+	for _, s := range c.parts {
+		all = append(all, s.TopSlow(k)...)
+	}
+	return topSlow(all, k, byAverage)
+}
+
+// The k slowest traced tasks of all the parts, by 99th percentile duration.
+func (c *Composite) TopSlowP99(k int) []TaskStat {
+	var all []TaskStat
+	for _, s := range c.parts {
+		all = append(all, s.TopSlowP99(k)...)
+	}
+	return topSlow(all, k, byP99)
+}
+
+// Statistics of a traced task, from the first part tracing it.
+func (c *Composite) TaskStats(t Task) (TaskStat, bool) {
+	for _, s := range c.parts {
+		if st, ok := s.TaskStats(t); ok {
+			return st, true
+		}
+	}
+	return TaskStat{}, false
+}
+
+// Statistics of the traced tasks of all the parts.
+func (c *Composite) AllStats() []TaskStat {
+	var all []TaskStat
+	for _, s := range c.parts {
+		all = append(all, s.AllStats()...)
+	}
+	return all
+}
+
+// Declare the SLA of a task in all the parts it is scheduled in.
+func (c *Composite) SetSLA(t Task, sla SLA) bool {
+	found := false
+	for _, s := range c.parts {
+		found = s.SetSLA(t, sla) || found
+	}
+	return found
+}
+
+// Rolling SLA compliance of a task, from the first part tracking its SLA.
+func (c *Composite) SLACompliance(t Task) (float64, bool) {
+	for _, s := range c.parts {
+		if r, ok := s.SLACompliance(t); ok {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// Rolling success rate of a task, see Outcomes.
+func (c *Composite) SuccessRate(t Task) (float64, bool) {
+	o, ok := c.Outcomes(t)
+	return o.Rate, ok
+}
+
+// Outcomes of a task in all the parts it is scheduled in : the counts are summed, and the rate is the average of
+// the rates of the parts.
+func (c *Composite) Outcomes(t Task) (Outcomes, bool) {
+	var o Outcomes
+	nb := 0
+	for _, s := range c.parts {
+		if po, ok := s.Outcomes(t); ok {
+			o.Successes += po.Successes
+			o.Failures += po.Failures
+			o.Rate += po.Rate
+			nb++
+		}
+	}
+	if nb == 0 {
+		return Outcomes{}, false
+	}
+	o.Rate /= float64(nb)
+	return o, true
+}
+
+// Add a hook run at every tick of the primary part.
+func (c *Composite) AddBeforeHook(h Hook) *HookHandle {
+	return c.parts[0].AddBeforeHook(h)
+}
+
+// Add a hook run at every tick of the primary part.
+func (c *Composite) AddAfterHook(h Hook) *HookHandle {
+	return c.parts[0].AddAfterHook(h)
+}
+
+// Set the before hook of the primary part.
+// Deprecated: use AddBeforeHook.
+func (c *Composite) SetBefore(h Hook) {
+	c.parts[0].SetBefore(h)
+}
+
+// Set the after hook of the primary part.
+// Deprecated: use AddAfterHook.
+func (c *Composite) SetAfter(h Hook) {
+	c.parts[0].SetAfter(h)
+}
+
+// Number of hook calls dropped by the primary part.
+func (c *Composite) DroppedHooks() int {
+	return c.parts[0].DroppedHooks()
+}
+
+// Duration statistics of the hooks of the primary part.
+func (c *Composite) HookStats() (before, after TaskStat) {
+	return c.parts[0].HookStats()
+}
+
+// Register fn on all the parts.
+func (c *Composite) Subscribe(kinds EventKind, fn func(Event)) {
+	for _, s := range c.parts {
+		s.Subscribe(kinds, fn)
+	}
+}
+
+// Register an observer receiving the events of all the parts through a single queue.
+func (c *Composite) Observe(kinds EventKind, o Observer, queue int) *Observation {
+	obs := newObservation(o, queue)
+	for _, s := range c.parts {
+		obs.parts = append(obs.parts, s.Observe(kinds, ObserverFunc(obs.offer), queue))
+	}
+	return obs
+}
+
+// Write the events of all the parts to w, one per line.
+func (c *Composite) StreamEvents(w io.Writer, format Format) *Observation {
+	return c.Observe(EventAll, streamObserver(w, format), 0)
+}
+
+// Watch the changes of the schedule of all the parts until ctx is done. Changes are in order within each part.
+func (c *Composite) Watch(ctx context.Context) <-chan ScheduleChange {
+	ch := make(chan ScheduleChange)
+	var wg sync.WaitGroup
+	for _, s := range c.parts {
+		wg.Add(1)
+		go func(changes <-chan ScheduleChange) {
+			defer wg.Done()
+			for sc := range changes { // closed once ctx is done
+				select {
+				case ch <- sc:
+				case <-ctx.Done():
+				}
+			}
+		}(s.Watch(ctx))
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+// Register fn for the tasks removed by any part.
+func (c *Composite) OnTaskRemoved(fn func(t Task, reason error)) {
+	for _, s := range c.parts {
+		s.OnTaskRemoved(fn)
+	}
+}
+
+// Multiply the period of a task by factor in all the parts it is scheduled in.
+func (c *Composite) Throttle(t Task, factor float64) bool {
+	found := false
+	for _, s := range c.parts {
+		found = s.Throttle(t, factor) || found
+	}
+	return found
+}
+
+// Restore the original period of a task in all the parts it is throttled in.
+func (c *Composite) Unthrottle(t Task) bool {
+	found := false
+	for _, s := range c.parts {
+		found = s.Unthrottle(t) || found
+	}
+	return found
+}
+
+// Add a task with a priority to the part it is routed to.
+func (c *Composite) AddWithPriority(period, priority int, t Task) *TaskHandle {
+	return c.part(period, t).AddWithPriority(period, priority, t)
+}
+
+// Add a conditional task to the part it is routed to.
+func (c *Composite) AddIf(period int, cond func() bool, t Task) *TaskHandle {
+	return c.part(period, t).AddIf(period, cond, t)
+}
+
+// Add a task retiring after count runs to the part it is routed to.
+func (c *Composite) AddTimes(period, count int, t Task) *TaskHandle {
+	return c.part(period, t).AddTimes(period, count, t)
+}
+
+// Add a task removed after a wall-clock lifetime to the part it is routed to.
+func (c *Composite) AddWithTTL(period int, ttl time.Duration, t Task) *TaskHandle {
+	return c.part(period, t).AddWithTTL(period, ttl, t)
+}
+
+// Add a task at an offset within its period to the part it is routed to.
+func (c *Composite) AddWithOffset(period, offset int, t Task) *TaskHandle {
+	return c.part(period, t).AddWithOffset(period, offset, t)
+}
+
+// Add tasks with a timeout to the parts they are routed to.
+func (c *Composite) AddWithTimeout(period int, timeout time.Duration, t ...Task) {
+	for _, tt := range t {
+		c.part(period, tt).AddWithTimeout(period, timeout, tt)
+	}
+}
+
+// Add tasks with an error policy to the parts they are routed to.
+func (c *Composite) AddWithPolicy(period int, policy ErrorPolicy, t ...Task) {
+	for _, tt := range t {
+		c.part(period, tt).AddWithPolicy(period, policy, tt)
+	}
+}
+
+// Add exactly-once tasks to the parts they are routed to.
+func (c *Composite) AddExactlyOnce(period int, policy ErrorPolicy, t ...CommitTask) {
+	for _, tt := range t {
+		c.part(period, tt).AddExactlyOnce(period, policy, tt)
+	}
+}
+
+// Add express tasks to the parts they are routed to.
+func (c *Composite) AddExpress(period int, t ...Task) {
+	for _, tt := range t {
+		c.part(period, tt).AddExpress(period, tt)
+	}
+}
+
+// Add exempt tasks to the parts they are routed to.
+func (c *Composite) AddExempt(period int, t ...Task) {
+	for _, tt := range t {
+		c.part(period, tt).AddExempt(period, tt)
+	}
+}
+
+// Add tasks sharing a concurrency key to the parts they are routed to. Keys apply within a part.
+func (c *Composite) AddWithKey(period int, key string, t ...Task) {
+	for _, tt := range t {
+		c.part(period, tt).AddWithKey(period, key, tt)
+	}
+}
+
+// Add tagged tasks to the parts they are routed to.
+func (c *Composite) AddTagged(period int, tags []string, t ...Task) {
+	for _, tt := range t {
+		c.part(period, tt).AddTagged(period, tags, tt)
+	}
+}
+
+// Add owned tasks to the parts they are routed to.
+func (c *Composite) AddOwned(owner Owner, period int, t ...Task) []*TaskHandle {
+	hs := make([]*TaskHandle, 0, len(t))
+	for _, tt := range t {
+		hs = append(hs, c.part(period, tt).AddOwned(owner, period, tt)...)
+	}
+	return hs
+}
+
+// Add tasks of a tenant to the parts they are routed to, the budget of the primary part applying to the tasks of
+// the tenant in all the parts. If it does not allow all the tasks, none is added and ErrTenantQuota is returned.
+func (c *Composite) AddTenant(tenant string, period int, t ...Task) error {
+	if period <= 0 {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if st := c.TenantStats(tenant); st.Budget.MaxTasks > 0 && st.Tasks+len(t) > st.Budget.MaxTasks {
+		return ErrTenantQuota
+	}
+	for _, tt := range t {
+		if err := c.part(period, tt).AddTenant(tenant, period, tt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set the budget of a tenant in all the parts.
+func (c *Composite) SetTenantBudget(tenant string, b TenantBudget) {
+	for _, s := range c.parts {
+		s.SetTenantBudget(tenant, b)
+	}
+}
+
+// Statistics of a tenant, summed over the parts, with the budget of the primary part.
+// The load is the busy duration as a fraction of the largest elapsed duration of the parts.
+func (c *Composite) TenantStats(tenant string) TenantStats {
+	var st TenantStats
+	for i, s := range c.parts {
+		ps := s.TenantStats(tenant)
+		if i == 0 {
+			st.Budget = ps.Budget
+		}
+		st.Tasks += ps.Tasks
+		st.Busy += ps.Busy
+		st.Shed += ps.Shed
+	}
+	if el := c.Elapsed(); el > 0 {
+		st.Load = float64(st.Busy) / float64(el)
+	}
+	return st
+}
+
+// Statistics of a tag, summed over the parts.
+// The load is the busy duration as a fraction of the largest elapsed duration of the parts.
+func (c *Composite) TagStats(tag string) TagStats {
+	st := TagStats{Tag: tag}
+	for _, s := range c.parts {
+		ps := s.TagStats(tag)
+		st.Tasks += ps.Tasks
+		st.Runs += ps.Runs
+		st.Errors += ps.Errors
+		st.Busy += ps.Busy
+	}
+	if st.Runs > 0 {
+		st.ErrorRate = float64(st.Errors) / float64(st.Runs)
+	}
+	if el := c.Elapsed(); el > 0 {
+		st.Load = float64(st.Busy) / float64(el)
+	}
+	return st
+}
+
+// Statistics of all the tags of the parts, by decreasing busy duration.
+func (c *Composite) TagRollups() []TagStats {
+	tags := map[string]bool{}
+	for _, s := range c.parts {
+		for _, st := range s.TagRollups() {
+			tags[st.Tag] = true
+		}
+	}
+	stats := make([]TagStats, 0, len(tags))
+	for tag := range tags {
+		stats = append(stats, c.TagStats(tag))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Busy != stats[j].Busy {
+			return stats[i].Busy > stats[j].Busy
+		}
+		return stats[i].Tag < stats[j].Tag
+	})
+	return stats
+}
+
+// Add the tasks described by specs to the parts they are routed to, names being unique across the parts.
+func (c *Composite) AddBatch(specs []Spec) []error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	errs := make([]error, len(specs))
+	for i, sp := range specs {
+		if sp.Name != "" && c.taken(sp.Name) {
+			errs[i] = ErrDuplicateName
+			continue
+		}
+		errs[i] = c.part(sp.Period, sp.Task).AddBatch([]Spec{sp})[0]
+	}
+	return errs
+}
+
+// Add all the tasks of a plan to the parts they are routed to, or none of them. The returned error identifies
+// the part, and the spec within the specs routed to it.
+func (c *Composite) ApplyPlan(p SchedulePlan) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.unique(p); err != nil {
+		return err
+	}
+	plans := make([]SchedulePlan, len(c.parts))
+	for _, sp := range p {
+		i := c.index(sp.Period, sp.Task)
+		plans[i] = append(plans[i], sp)
+	}
+	for i, pp := range plans {
+		if len(pp) == 0 {
+			continue
+		}
+		if err := c.parts[i].ApplyPlan(pp); err != nil {
+			for j := range plans[:i] { // roll back
+				c.parts[j].ApplyDiff(PlanDiff{Removed: plans[j]})
+			}
+			return fmt.Errorf("part %d : %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Apply the changes of a diff to the parts the specs are routed to, a modified spec moving to another part if its
+// new period routes there. If a part rejects a spec, the parts already changed are reverted and the error
+// identifies the part.
+func (c *Composite) ApplyDiff(d PlanDiff) error {
+	diffs := make([]PlanDiff, len(c.parts))
+	for _, sp := range d.Removed {
+		i := c.index(sp.Period, sp.Task)
+		diffs[i].Removed = append(diffs[i].Removed, sp)
+	}
+	for _, sc := range d.Modified {
+		i, j := c.index(sc.Old.Period, sc.Old.Task), c.index(sc.New.Period, sc.New.Task)
+		if i == j {
+			diffs[i].Modified = append(diffs[i].Modified, sc)
+			continue
+		}
+		diffs[i].Removed = append(diffs[i].Removed, sc.Old)
+		diffs[j].Added = append(diffs[j].Added, sc.New)
+	}
+	for _, sp := range d.Added {
+		i := c.index(sp.Period, sp.Task)
+		diffs[i].Added = append(diffs[i].Added, sp)
+	}
+
+	for i, pd := range diffs {
+		if pd.Empty() {
+			continue
+		}
+		if err := c.parts[i].ApplyDiff(pd); err != nil {
+			for j := range diffs[:i] { // roll back
+				c.parts[j].ApplyDiff(diffs[j].inverse())
+			}
+			return fmt.Errorf("part %d : %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Add a task under a name unique across the parts, to the part it is routed to.
+func (c *Composite) AddNamed(name string, period int, t Task) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.taken(name) {
+		return ErrDuplicateName
+	}
+	return c.part(period, t).AddNamed(name, period, t)
+}
+
+// Names of the named tasks of all the parts, sorted.
+func (c *Composite) Names() []string {
+	var names []string
+	for _, s := range c.parts {
+		names = append(names, s.Names()...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Find a named task in the parts.
+func (c *Composite) Lookup(name string) (Task, bool) {
+	for _, s := range c.parts {
+		if t, ok := s.Lookup(name); ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Remove a named task from the part it belongs to.
+func (c *Composite) RemoveByName(name string) bool {
+	for _, s := range c.parts {
+		if s.RemoveByName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pause a named task in the part it belongs to.
+func (c *Composite) PauseByName(name string) bool {
+	for _, s := range c.parts {
+		if s.PauseByName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resume a named task in the part it belongs to.
+func (c *Composite) ResumeByName(name string) bool {
+	for _, s := range c.parts {
+		if s.ResumeByName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status of the composite : the name of the primary part, the aggregated statistics, and the tasks of all the
+// parts by increasing period. It is running or degraded if a part is, paused or in maintenance if all the parts are.
+func (c *Composite) Status() Status {
+	st := Status{Paused: true, Maintenance: true, Stats: c.Stats(), Tasks: []TaskStatus{}}
+	for i, s := range c.parts {
+		ps := s.Status()
+		if i == 0 {
+			st.Name = ps.Name
+		}
+		st.Running = st.Running || ps.Running
+		st.Paused = st.Paused && ps.Paused
+		st.Maintenance = st.Maintenance && ps.Maintenance
+		st.Degraded = st.Degraded || ps.Degraded
+		st.Tasks = append(st.Tasks, ps.Tasks...)
+	}
+	sort.SliceStable(st.Tasks, func(i, j int) bool { return st.Tasks[i].Period < st.Tasks[j].Period })
+	return st
+}
+
+// List the registrations of all the parts, by increasing period.
+func (c *Composite) List() []TaskInfo {
+	infos := []TaskInfo{}
+	for _, s := range c.parts {
+		infos = append(infos, s.List()...)
+	}
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Period < infos[j].Period })
+	return infos
+}
+
+// List the registrations that would run at tick of each part, the primary first. Each part counts its own ticks.
+func (c *Composite) Due(tick int) []TaskInfo {
+	infos := []TaskInfo{}
+	for _, s := range c.parts {
+		infos = append(infos, s.Due(tick)...)
+	}
+	return infos
+}
+
+// Get when a task runs next in the parts it is scheduled in : the earliest estimated time, or the first part
+// scheduling it if the times are unknown.
+func (c *Composite) NextRun(t Task) (TaskInfo, bool) {
+	var next TaskInfo
+	found := false
+	for _, s := range c.parts {
+		info, ok := s.NextRun(t)
+		if ok && (!found || !info.NextTime.IsZero() && (next.NextTime.IsZero() || info.NextTime.Before(next.NextTime))) {
+			next, found = info, true
+		}
+	}
+	return next, found
+}
+
+// Effective offsets of the registrations of a task in all the parts, the primary first.
+func (c *Composite) Offsets(t Task) []Offset {
+	var offsets []Offset
+	for _, s := range c.parts {
+		offsets = append(offsets, s.Offsets(t)...)
+	}
+	return offsets
+}
+
+// Largest stretch factor of the parts, each one adapting to its own load.
+func (c *Composite) Stretch() float64 {
+	stretch := 1.0
+	for _, s := range c.parts {
+		stretch = max(stretch, s.Stretch())
+	}
+	return stretch
+}
+
+// Number of catch-up runs waiting in all the parts.
+func (c *Composite) CatchUpBacklog() int {
+	n := 0
+	for _, s := range c.parts {
+		n += s.CatchUpBacklog()
+	}
+	return n
+}
+
+// Number of smoothed runs waiting in all the parts.
+func (c *Composite) SmoothingBacklog() int {
+	n := 0
+	for _, s := range c.parts {
+		n += s.SmoothingBacklog()
+	}
+	return n
+}
+
+// Time of the last heartbeat of the parts, zero if none ran yet.
+func (c *Composite) LastHeartbeat() time.Time {
+	var last time.Time
+	for _, s := range c.parts {
+		if t := s.LastHeartbeat(); t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// Duration since t on the clock of the primary part, for LivenessHandler.
+func (c *Composite) since(t time.Time) time.Duration {
+	if cl, ok := c.parts[0].(clocked); ok {
+		return cl.since(t)
+	}
+	return time.Since(t)
+}
+
+// Set the overrun policy of all the parts.
+func (c *Composite) SetOverrunPolicy(p OverrunPolicy) {
+	for _, s := range c.parts {
+		s.SetOverrunPolicy(p)
+	}
+}
+
+// Set a Hook executed after every overrunning tick of any part, called with the part.
+func (c *Composite) OnOverrun(h Hook) {
+	for _, s := range c.parts {
+		s.OnOverrun(h)
+	}
+}
+
+// Create a child scope of the primary part.
+func (c *Composite) Child() *Scope {
+	return c.parts[0].Child()
+}
+
+// Get a group of tasks of the primary part.
+func (c *Composite) Group(name string) *Group {
+	return c.parts[0].Group(name)
+}
+
+// Transfer the schedule of each part to the part of the same index of target, which must be a Composite with as
+// many parts. It returns ErrHandoffTarget otherwise. The errors of the parts are joined.
+func (c *Composite) Handoff(target Scheduler) error {
+	t, ok := target.(*Composite)
+	if !ok || len(t.parts) != len(c.parts) {
+		return ErrHandoffTarget
+	}
+	if t == c {
+		return nil
+	}
+	errs := make([]error, len(c.parts))
+	for i, s := range c.parts {
+		errs[i] = s.Handoff(t.parts[i])
+	}
+	return errors.Join(errs...)
+}
+
+// Get the schedule of all the parts, with the tick counter of the primary part.
+func (c *Composite) snapshot() (Snapshot, error) {
+	var snap Snapshot
+	for i, s := range c.parts {
+		var buf bytes.Buffer
+		var ps Snapshot
+		if err := s.SaveSchedule(&buf); err != nil {
+			return snap, err
+		}
+		if err := json.NewDecoder(&buf).Decode(&ps); err != nil {
+			return snap, err
+		}
+		if i == 0 {
+			snap.Tick = ps.Tick
+		}
+		snap.Specs = append(snap.Specs, ps.Specs...)
+		snap.States = append(snap.States, ps.States...)
+	}
+	sort.Slice(snap.Specs, func(i, j int) bool { return snap.Specs[i].Name < snap.Specs[j].Name })
+	sort.Slice(snap.States, func(i, j int) bool { return snap.States[i].Name < snap.States[j].Name })
+	return snap, nil
+}
+
+// Add the tasks of a schedule, bound by name to tasks, to the parts they are routed to, resuming their run states.
+// The tick counter of the schedule is resumed by the primary part only. All the tasks are added, or none of them.
+func (c *Composite) resume(snap Snapshot, tasks map[string]Task) error {
+	plan := append(SchedulePlan(nil), snap.Specs...)
+	if err := plan.Bind(tasks); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.unique(plan); err != nil {
+		return err
+	}
+	snaps, parts := make([]Snapshot, len(c.parts)), map[string]int{}
+	for _, sp := range plan {
+		i := c.index(sp.Period, sp.Task)
+		snaps[i].Specs = append(snaps[i].Specs, sp)
+		parts[sp.Name] = i
+	}
+	for _, ts := range snap.States {
+		if i, ok := parts[ts.Name]; ok {
+			snaps[i].States = append(snaps[i].States, ts)
+		}
+	}
+	snaps[0].Tick = snap.Tick
+
+	bound := map[string]Task{}
+	for _, sp := range plan {
+		bound[sp.Name] = sp.Task
+	}
+	for i, ps := range snaps {
+		if len(ps.Specs) == 0 && ps.Tick == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(ps); err != nil {
+			return err
+		}
+		if err := c.parts[i].LoadSchedule(&buf, TasksByName(bound)); err != nil {
+			for j := range snaps[:i] { // roll back
+				c.parts[j].ApplyDiff(PlanDiff{Removed: snaps[j].Specs})
+			}
+			return fmt.Errorf("part %d : %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Write the named tasks of all the parts and the tick counter of the primary part to w.
+func (c *Composite) SaveSchedule(w io.Writer) error {
+	snap, err := c.snapshot()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// Read a schedule written by SaveSchedule, adding its tasks to the parts they are routed to, all of them or none.
+// The tick counter is resumed by the primary part only, if it never ticked.
+func (c *Composite) LoadSchedule(r io.Reader, factory TaskFactory) error {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("unable to read the schedule : %w", err)
+	}
+	tasks := map[string]Task{}
+	for _, sp := range snap.Specs {
+		if t := factory(sp.Name); t != nil {
+			tasks[sp.Name] = t
+		}
+	}
+	return c.resume(snap, tasks)
+}
+
+// Set the store of the composite, used by Checkpoint and Restore, and of all the parts, receiving their dead
+// letters. nil removes it.
+func (c *Composite) SetStore(st Store) {
+	c.lock.Lock()
+	c.store = st
+	c.lock.Unlock()
+
+	for _, s := range c.parts {
+		s.SetStore(st)
+	}
+}
+
+// Get the store, nil if none.
+func (c *Composite) getStore() Store {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.store
+}
+
+// Save the named tasks of all the parts and their run state to the store.
+func (c *Composite) Checkpoint() error {
+	st := c.getStore()
+	if st == nil {
+		return ErrNoStore
+	}
+	snap, err := c.snapshot()
+	if err != nil {
+		return err
+	}
+	if err := st.SaveSpecs(snap.Specs); err != nil {
+		return err
+	}
+	return st.SaveStates(snap.States)
+}
+
+// Add the tasks saved in the store to the parts they are routed to, bound by name to tasks. All the tasks are
+// added, or none of them.
+func (c *Composite) Restore(tasks map[string]Task) error {
+	st := c.getStore()
+	if st == nil {
+		return ErrNoStore
+	}
+	specs, err := st.LoadSpecs()
+	if err != nil {
+		return err
+	}
+	states, err := st.LoadStates()
+	if err != nil {
+		return err
+	}
+	return c.resume(Snapshot{Specs: specs, States: states}, tasks)
+}
+
+// Set the logger of all the parts.
+func (c *Composite) SetLogger(l *slog.Logger) {
+	for _, s := range c.parts {
+		s.SetLogger(l)
+	}
+}
+
+// Set the span tracer of all the parts.
+func (c *Composite) SetSpanTracer(t SpanTracer) {
+	for _, s := range c.parts {
+		s.SetSpanTracer(t)
+	}
+}
+
+// Set the error policy of all the parts.
+func (c *Composite) SetErrorPolicy(policy ErrorPolicy) {
+	for _, s := range c.parts {
+		s.SetErrorPolicy(policy)
+	}
+}
+
+// Set the error handler of all the parts.
+func (c *Composite) SetErrorHandler(h func(t Task, err error)) {
+	for _, s := range c.parts {
+		s.SetErrorHandler(h)
+	}
+}
+
+// Set the eviction callback of all the parts.
+func (c *Composite) OnEvict(fn func(t Task, reason error) (keep bool, period int)) {
+	for _, s := range c.parts {
+		s.OnEvict(fn)
+	}
+}
+
+// Check whether a part is in degraded mode.
+func (c *Composite) Degraded() bool {
+	for _, s := range c.parts {
+		if s.Degraded() {
+			return true
+		}
+	}
+	return false
+}
+
+// Enter maintenance mode on all the parts.
+func (c *Composite) EnterMaintenance() {
+	for _, s := range c.parts {
+		s.EnterMaintenance()
+	}
+}
+
+// Leave maintenance mode on all the parts.
+func (c *Composite) ExitMaintenance() {
+	for _, s := range c.parts {
+		s.ExitMaintenance()
+	}
+}
+
+// Check whether all the parts are in maintenance mode.
+func (c *Composite) Maintenance() bool {
+	for _, s := range c.parts {
+		if !s.Maintenance() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import "errors"
+
+// ErrTaskRetired is the reason of the removal of a task added with AddTimes, once it ran its number of times.
+var ErrTaskRetired = errors.New("task retired")
+
+// Add a task sheduled to run every 'period' ticks, only when cond holds at the tick it is due, such as when a
+// feature flag is on, or during business hours. A due run whose condition does not hold is skipped, without error.
+// cond is called outside of the scheduler locks, by the tick running the task. A nil cond always holds.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddIf(period int, cond func() bool, t Task) *TaskHandle {
+	if period <= 0 {
+		return nil
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := &entry{task: t, cond: cond}
+	s.addEntry(period, e)
+	return &TaskHandle{s: s, e: e}
+}
+
+// Add a task sheduled to run every 'period' ticks, removed automatically after it ran count times, whether the runs
+// failed or not, such as a warm-up job, a migration, or a capped polling. It still obeys its error policy.
+// Negative or 0 period or count tasks are not scheduled.
+func (s *scheduler) AddTimes(period, count int, t Task) *TaskHandle {
+	if period <= 0 || count <= 0 {
+		return nil
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := &entry{task: t, left: count}
+	s.addEntry(period, e)
+	return &TaskHandle{s: s, e: e}
+}
@@ -0,0 +1,283 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCronSyntax is returned when a cron expression cannot be parsed. It matches ErrInvalid.
+var ErrCronSyntax = newError("invalid cron expression", ErrInvalid)
+
+// Wall-clock and monotonic elapsed times may differ by this much before a clock step is assumed.
+const clockStepTolerance = time.Second
+
+// CronSchedule is a parsed cron expression.
+type CronSchedule struct {
+	expr   string
+	minute bitset
+	hour   bitset
+	dom    bitset
+	month  bitset
+	dow    bitset
+	anyDom bool           // day of month is *
+	anyDow bool           // day of week is *
+	loc    *time.Location // time zone of the expression
+}
+
+// bitset holds the allowed values of a cron field.
+type bitset uint64
+
+func (b bitset) has(v int) bool { return b&(1<<uint(v)) != 0 }
+
+// cron field limits and names
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names of the values from min, if any
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard 5 fields cron expression : minute, hour, day of month, month and day of week.
+// Fields accept *, values, ranges (1-5), steps (*/15, 1-30/2), lists (1,15) and, for months and days
+// of week, three letters names (jan, mon). Days of week range from 0 (sunday) to 7 (sunday again).
+// As in most cron implementations, when both day fields are restricted, a day matching either of them is selected.
+// The descriptors @yearly, @annually, @monthly, @weekly, @daily, @midnight and @hourly are accepted.
+// The expression is evaluated in the local time zone, unless it is prefixed with CRON_TZ=<zone> or TZ=<zone>,
+// as in "CRON_TZ=Europe/Paris 0 9 * * mon-fri".
+func ParseCron(expr string) (*CronSchedule, error) {
+	c := &CronSchedule{expr: expr, loc: time.Local}
+	spec := strings.TrimSpace(expr)
+
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		tz, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(tz, "=")
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q : %v", ErrCronSyntax, expr, err)
+		}
+		c.loc = loc
+		spec = strings.TrimSpace(rest)
+	}
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%w %q : expected %d fields, got %d", ErrCronSyntax, expr, len(cronFields), len(fields))
+	}
+	sets := make([]bitset, len(fields))
+	for i, f := range fields {
+		b, err := cronFields[i].parse(strings.ToLower(f))
+		if err != nil {
+			return nil, fmt.Errorf("%w %q : %v", ErrCronSyntax, expr, err)
+		}
+		sets[i] = b
+	}
+	c.minute, c.hour, c.dom, c.month, c.dow = sets[0], sets[1], sets[2], sets[3], sets[4]
+	if c.dow.has(7) { // 7 is sunday too
+		c.dow |= 1
+	}
+	c.anyDom, c.anyDow = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+// parse a comma separated list of a field.
+func (f cronField) parse(s string) (bitset, error) {
+	var b bitset
+	for _, part := range strings.Split(s, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(first); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(last); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max // 5/10 means from 5 to max, every 10
+			}
+		}
+		if hi < lo {
+			return 0, fmt.Errorf("empty range %q for %s", part, f.name)
+		}
+		n := 1
+		if hasStep {
+			var err error
+			if n, err = strconv.Atoi(step); err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q for %s", part, f.name)
+			}
+		}
+		for v := lo; v <= hi; v += n {
+			b |= 1 << uint(v)
+		}
+	}
+	return b, nil
+}
+
+// parse a single value of a field, either a number or a name.
+func (f cronField) value(s string) (int, error) {
+	for i, n := range f.names {
+		if s == n {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q for %s, expected %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the cron expression.
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+// Location is the time zone the expression is evaluated in.
+func (c *CronSchedule) Location() *time.Location {
+	return c.loc
+}
+
+// Next returns the first activation time strictly after t, in the schedule time zone.
+// It returns the zero time if the expression never matches, such as for February 30th.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.In(c.loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, c.loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0) // leap years repeat within 5 years
+	for t.Before(limit) {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case !c.hour.has(t.Hour()):
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute) // absolute time, safe across DST changes
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// check the day fields.
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if c.anyDom || c.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// cronEntry is a task scheduled by a cron expression.
+type cronEntry struct {
+	*entry
+	sched *CronSchedule
+	next  time.Time // next activation time, wall-clock
+}
+
+// Add a task running according to a cron expression, see ParseCron for the syntax.
+// The expression is checked at every tick, so the tick duration bounds the precision of the runs.
+// If the scheduler falls behind, or the wall clock steps forward, missed occurrences are run once, not replayed.
+// When the wall clock steps backward, the next run keeps its monotonic delay, so that occurrences already run are
+// not run again whatever the step, nor the task silent for its duration. The runs after it follow the new time.
+func (s *scheduler) AddCron(expr string, t Task) error {
+	c, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	s.AddSchedule(c, t)
+	return nil
+}
+
+// Add a task running according to a parsed cron schedule. See AddCron.
+func (s *scheduler) AddSchedule(c *CronSchedule, t Task) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := &entry{task: t, outcomes: outcomes{recent: newRing(DefaultRateWindow)}}
+	s.register(e)
+	s.crons = append(s.crons, &cronEntry{entry: e, sched: c, next: c.Next(s.now())})
+}
+
+// Get the next run time of a cron scheduled task. It returns false if the task is not cron scheduled.
+func (s *scheduler) NextCron(t Task) (time.Time, bool) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, c := range s.crons {
+		if sameTask(c.task, t) {
+			return c.next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// unsafe list of the cron entries due at now, computing their next activation.
+func (s *scheduler) dueCron(now time.Time) []*entry {
+	if len(s.crons) == 0 {
+		s.lastCheck = now
+		return nil
+	}
+	if step := clockStep(s.lastCheck, now); step < -clockStepTolerance {
+		s.rederiveCron(step)
+	}
+	s.lastCheck = now
+
+	var due []*entry
+	for _, c := range s.crons {
+		if c.next.IsZero() || now.Before(c.next) {
+			continue
+		}
+		due = append(due, c.entry)
+		c.next = c.sched.Next(now) // missed occurrences are not replayed
+	}
+	return due
+}
+
+// unsafe re-derivation of the cron activations after a clock step, measured since the last check.
+// The next activation moves with the wall clock, so that it stays at the same monotonic delay from now : the last
+// check is the monotonic anchor, and the delay from it to the next activation is unchanged by the step.
+func (s *scheduler) rederiveCron(step time.Duration) {
+	for _, c := range s.crons {
+		if !c.next.IsZero() {
+			c.next = c.next.Add(step)
+		}
+	}
+}
+
+// Difference between the wall-clock and the monotonic elapsed times from prev to now.
+// A positive value is a forward wall-clock step, a negative one a backward step.
+// It is 0 if any of the times has no monotonic reading, and a few nanoseconds between two close readings.
+func clockStep(prev, now time.Time) time.Duration {
+	if prev.IsZero() {
+		return 0
+	}
+	return now.Round(0).Sub(prev.Round(0)) - now.Sub(prev) // 0 without monotonic readings
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCronParse(t *testing.T) {
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "TZ=Nowhere/Land * * * * *"} {
+		if _, err := ParseCron(bad); !errors.Is(err, ErrCronSyntax) {
+			t.Fatalf("Expected a syntax error for %q, got %v", bad, err)
+		}
+	}
+	for _, good := range []string{"* * * * *", "*/5 1-3 1,15 jan-jun mon-fri", "0 0 * * 7", "@daily", "CRON_TZ=UTC 30 9 * * *"} {
+		if _, err := ParseCron(good); err != nil {
+			t.Fatalf("Unexpected error for %q : %v", good, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		tt, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tt
+	}
+	cases := []struct{ expr, from, next string }{
+		{"*/5 * * * *", "2024-01-01 10:02", "2024-01-01 10:05"},
+		{"*/5 * * * *", "2024-01-01 10:05", "2024-01-01 10:10"},
+		{"0 9 * * mon-fri", "2024-01-05 09:00", "2024-01-08 09:00"}, // friday to monday
+		{"0 0 1 * *", "2024-01-31 12:00", "2024-02-01 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"0 12 13 * fri", "2024-01-01 00:00", "2024-01-05 12:00"}, // either day field matches
+		{"@hourly", "2024-12-31 23:59", "2025-01-01 00:00"},
+	}
+	for _, c := range cases {
+		sched, err := ParseCron("CRON_TZ=UTC " + c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sched.Next(utc(c.from)); !got.Equal(utc(c.next)) {
+			t.Fatalf("%q after %s : expected %s, got %s", c.expr, c.from, c.next, got)
+		}
+	}
+	never, _ := ParseCron("0 0 30 2 *")
+	if !never.Next(time.Now()).IsZero() {
+		t.Fatalf("Expected February 30th to never match")
+	}
+}
+
+func TestCronTimeZone(t *testing.T) {
+	sched, err := ParseCron("CRON_TZ=Europe/Paris 30 2 * * *")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	// 2:30 does not exist on 2024-03-31 in Paris, clocks jump from 2:00 to 3:00
+	from := time.Date(2024, 3, 30, 12, 0, 0, 0, sched.Location())
+	next := sched.Next(from)
+	if next.Day() != 1 || next.Hour() != 2 || next.Minute() != 30 {
+		t.Fatalf("Expected the nonexistent time to be skipped, got %v", next)
+	}
+	if got := sched.Next(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)); got.UTC().Hour() != 0 || got.UTC().Minute() != 30 {
+		t.Fatalf("Expected 2:30 in Paris to be 0:30 UTC in summer, got %v", got.UTC())
+	}
+}
+
+func TestCronClockSteps(t *testing.T) {
+	s := New().(*scheduler)
+	runs := 0
+	if err := s.AddCron("CRON_TZ=UTC */5 * * * *", countTask{&runs, nil}); err != nil {
+		t.Fatal(err)
+	}
+	c := s.crons[0]
+	base := time.Date(2024, 1, 1, 10, 4, 0, 0, time.UTC)
+	c.next = c.sched.Next(base)
+
+	if due := s.dueCron(base.Add(time.Minute)); len(due) != 1 {
+		t.Fatalf("Expected the 10:05 occurrence to be due")
+	}
+	// forward step of 3 hours : a single catch up run
+	if due := s.dueCron(base.Add(3 * time.Hour)); len(due) != 1 || !c.next.Equal(base.Add(3*time.Hour+time.Minute)) {
+		t.Fatalf("Expected a single run after a forward step, next %v", c.next)
+	}
+	// small backward step right after a run : the next run stays 5 minutes away, at 13:08 in the new time
+	s.dueCron(base.Add(3*time.Hour + time.Minute)) // runs 13:05
+	s.rederiveCron(-2 * time.Minute)
+	if !c.next.Equal(base.Add(3*time.Hour + 4*time.Minute)) {
+		t.Fatalf("Expected 13:08 after a small backward step, got %v", c.next)
+	}
+	// large backward step : the occurrences already run in the past hours are not run again
+	s.rederiveCron(-3 * time.Hour)
+	if !c.next.Equal(base.Add(4 * time.Minute)) {
+		t.Fatalf("Expected 10:08 after a large backward step, got %v", c.next)
+	}
+	for at := base; at.Before(base.Add(4 * time.Minute)); at = at.Add(time.Minute) {
+		if due := s.dueCron(at); len(due) != 0 {
+			t.Fatalf("Unexpected run at %v after a large backward step", at)
+		}
+	}
+	if due := s.dueCron(base.Add(4 * time.Minute)); len(due) != 1 || !c.next.Equal(base.Add(6*time.Minute)) {
+		t.Fatalf("Expected a run at 10:08 after a large backward step, next %v", c.next)
+	}
+	if step := clockStep(time.Now(), time.Now()); step > clockStepTolerance || step < -clockStepTolerance {
+		t.Fatalf("Unexpected clock step %v", step)
+	}
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import "time"
+
+// Weight of the last tick in the recent load average used by degraded mode.
+const recentLoadWeight = 0.2
+
+// degradation holds the degraded mode thresholds and the recent load.
+type degradation struct {
+	enter  float64 // recent load above which degraded mode is entered, 0 if disabled
+	exit   float64 // recent load below which degraded mode is left
+	recent float64 // exponential moving average of the ticks load
+}
+
+// Add express tasks sheduled to run every 'period' ticks. Express tasks keep running in degraded mode.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddExpress(period int, t ...Task) {
+	if period <= 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, express: true})
+	}
+}
+
+// WithDegradation enters degraded mode when the recent load exceeds enter, running only express tasks, and restores
+// full operation when the recent load falls below exit. The recent load is a moving average of the time spent in each
+// tick, as a ratio of the tick duration. Since degraded mode lowers the load, exit should be well below enter to avoid
+// switching back and forth. An EventDegraded or EventRestored event is emitted at each switch.
+// A 0 or negative enter disables degraded mode, which is the default.
+func WithDegradation(enter, exit float64) Option {
+	return func(s *scheduler) {
+		s.degrade.enter, s.degrade.exit = max(enter, 0), min(exit, enter)
+	}
+}
+
+// Check whether the scheduler is in degraded mode.
+func (s *scheduler) Degraded() bool {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	return s.degraded
+}
+
+// Update the recent load with the busy duration of the last tick, switching mode when thresholds are crossed.
+func (s *scheduler) checkDegradation(busy, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+
+	s.locktasks.Lock()
+	d := &s.degrade
+	d.recent = (1-recentLoadWeight)*d.recent + recentLoadWeight*float64(busy)/float64(duration)
+	var kind EventKind
+	switch {
+	case d.enter <= 0:
+	case !s.degraded && d.recent >= d.enter:
+		s.degraded, kind = true, EventDegraded
+	case s.degraded && d.recent <= d.exit:
+		s.degraded, kind = false, EventRestored
+	}
+	s.locktasks.Unlock()
+
+	if kind != 0 {
+		s.emit(Event{Kind: kind, Tick: s.Ticks() - 1})
+	}
+}
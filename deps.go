@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// ErrDependencyCycle is returned by DependsOn and AddChain when a task would depend on itself. It matches ErrInvalid.
+var ErrDependencyCycle = newError("dependency cycle", ErrInvalid)
+
+// Make t depend on the tasks of on. At the ticks where t and some of them are due, t runs after them, and is skipped
+// if one of them failed or did not run. At the other ticks, t runs as usual. Dependencies add up.
+// It returns an error matching ErrTaskNotFound if t is not scheduled, and ErrDependencyCycle if one of on already
+// depends on t.
+func (s *scheduler) DependsOn(t Task, on ...Task) error {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	ee := s.registrations(t)
+	if len(ee) == 0 {
+		return ErrUnknownTask
+	}
+	for _, o := range on {
+		if sameTask(o, t) || s.reaches(o, t) {
+			return ErrDependencyCycle
+		}
+	}
+	for _, e := range ee {
+		e.after = append(e.after, on...)
+	}
+	return nil
+}
+
+// Add tasks scheduled to run every 'period' ticks, on the same ticks, each one depending on the previous one :
+// a failure skips the rest of the chain for that tick.
+// Negative or 0 period tasks are not scheduled. It returns ErrDependencyCycle, adding nothing, if a task appears
+// twice in the chain, or already depends on a task after it.
+func (s *scheduler) AddChain(period int, t ...Task) ([]*TaskHandle, error) {
+	if period <= 0 {
+		return nil, nil
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for i := range t {
+		for j := 0; j < i; j++ { // t[i] will depend on t[j]
+			if sameTask(t[i], t[j]) || s.reaches(t[j], t[i]) {
+				return nil, ErrDependencyCycle
+			}
+		}
+	}
+	offset := phase(len(s.tasks[period]), period) // slot the first task would have
+	hs := make([]*TaskHandle, len(t))
+	for i, tt := range t {
+		e := &entry{task: tt, fixed: true, offset: offset}
+		if i > 0 {
+			e.after = []Task{t[i-1]}
+		}
+		s.addEntry(period, e)
+		hs[i] = &TaskHandle{s: s, e: e}
+	}
+	return hs, nil
+}
+
+// unsafe list of the periodic, one-shot and cron registrations of t.
+func (s *scheduler) registrations(t Task) []*entry {
+	ee := s.periodic(t)
+	for _, v := range s.once {
+		for _, e := range v {
+			if sameTask(e.task, t) {
+				ee = append(ee, e)
+			}
+		}
+	}
+	for _, c := range s.crons {
+		if sameTask(c.task, t) {
+			ee = append(ee, c.entry)
+		}
+	}
+	return ee
+}
+
+// unsafe check whether a depends on b, directly or not.
+func (s *scheduler) reaches(a, b Task) bool {
+	for _, e := range s.registrations(a) {
+		for _, d := range e.after {
+			if sameTask(d, b) || s.reaches(d, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Order due entries so that each one comes after the due entries it depends on, keeping the order otherwise.
+// It returns the due entries each entry depends on, nil if there are none. Should the dependencies have a cycle,
+// the dependency closing it is ignored, so that no entry waits for itself.
+func byDependencies(due []*entry) map[*entry][]*entry {
+	var deps map[*entry][]*entry
+	for _, e := range due {
+		for _, t := range e.after {
+			for _, d := range due {
+				if d != e && sameTask(d.task, t) {
+					if deps == nil {
+						deps = map[*entry][]*entry{}
+					}
+					deps[e] = append(deps[e], d)
+				}
+			}
+		}
+	}
+	if deps == nil {
+		return nil
+	}
+
+	ordered := make([]*entry, 0, len(due))
+	seen := make(map[*entry]bool, len(due))
+	var visit func(e *entry)
+	visit = func(e *entry) {
+		if seen[e] {
+			return
+		}
+		seen[e] = true
+		var kept []*entry
+		for _, d := range deps[e] {
+			if seen[d] && !slices.Contains(ordered, d) {
+				continue // d is being visited, and depends on e
+			}
+			visit(d)
+			kept = append(kept, d)
+		}
+		if kept == nil {
+			delete(deps, e)
+		} else {
+			deps[e] = kept
+		}
+		ordered = append(ordered, e)
+	}
+	for _, e := range due {
+		visit(e)
+	}
+	copy(due, ordered)
+	return deps
+}
+
+// Check whether the due entries e depends on all succeeded, logging the skip otherwise.
+func (s *scheduler) ready(e *entry, run *tickRun) bool {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+
+	for _, d := range run.deps[e] {
+		if !run.ok[d] {
+			s.log(slog.LevelDebug, "task skipped, dependency did not succeed", "task", e.task, "tick", run.tick)
+			return false
+		}
+	}
+	return true
+}
+
+// Signal the entries depending on e that it is over, whether it ran or not.
+func (run *tickRun) finish(e *entry) {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+
+	if c, ok := run.done[e]; ok {
+		close(c)
+		delete(run.done, e)
+	}
+}
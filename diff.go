@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// PlanDiff lists the changes between two schedule plans.
+type PlanDiff struct {
+	Added    []Spec       // specs only in the new plan
+	Removed  []Spec       // specs only in the old plan
+	Modified []SpecChange // specs in both plans, with different settings
+}
+
+// SpecChange is a spec modified between two plans.
+type SpecChange struct {
+	Old, New Spec
+}
+
+// Check that the plans are equivalent.
+func (d PlanDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Changes undoing the diff.
+func (d PlanDiff) inverse() PlanDiff {
+	inv := PlanDiff{Added: d.Removed, Removed: d.Added}
+	for _, c := range d.Modified {
+		inv.Modified = append(inv.Modified, SpecChange{Old: c.New, New: c.Old})
+	}
+	return inv
+}
+
+// Compute the changes turning plan a into plan b.
+// Specs are matched by name, unnamed specs by their task. A spec is modified when its period, offset, priority,
+// jitter, tenant, timeout, express, exempt, run keys or commit flags, tags, concurrency key, or task changes. Policies are functions that cannot be compared, their changes are not detected.
+func Diff(a, b SchedulePlan) PlanDiff {
+	var d PlanDiff
+	matched := make([]bool, len(b))
+	for _, old := range a {
+		j := b.find(old, matched)
+		if j < 0 {
+			d.Removed = append(d.Removed, old)
+			continue
+		}
+		matched[j] = true
+		if !sameSpec(old, b[j]) {
+			d.Modified = append(d.Modified, SpecChange{Old: old, New: b[j]})
+		}
+	}
+	for j, sp := range b {
+		if !matched[j] {
+			d.Added = append(d.Added, sp)
+		}
+	}
+	return d
+}
+
+// Index of the first spec not yet matched that identifies as sp, -1 if none.
+func (p SchedulePlan) find(sp Spec, matched []bool) int {
+	for j, other := range p {
+		if matched[j] {
+			continue
+		}
+		if sp.Name != "" && other.Name == sp.Name {
+			return j
+		}
+		if sp.Name == "" && other.Name == "" && sameTask(sp.Task, other.Task) {
+			return j
+		}
+	}
+	return -1
+}
+
+// Check that two specs have the same comparable settings.
+func sameSpec(a, b Spec) bool {
+	return a.Period == b.Period && a.Tenant == b.Tenant && a.Timeout == b.Timeout && a.Priority == b.Priority &&
+		a.Jitter == b.Jitter && a.Express == b.Express && a.Exempt == b.Exempt && a.RunKeys == b.RunKeys &&
+		a.Commit == b.Commit && slices.Equal(a.Tags, b.Tags) && a.Key == b.Key && a.FixedOffset == b.FixedOffset &&
+		(!a.FixedOffset || phase(a.Offset, a.Period) == phase(b.Offset, b.Period)) && sameTask(a.Task, b.Task)
+}
+
+// Compare tasks without panicking on non comparable task types, which are never equal.
+func sameTask(a, b Task) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// Apply the changes of a diff, removing, replacing and adding the tasks concerned.
+// Tasks not mentioned in the diff are left untouched, with their history.
+// If a spec is rejected, the scheduler is restored and the error identifies the spec.
+func (s *scheduler) ApplyDiff(d PlanDiff) error {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	var removed []*entry
+	drop := func(sp Spec) {
+		if e := s.registered(sp); e != nil {
+			s.removeEntry(e)
+			removed = append(removed, e)
+		}
+	}
+	add := append([]Spec(nil), d.Added...)
+	for _, sp := range d.Removed {
+		drop(sp)
+	}
+	for _, c := range d.Modified {
+		drop(c.Old)
+		add = append(add, c.New)
+	}
+
+	added := make([]*entry, 0, len(add))
+	for i, sp := range add {
+		e, err := s.addSpec(sp)
+		if err != nil {
+			for _, e := range added { // roll back
+				s.removeEntry(e)
+			}
+			for _, e := range removed {
+				s.tasks[e.period] = append(s.tasks[e.period], e)
+				s.changed(ChangeAdded, e)
+			}
+			return fmt.Errorf("spec %d : %w", i, err)
+		}
+		added = append(added, e)
+	}
+	return nil
+}
+
+// unsafe lookup of the periodic entry matching a spec, by name or by task for unnamed specs, nil if none.
+func (s *scheduler) registered(sp Spec) *entry {
+	if sp.Name != "" {
+		return s.named(sp.Name)
+	}
+	for _, v := range s.tasks {
+		for _, e := range v {
+			if e.name == "" && sameTask(e.task, sp.Task) {
+				return e
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+)
+
+// TaskEnv is the environment of a task run, passed in the context of TaskCtx tasks and retrieved with
+// EnvFromContext, so that scheduled code reports about itself the same way everywhere.
+type TaskEnv struct {
+	ID     TaskID // registration running
+	Name   string // name of the task, if any
+	Period int    // period in ticks, 0 for one-shot and cron tasks
+	Tick   int    // tick of the run
+
+	s *scheduler
+	e *entry
+}
+
+// key of the task environment in the run context
+type envKey struct{}
+
+// EnvFromContext returns the environment of the run ctx belongs to, if any.
+func EnvFromContext(ctx context.Context) (*TaskEnv, bool) {
+	env, ok := ctx.Value(envKey{}).(*TaskEnv)
+	return env, ok
+}
+
+// Derive the context of a run of e at tick, carrying its environment.
+func (s *scheduler) withEnv(ctx context.Context, e *entry, tick int) context.Context {
+	return context.WithValue(ctx, envKey{}, &TaskEnv{ID: e.id, Name: e.name, Period: e.period, Tick: tick, s: s, e: e})
+}
+
+// Logger of the scheduler, see SetLogger, tagged with the task, its ID and the tick of the run.
+// It discards everything if logging is disabled.
+func (env *TaskEnv) Logger() *slog.Logger {
+	l := env.s.logger()
+	if l == nil {
+		return slog.New(discardHandler{})
+	}
+	l = l.With("task", env.e.label(), "taskId", env.ID, "tick", env.Tick)
+	if env.s.name != "" {
+		l = l.With("scheduler", env.s.name)
+	}
+	return l
+}
+
+// Record the current value of a metric of the task, replacing the previous one. The last values are reported
+// by TaskHandle.Stats and the admin status.
+func (env *TaskEnv) Record(name string, value float64) {
+	env.e.metrics.set(name, value)
+}
+
+// taskMetrics are the last values recorded by the runs of an entry.
+type taskMetrics struct {
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+func (m *taskMetrics) set(name string, value float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.values == nil {
+		m.values = map[string]float64{}
+	}
+	m.values[name] = value
+}
+
+// Copy of the values, nil if none.
+func (m *taskMetrics) snapshot() map[string]float64 {
+	if m == nil {
+		return nil
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if len(m.values) == 0 {
+		return nil
+	}
+	return maps.Clone(m.values)
+}
+
+// A slog handler discarding everything.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
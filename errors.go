@@ -0,0 +1,36 @@
+package scheduler
+
+import "errors"
+
+// Causes of the errors of the package. The specific errors match their cause with errors.Is, so that callers can
+// branch on it : errors.Is(err, ErrTimeout) holds for a task or hook timeout, and for an interrupted stop, and
+// errors.Is(err, ErrInvalid) for any rejected argument. The errors reported for the runs of the tasks, such as
+// ErrNotCommitted, and the reasons tasks are removed, such as ErrTaskRetired, have no cause.
+var (
+	ErrInvalid        = errors.New("invalid request")
+	ErrExists         = errors.New("already exists")
+	ErrAlreadyStarted = errors.New("scheduler already started")
+	ErrNotRunning     = errors.New("scheduler not running")
+	ErrTaskNotFound   = errors.New("task not found")
+	ErrScheduleFull   = errors.New("schedule full")
+	ErrTimeout        = errors.New("timed out")
+)
+
+// causeError is a specific error, matching its cause.
+type causeError struct {
+	msg   string
+	cause error
+}
+
+// Return an error with msg, matching cause with errors.Is.
+func newError(msg string, cause error) error {
+	return &causeError{msg: msg, cause: cause}
+}
+
+func (e *causeError) Error() string {
+	return e.msg
+}
+
+func (e *causeError) Unwrap() error {
+	return e.cause
+}
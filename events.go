@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EventKind identifies the kind of an Event.
+// Kinds are bit flags, they can be combined to subscribe to several kinds at once.
+type EventKind int
+
+const (
+	// A hook timed out or panicked, and was abandoned.
+	EventHookAbandoned EventKind = 1 << iota
+	// A traced task run lasted abnormally long or short compared with its history.
+	EventAnomaly
+	// A task run exceeded its timeout, and was abandoned.
+	EventTaskTimeout
+	// The SLA compliance of a task fell below its target.
+	EventSLABreach
+	// The scheduler entered degraded mode, only express tasks run.
+	EventDegraded
+	// The scheduler left degraded mode, all tasks run again.
+	EventRestored
+	// The tasks of a tick took longer than the tick duration.
+	EventOverrun
+	// A tick ended, Duration is the time spent in the tick.
+	EventTick
+	// A task run ended, successfully or with Err.
+	EventRun
+	// A task run failed with Err.
+	EventTaskError
+	// A task was removed by the scheduler itself, Err being the reason : the error of its last run when removed by
+	// its error policy, ErrTaskRetired after the runs of AddTimes, or ErrTaskExpired after the lifetime of AddWithTTL.
+	EventTaskRemoved
+)
+
+// Names of the event kinds, in bit order.
+var eventNames = []string{"HookAbandoned", "Anomaly", "TaskTimeout", "SLABreach", "Degraded", "Restored", "Overrun",
+	"Tick", "Run", "TaskError", "TaskRemoved"}
+
+// Name of the kind, or of the combined kinds separated by '|'.
+func (k EventKind) String() string {
+	if k == EventAll {
+		return "All"
+	}
+	var names []string
+	for i, n := range eventNames {
+		if k&(1<<i) != 0 {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+	return strings.Join(names, "|")
+}
+
+// EventAll matches all kinds of events.
+const EventAll EventKind = -1
+
+// Event describes something that happened in the scheduler.
+type Event struct {
+	Kind EventKind // kind of event
+	Tick int       // tick during which the event happened
+	Time time.Time // time of the event
+	Task Task      // task concerned by the event, if any
+	Err  error     // error associated with the event, if any
+
+	TaskID   TaskID        // registration of the task concerned by the event, if any
+	Duration time.Duration // duration of the run or hook concerned, if any
+
+	BeforeHooks time.Duration // duration of the before hooks of the tick, for EventTick
+	AfterHooks  time.Duration // duration of the after hooks of the tick, for EventTick
+}
+
+// subscriber is a registered event listener.
+type subscriber struct {
+	kinds EventKind
+	fn    func(Event)
+	obs   *Observation // observation delivering the events, nil for synchronous listeners
+}
+
+// Subscribe registers fn to be called for each event matching kinds.
+// Listeners are called synchronously, from the goroutine emitting the event. They should return quickly.
+func (s *scheduler) Subscribe(kinds EventKind, fn func(Event)) {
+	if fn == nil {
+		return
+	}
+	s.lockevents.Lock()
+	defer s.lockevents.Unlock()
+
+	s.subscribers = append(s.subscribers, subscriber{kinds: kinds, fn: fn})
+}
+
+// Emit an event to the matching subscribers.
+func (s *scheduler) emit(ev Event) {
+	s.lockevents.RLock()
+	subs := s.subscribers
+	s.lockevents.RUnlock()
+
+	if len(subs) == 0 {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = s.now()
+	}
+	for _, sub := range subs {
+		if sub.kinds&ev.Kind != 0 {
+			sub.fn(ev)
+		}
+	}
+}
+
+// Register fn to be called for each task removed by the scheduler itself, with the reason of the removal,
+// so that callers can log, alert, or reschedule the task elsewhere. See EventTaskRemoved.
+func (s *scheduler) OnTaskRemoved(fn func(t Task, reason error)) {
+	if fn == nil {
+		return
+	}
+	s.Subscribe(EventTaskRemoved, func(ev Event) { fn(ev.Task, ev.Err) })
+}
+
+// Emit the events of the removals of a tick : the entries expired, then those removed by their error policy
+// after their runs, or retired.
+func (s *scheduler) emitRemovals(run *tickRun, decisions map[*entry]decision, expired []*entry) {
+	removed := func(e *entry, reason error) {
+		s.emit(Event{Kind: EventTaskRemoved, Tick: run.tick, Task: e.task, TaskID: e.id, Err: reason})
+	}
+	for _, e := range expired {
+		removed(e, ErrTaskExpired)
+	}
+	for _, e := range run.ran {
+		if d, ok := decisions[e]; ok && d.remove {
+			removed(e, run.errs[e])
+		} else if slices.Contains(run.retired, e) {
+			removed(e, ErrTaskRetired)
+		}
+	}
+}
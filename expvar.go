@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"expvar"
+	"sync"
+)
+
+// Schedulers published with PublishExpvar, by name.
+var (
+	lockexpvar sync.Mutex
+	published  = map[string]Scheduler{}
+)
+
+// PublishExpvar publishes the Stats of s as the expvar variable name, so they show up on /debug/vars.
+// Publishing the same name again publishes the new scheduler in place of the previous one.
+func PublishExpvar(name string, s Scheduler) {
+	lockexpvar.Lock()
+	defer lockexpvar.Unlock()
+
+	if _, ok := published[name]; !ok {
+		expvar.Publish(name, expvar.Func(func() any {
+			lockexpvar.Lock()
+			s := published[name]
+			lockexpvar.Unlock()
+			return s.Stats()
+		}))
+	}
+	published[name] = s
+}
@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileStore is a RunStore keeping everything in a JSON file, safe for concurrent use.
+// Each change rewrites the file atomically, so other processes can read it at any time, and a process restarting
+// finds the last schedule saved. Only one process should write to the file. Like any persistent store, it does not
+// keep the tasks and policies of the specs.
+type FileStore struct {
+	lock sync.Mutex
+	path string
+}
+
+var _ RunStore = &FileStore{}
+
+// Return a FileStore persisting to path. The file is created on the first change.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// fileContent is the content of the file of a FileStore.
+type fileContent struct {
+	Specs   []Spec          `json:"specs,omitempty"`
+	States  []TaskState     `json:"states,omitempty"`
+	Letters []DeadLetter    `json:"deadLetters,omitempty"`
+	Runs    map[string]bool `json:"runs,omitempty"` // run keys, true once completed
+}
+
+// Read the file, empty if it does not exist yet.
+func (f *FileStore) read() (fileContent, error) {
+	var c fileContent
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// Read the file, apply change, and write it back if change succeeds.
+func (f *FileStore) update(change func(c *fileContent) error) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c, err := f.read()
+	if err != nil {
+		return err
+	}
+	if err := change(&c); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(f.path, data)
+}
+
+// Read the file under the lock.
+func (f *FileStore) load() (fileContent, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.read()
+}
+
+func (f *FileStore) SaveSpecs(specs []Spec) error {
+	return f.update(func(c *fileContent) error {
+		c.Specs = specs // tasks and policies are not serialized
+		return nil
+	})
+}
+
+func (f *FileStore) LoadSpecs() ([]Spec, error) {
+	c, err := f.load()
+	return c.Specs, err
+}
+
+func (f *FileStore) SaveStates(states []TaskState) error {
+	return f.update(func(c *fileContent) error {
+		c.States = states
+		return nil
+	})
+}
+
+func (f *FileStore) LoadStates() ([]TaskState, error) {
+	c, err := f.load()
+	return c.States, err
+}
+
+func (f *FileStore) AddDeadLetter(d DeadLetter) error {
+	return f.update(func(c *fileContent) error {
+		c.Letters = append(c.Letters, d)
+		return nil
+	})
+}
+
+func (f *FileStore) DeadLetters() ([]DeadLetter, error) {
+	c, err := f.load()
+	return c.Letters, err
+}
+
+func (f *FileStore) BeginRun(key string) (bool, error) {
+	fresh := false
+	err := f.update(func(c *fileContent) error {
+		if _, ok := c.Runs[key]; ok {
+			return nil
+		}
+		if c.Runs == nil {
+			c.Runs = map[string]bool{}
+		}
+		c.Runs[key], fresh = false, true
+		return nil
+	})
+	return fresh, err
+}
+
+func (f *FileStore) EndRun(key string) error {
+	return f.update(func(c *fileContent) error {
+		if c.Runs == nil {
+			c.Runs = map[string]bool{}
+		}
+		c.Runs[key] = true
+		return nil
+	})
+}
+
+func (f *FileStore) PendingRuns() ([]string, error) {
+	c, err := f.load()
+	var keys []string
+	for k, done := range c.Runs {
+		if !done {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, err
+}
+
+// Write data to path atomically, through a temporary file renamed over it.
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
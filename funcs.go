@@ -0,0 +1,52 @@
+package scheduler
+
+import "context"
+
+// Return a task running fn.
+// Each call returns a distinct task, that can be compared and removed, unlike the function itself.
+func TaskFunc(fn func() error) Task {
+	return &funcTask{fn: fn}
+}
+
+// Return a task running fn, which never fails.
+func TaskOf(fn func()) Task {
+	return &funcTask{fn: func() error { fn(); return nil }}
+}
+
+// Return a task running fn with the run context. See TaskCtx.
+func TaskCtxFunc(fn func(ctx context.Context) error) TaskCtx {
+	return &ctxFuncTask{fn: fn}
+}
+
+// Return a task doing nothing, for testing.
+func NoopTask() Task {
+	return &funcTask{fn: func() error { return nil }}
+}
+
+// Return a task always failing with err, for testing.
+func ErrTask(err error) Task {
+	return &funcTask{fn: func() error { return err }}
+}
+
+// funcTask is a task running a function.
+// It is used through a pointer, so that it remains comparable for Remove.
+type funcTask struct {
+	fn func() error
+}
+
+func (t *funcTask) Run() error {
+	return t.fn()
+}
+
+// ctxFuncTask is a task running a function with the run context.
+type ctxFuncTask struct {
+	fn func(ctx context.Context) error
+}
+
+func (t *ctxFuncTask) Run() error {
+	return t.fn(context.Background())
+}
+
+func (t *ctxFuncTask) RunContext(ctx context.Context) error {
+	return t.fn(ctx)
+}
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"slices"
+	"sync/atomic"
+)
+
+// Group is a named set of tasks of a scheduler, paused, resumed, removed or re-prioritized as a unit,
+// such as a "maintenance" group disabled during peak hours. Its tasks run on the ticks of the scheduler,
+// like any other task. Tasks added to a paused group start paused.
+type Group struct {
+	s           atomic.Pointer[scheduler] // scheduler of the tasks, changed by Handoff
+	name        string
+	entries     []*entry // registrations added through the group
+	paused      bool
+	priority    int
+	prioritized bool // priority set, and given to the tasks added from now on
+}
+
+// Get the group with the given name, created empty on first use.
+func (s *scheduler) Group(name string) *Group {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	g, ok := s.groups[name]
+	if !ok {
+		g = &Group{name: name}
+		g.s.Store(s)
+		s.groups[name] = g
+	}
+	return g
+}
+
+// Lock the tasks of the scheduler of the group, and return it.
+func (g *Group) lockTasks() *scheduler {
+	return lockTasks(&g.s)
+}
+
+// Name of the group.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Add tasks sheduled to run every 'period' ticks to the group, as Scheduler.Add does.
+func (g *Group) Add(period int, t ...Task) []*TaskHandle {
+	if period <= 0 {
+		return nil
+	}
+	s := g.lockTasks()
+	defer s.locktasks.Unlock()
+
+	hs := s.add(period, t...)
+	for _, h := range hs {
+		h.e.paused = g.paused
+		if g.prioritized {
+			h.e.priority = g.priority
+		}
+		g.entries = append(g.entries, h.e)
+	}
+	return hs
+}
+
+// Suspend the runs of all the tasks of the group, keeping them scheduled.
+func (g *Group) Pause() {
+	g.setPaused(true)
+}
+
+// Resume the runs of all the tasks of the group, including those paused through their handle.
+func (g *Group) Resume() {
+	g.setPaused(false)
+}
+
+func (g *Group) setPaused(paused bool) {
+	s := g.lockTasks()
+	defer s.locktasks.Unlock()
+
+	g.paused = paused
+	for _, e := range g.entries {
+		e.paused = paused
+	}
+}
+
+// Check whether the group is paused.
+func (g *Group) Paused() bool {
+	s := g.lockTasks()
+	defer s.locktasks.Unlock()
+
+	return g.paused
+}
+
+// Set the priority of all the tasks of the group, and of those added to it from now on. See AddWithPriority.
+func (g *Group) SetPriority(priority int) {
+	s := g.lockTasks()
+	defer s.locktasks.Unlock()
+
+	g.priority, g.prioritized = priority, true
+	for _, e := range g.entries {
+		e.priority = priority
+	}
+}
+
+// Remove all the tasks of the group from the scheduler. The group stays usable.
+func (g *Group) Remove() {
+	s := g.lockTasks()
+	defer s.locktasks.Unlock()
+
+	for _, e := range g.entries {
+		s.removeEntry(e)
+	}
+	g.entries = nil
+}
+
+// Number of tasks of the group still scheduled.
+func (g *Group) Tasks() int {
+	s := g.lockTasks()
+	defer s.locktasks.Unlock()
+
+	g.prune(s)
+	return len(g.entries)
+}
+
+// unsafe removal of the registrations no longer scheduled in s from the group.
+func (g *Group) prune(s *scheduler) {
+	active := map[*entry]bool{}
+	s.each(func(e *entry) { active[e] = true })
+	g.entries = slices.DeleteFunc(g.entries, func(e *entry) bool { return !active[e] })
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+// TaskHandle is a single registration of a task, returned by Add.
+// It manipulates that registration only, even if the same task was added several times.
+type TaskHandle struct {
+	s *scheduler
+	e *entry
+}
+
+// HandleStats are the statistics of a registration.
+type HandleStats struct {
+	Period   int                // period in ticks
+	Active   bool               // still scheduled
+	Paused   bool               // suspended by Pause
+	Outcomes Outcomes           // runs outcomes
+	Offset   Offset             // effective offset, while the registration is active
+	Metrics  map[string]float64 // last values recorded with TaskEnv.Record, nil if none
+	Left     int                // runs left before the registration retires, 0 if unlimited, see AddTimes
+}
+
+// Return the registered task.
+func (h *TaskHandle) Task() Task {
+	return h.e.task
+}
+
+// Remove the registration from the scheduler. It returns false if it was already removed.
+func (h *TaskHandle) Cancel() bool {
+	h.s.locktasks.Lock()
+	defer h.s.locktasks.Unlock()
+
+	if !h.active() {
+		return false
+	}
+	h.s.removeEntry(h.e)
+	return true
+}
+
+// Suspend the runs of the registration, keeping it scheduled.
+func (h *TaskHandle) Pause() {
+	h.s.locktasks.Lock()
+	defer h.s.locktasks.Unlock()
+
+	h.e.paused = true
+}
+
+// Resume the runs of a paused registration.
+func (h *TaskHandle) Resume() {
+	h.s.locktasks.Lock()
+	defer h.s.locktasks.Unlock()
+
+	h.e.paused = false
+}
+
+// Change the period of the registration, keeping its history and its phase : it next runs at the tick it was due,
+// then every period ticks, as with Scheduler.Reschedule. The new period replaces the original one of a throttled
+// registration, the throttling is cancelled.
+// It returns false if the period is negative or 0, or if the registration was removed.
+func (h *TaskHandle) Reschedule(period int) bool {
+	if period <= 0 {
+		return false
+	}
+	h.s.locktasks.Lock()
+	defer h.s.locktasks.Unlock()
+
+	if !h.active() {
+		return false
+	}
+	h.s.reschedule([]*entry{h.e}, period)
+	return true
+}
+
+// Get the statistics of the registration.
+func (h *TaskHandle) Stats() HandleStats {
+	h.s.locktasks.Lock()
+	defer h.s.locktasks.Unlock()
+
+	st := HandleStats{
+		Period: h.e.period,
+		Active: h.active(),
+		Paused: h.e.paused,
+		Outcomes: Outcomes{
+			Successes: h.e.outcomes.successes,
+			Failures:  h.e.outcomes.failures,
+			Rate:      h.e.outcomes.recent.ratio(),
+		},
+		Metrics: h.e.metrics.snapshot(),
+		Left:    h.e.left,
+	}
+	if st.Active {
+		st.Offset = h.s.offset(h.e, h.s.index(h.e))
+	}
+	return st
+}
+
+// Get the effective offset of the registration, false if it was removed. See Scheduler.Offsets.
+func (h *TaskHandle) Offset() (Offset, bool) {
+	h.s.locktasks.Lock()
+	defer h.s.locktasks.Unlock()
+
+	i := h.s.index(h.e)
+	if i < 0 {
+		return Offset{}, false
+	}
+	return h.s.offset(h.e, i), true
+}
+
+// unsafe check that the registration is still scheduled.
+func (h *TaskHandle) active() bool {
+	return h.s.index(h.e) >= 0
+}
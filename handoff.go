@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrHandoffTarget is returned by Handoff when the target is not a scheduler created by New. It matches ErrInvalid.
+var ErrHandoffTarget = newError("handoff target not created by New", ErrInvalid)
+
+// Transfer the live schedule to target, such as a scheduler created with new options, and switch execution to it.
+// A running scheduler stops once its current tick is over, and target starts at once with the same tick duration,
+// so that no tick is run twice or by both. The tasks keep their history, offsets, run state and IDs, and target
+// continues from the tick counter and statistics of s, its own tasks being kept. The heartbeat, the budgets of the
+// tenants and the statistics of the tenants and tags move too, the heartbeat and budgets of target prevailing. The tasks are wrapped with the
+// middleware of target instead of the one of s. The scopes, groups and owners of s add their tasks to target from
+// now on. The handles returned by s become inactive, and s is left empty.
+// It returns an error matching ErrAlreadyStarted if target is running.
+func (s *scheduler) Handoff(target Scheduler) error {
+	t, ok := target.(*scheduler)
+	if !ok {
+		return ErrHandoffTarget
+	}
+	if t == s {
+		return nil
+	}
+	t.lockrun.Lock() // target is not started until it runs the tasks of s
+	defer t.lockrun.Unlock()
+
+	t.lockstats.RLock()
+	busy := t.running()
+	t.lockstats.RUnlock()
+	if busy {
+		return newError("handoff target already running", ErrAlreadyStarted)
+	}
+
+	s.lockstats.RLock()
+	running, duration := s.running(), s.duration
+	s.lockstats.RUnlock()
+	if running {
+		s.stop(StopReasonHandoff) // waits for the current tick
+	}
+	s.transfer(t)
+	if s.paused.Load() {
+		t.Pause()
+	}
+	if running {
+		t.spawn(t.open(duration))
+	}
+	return nil
+}
+
+// Move the tasks, scopes, groups, owners, heartbeat, tenants, tags and statistics of s to t.
+func (s *scheduler) transfer(t *scheduler) {
+	first, second := s, t // locked in the order of their addresses, for concurrent handoffs between them
+	if reflect.ValueOf(t).Pointer() < reflect.ValueOf(s).Pointer() {
+		first, second = t, s
+	}
+	first.lockowners.Lock()
+	defer first.lockowners.Unlock()
+	second.lockowners.Lock()
+	defer second.lockowners.Unlock()
+	first.locktasks.Lock()
+	defer first.locktasks.Unlock()
+	second.locktasks.Lock()
+	defer second.locktasks.Unlock()
+
+	if s.heartbeat != nil {
+		if t.heartbeat == nil {
+			s.heartbeat.task.(*heartbeatTask).s = t
+			t.heartbeat = s.heartbeat
+			t.lastBeat.Store(s.lastBeat.Load())
+		} else {
+			s.removeEntry(s.heartbeat) // the heartbeat of t is kept
+		}
+		s.heartbeat = nil
+	}
+
+	move := func(e *entry) {
+		s.changed(ChangeRemoved, e)
+		e.run = nil
+		t.register(e)
+	}
+	for p, v := range s.tasks {
+		for i, e := range v {
+			move(e)
+			if !e.fixed && len(t.tasks[p]) > 0 { // appended at another index, the phase is pinned
+				e.fixed, e.offset = true, phase(i, p)
+			}
+		}
+		t.tasks[p] = append(t.tasks[p], v...)
+	}
+	for tick, v := range s.once {
+		for _, e := range v {
+			move(e)
+		}
+		t.once[tick] = append(t.once[tick], v...)
+	}
+	for _, c := range s.crons {
+		move(c.entry)
+	}
+	t.crons = append(t.crons, s.crons...)
+	t.backlog = append(t.backlog, s.backlog...)
+	t.bucket = append(t.bucket, s.bucket...)
+	t.expiring = append(t.expiring, s.expiring...)
+	t.lastCheck = s.lastCheck
+	for name, g := range s.groups {
+		g.s.Store(t)
+		if tg, ok := t.groups[name]; ok { // the group of t gets the tasks
+			tg.entries = append(tg.entries, g.entries...)
+		} else {
+			t.groups[name] = g
+		}
+	}
+	for c := range s.scopes {
+		c.s.Store(t)
+		t.scopes[c] = true
+	}
+	for owner, c := range s.owners {
+		if _, ok := t.owners[owner]; !ok {
+			t.owners[owner] = c
+		}
+	}
+	for name, tn := range s.tenants {
+		tt := t.tenant(name)
+		if tt.budget == (TenantBudget{}) {
+			tt.budget = tn.budget
+		}
+		tt.busy += tn.busy
+		tt.shed += tn.shed
+	}
+	t.tagMerge(s.tags)
+	s.tasks, s.once, s.crons = map[int][]*entry{}, map[int][]*entry{}, nil
+	s.backlog, s.bucket, s.expiring, s.groups = nil, nil, nil, map[string]*Group{}
+	s.tenants, s.tags = map[string]*tenant{}, map[string]*rollup{}
+	s.scopes, s.owners = map[*Scope]bool{}, map[Owner]*Scope{}
+
+	first.lockstats.Lock()
+	defer first.lockstats.Unlock()
+	second.lockstats.Lock()
+	defer second.lockstats.Unlock()
+
+	t.ticks = s.ticks
+	t.slots += s.slots
+	t.load += s.load
+	t.lastTick, t.maxTick = s.lastTick, max(t.maxTick, s.maxTick)
+	t.lastHooks = s.lastHooks
+	t.runs += s.runs
+	t.failures += s.failures
+	t.overruns.Add(s.overruns.Load())
+}
+
+// Lock the mutex of the scheduler p points to, chosen by lock, and return the scheduler. Since a Handoff changes
+// p with the mutexes of both schedulers held, p is checked again once locked.
+func lockCurrent(p *atomic.Pointer[scheduler], lock func(s *scheduler) *sync.Mutex) *scheduler {
+	for {
+		s := p.Load()
+		l := lock(s)
+		l.Lock()
+		if p.Load() == s {
+			return s
+		}
+		l.Unlock()
+	}
+}
+
+// Lock the tasks of the scheduler p points to, and return it.
+func lockTasks(p *atomic.Pointer[scheduler]) *scheduler {
+	return lockCurrent(p, func(s *scheduler) *sync.Mutex { return &s.locktasks })
+}
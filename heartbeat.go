@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout of the requests of an HTTPHeartbeat created without one.
+const DefaultHeartbeatTimeout = 10 * time.Second
+
+// HeartbeatSink receives the heartbeats of a scheduler, for an external watchdog to check that it still ticks.
+type HeartbeatSink interface {
+	Beat(t time.Time) error
+}
+
+// HeartbeatFunc adapts a function to the HeartbeatSink interface.
+type HeartbeatFunc func(t time.Time) error
+
+func (f HeartbeatFunc) Beat(t time.Time) error {
+	return f(t)
+}
+
+// WithHeartbeat runs a heartbeat every period ticks, recording its time, see LastHeartbeat, and sending it to the
+// sinks. The heartbeat keeps running in maintenance and degraded modes. Sink errors are logged, the heartbeat does
+// not fail. The sinks are called on their own goroutine, so that a hung sink never blocks the ticks : while they are
+// still handling a heartbeat, the next ones are recorded but not sent.
+// A 0 or negative period runs no heartbeat, which is the default.
+// There are no heartbeats while the scheduler is paused or stopped : watchdogs should allow for the pauses.
+func WithHeartbeat(period int, sinks ...HeartbeatSink) Option {
+	return func(s *scheduler) {
+		s.setHeartbeat(period, sinks)
+	}
+}
+
+// Replace the heartbeat of s, removing it if period is 0 or negative.
+func (s *scheduler) setHeartbeat(period int, sinks []HeartbeatSink) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	if s.heartbeat != nil {
+		s.removeEntry(s.heartbeat)
+		s.heartbeat = nil
+	}
+	if period > 0 {
+		s.heartbeat = &entry{task: &heartbeatTask{s: s, sinks: sinks}, exempt: true, express: true}
+		s.addEntry(period, s.heartbeat)
+	}
+}
+
+// Time of the last heartbeat, zero if none ran yet.
+func (s *scheduler) LastHeartbeat() time.Time {
+	if n := s.lastBeat.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// The heartbeat task.
+type heartbeatTask struct {
+	s     *scheduler
+	sinks []HeartbeatSink
+
+	lock    sync.Mutex
+	sending bool           // the sinks are handling a heartbeat
+	sent    sync.WaitGroup // done once the sinks handled the heartbeat
+}
+
+func (h *heartbeatTask) Run() error {
+	now := h.s.now()
+	h.s.lastBeat.Store(now.UnixNano())
+	if len(h.sinks) == 0 {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.sending {
+		h.s.log(slog.LevelWarn, "heartbeat not sent, the sinks are still handling the previous one")
+		return nil
+	}
+	h.sending = true
+	h.sent.Add(1)
+	go func() {
+		defer h.sent.Done()
+		for _, sink := range h.sinks {
+			if err := sink.Beat(now); err != nil {
+				h.s.log(slog.LevelWarn, "heartbeat failed", "error", err)
+			}
+		}
+		h.lock.Lock()
+		h.sending = false
+		h.lock.Unlock()
+	}()
+	return nil
+}
+
+func (h *heartbeatTask) String() string {
+	return "heartbeat"
+}
+
+// FileHeartbeat writes the time of each heartbeat to the file at path, replacing it atomically, so that a watchdog
+// can check its content or modification time, as a Kubernetes exec probe or a systemd timer would.
+func FileHeartbeat(path string) HeartbeatSink {
+	return HeartbeatFunc(func(t time.Time) error {
+		return writeFile(path, []byte(t.Format(time.RFC3339Nano)+"\n"))
+	})
+}
+
+// HTTPHeartbeat sends a GET request to url on each heartbeat, as expected by dead man's switch services.
+// A request lasting more than timeout, DefaultHeartbeatTimeout if it is 0 or negative, or a reply other than 2xx,
+// is an error.
+func HTTPHeartbeat(url string, timeout time.Duration) HeartbeatSink {
+	if timeout <= 0 {
+		timeout = DefaultHeartbeatTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	return HeartbeatFunc(func(t time.Time) error {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("heartbeat to %s : %s", url, resp.Status)
+		}
+		return nil
+	})
+}
+
+// LivenessHandler replies 200 if the last heartbeat of s is at most maxAge old, and 503 otherwise, including
+// before the first one, for use as an HTTP liveness probe. The age is measured on the clock of s. See WithHeartbeat.
+func LivenessHandler(s Scheduler, maxAge time.Duration) http.Handler {
+	since := time.Since
+	if c, ok := s.(clocked); ok {
+		since = c.since
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last := s.LastHeartbeat()
+		if last.IsZero() || since(last) > maxAge {
+			http.Error(w, "no recent heartbeat", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "last heartbeat %s\n", last.Format(time.RFC3339Nano))
+	})
+}
+
+// clocked is implemented by the schedulers measuring time on their own clock, see WithClock.
+type clocked interface {
+	since(t time.Time) time.Duration
+}
@@ -0,0 +1,244 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrHookTimeout is the error of the event emitted when a hook is abandoned after its timeout. It matches ErrTimeout.
+var ErrHookTimeout = newError("hook timed out", ErrTimeout)
+
+// WithAsyncHooks runs the hooks on a separate goroutine, so that slow hooks never extend the tick duration.
+// Up to queue hook calls can be pending, further calls are dropped and counted.
+// Hooks then observe the scheduler slightly after the tick they were called for.
+// A 0 or negative queue runs the hooks on the tick goroutine, which is the default.
+func WithAsyncHooks(queue int) Option {
+	return func(s *scheduler) {
+		s.hookSize = max(queue, 0)
+	}
+}
+
+// Start the hook goroutine if hooks are asynchronous and it is not running.
+func (s *scheduler) startHooks() {
+	s.lockhooks.Lock()
+	defer s.lockhooks.Unlock()
+
+	if s.hookQueue == nil {
+		s.openHooks()
+	}
+}
+
+// Stop the hook goroutine once pending hooks are run, keeping the setting for the next start.
+func (s *scheduler) stopHooks() {
+	s.lockhooks.Lock()
+	defer s.lockhooks.Unlock()
+
+	s.closeHooks()
+}
+
+// unsafe creation of the hook queue and goroutine, if hooks are asynchronous.
+func (s *scheduler) openHooks() {
+	if s.hookSize <= 0 {
+		return
+	}
+	q := make(chan tracedHook, s.hookSize)
+	s.hookQueue = q
+	go func() {
+		for h := range q {
+			s.callHook(h.hook, h.trace)
+		}
+	}()
+}
+
+// unsafe closing of the hook queue, the goroutine terminates once the queue is drained.
+func (s *scheduler) closeHooks() {
+	if s.hookQueue != nil {
+		close(s.hookQueue)
+		s.hookQueue = nil
+	}
+}
+
+// Get the number of hook calls dropped because the asynchronous queue was full.
+func (s *scheduler) DroppedHooks() int {
+	return int(s.hookDrops.Load())
+}
+
+// tracedHook is a hook queued for asynchronous execution with the tracer recording its durations.
+type tracedHook struct {
+	hook  Hook
+	trace *TaskTracer
+}
+
+// Get the duration statistics of the before and after hooks, each hook call being recorded as a run.
+// Stats and the EventTick events report the durations of the hooks of each tick.
+func (s *scheduler) HookStats() (before, after TaskStat) {
+	return s.beforeTrace.stat(nil, 0), s.afterTrace.stat(nil, 0)
+}
+
+// HookHandle is the registration of a before or after hook, to remove it.
+type HookHandle struct {
+	s    *scheduler
+	list *[]*HookHandle // hooks it belongs to
+	hook Hook
+}
+
+// Add a Hook executed before all tasks are run at every tick. Hooks run in the order they were added.
+// A nil hook is not added, and nil is returned.
+func (s *scheduler) AddBeforeHook(h Hook) *HookHandle {
+	return s.addHook(&s.beforeTick, h)
+}
+
+// Add a Hook executed after all tasks are run at every tick. Hooks run in the order they were added.
+// A nil hook is not added, and nil is returned.
+func (s *scheduler) AddAfterHook(h Hook) *HookHandle {
+	return s.addHook(&s.afterTick, h)
+}
+
+// Add a hook to list.
+func (s *scheduler) addHook(list *[]*HookHandle, h Hook) *HookHandle {
+	if h == nil {
+		return nil
+	}
+	s.lockhooks.Lock()
+	defer s.lockhooks.Unlock()
+
+	return s.linkHook(list, h)
+}
+
+// unsafe append of a hook to list.
+func (s *scheduler) linkHook(list *[]*HookHandle, h Hook) *HookHandle {
+	hh := &HookHandle{s: s, list: list, hook: h}
+	*list = append(*list, hh)
+	return hh
+}
+
+// Remove the hook, returning false if it was already removed. A hook queued for asynchronous execution still runs.
+func (h *HookHandle) Remove() bool {
+	if h == nil {
+		return false
+	}
+	h.s.lockhooks.Lock()
+	defer h.s.lockhooks.Unlock()
+
+	return h.unlink()
+}
+
+// unsafe removal of the hook from its list.
+func (h *HookHandle) unlink() bool {
+	for i, hh := range *h.list {
+		if hh == h {
+			*h.list = append((*h.list)[:i:i], (*h.list)[i+1:]...) // copy, the hooks being run are unchanged
+			return true
+		}
+	}
+	return false
+}
+
+// Set a Hook that will be executed before all tasks are run at every tick, replacing the one it set previously.
+// nil removes it. The hooks added with AddBeforeHook are kept.
+//
+// Deprecated: use AddBeforeHook, which lets several hooks coexist.
+func (s *scheduler) SetBefore(h Hook) {
+	s.setHook(&s.beforeTick, &s.legacyBefore, h)
+}
+
+// Set a Hook that will be executed after all tasks are run at every tick, replacing the one it set previously.
+// nil removes it. The hooks added with AddAfterHook are kept.
+//
+// Deprecated: use AddAfterHook, which lets several hooks coexist.
+func (s *scheduler) SetAfter(h Hook) {
+	s.setHook(&s.afterTick, &s.legacyAfter, h)
+}
+
+// Replace the hook of list held in set by h, nil removing it.
+func (s *scheduler) setHook(list *[]*HookHandle, set **HookHandle, h Hook) {
+	s.lockhooks.Lock()
+	defer s.lockhooks.Unlock()
+
+	if *set != nil {
+		(*set).unlink()
+		*set = nil
+	}
+	if h != nil {
+		*set = s.linkHook(list, h)
+	}
+}
+
+// Run the hooks of list in order, recording the duration of each call in trace.
+func (s *scheduler) runHooks(list *[]*HookHandle, trace *TaskTracer) {
+	s.lockhooks.Lock()
+	hooks := *list
+	s.lockhooks.Unlock()
+
+	for _, h := range hooks {
+		s.runHook(h.hook, trace)
+	}
+}
+
+// Run a hook, either in place or on the hook goroutine, recording its duration in trace.
+func (s *scheduler) runHook(h Hook, trace *TaskTracer) {
+	if h == nil {
+		return
+	}
+	s.lockhooks.Lock()
+	if s.hookQueue != nil {
+		select {
+		case s.hookQueue <- tracedHook{hook: h, trace: trace}:
+		default:
+			s.hookDrops.Add(1)
+		}
+		s.lockhooks.Unlock()
+		return
+	}
+	s.lockhooks.Unlock()
+	s.callHook(h, trace)
+}
+
+// WithHookTimeout sets the maximum duration of a hook. A hook running longer is abandoned, it keeps running on its
+// own goroutine but the scheduler no longer waits for it, and an EventHookAbandoned event is emitted.
+// A 0 or negative duration means hooks are waited for without limit, which is the default.
+func WithHookTimeout(d time.Duration) Option {
+	return func(s *scheduler) {
+		s.hookLimit.Store(int64(d))
+	}
+}
+
+// Call a hook, guarded against panics and, if set, against timeouts.
+// A hook that panics or times out is abandoned and an event is emitted.
+// The duration waited for the hook is recorded in trace.
+func (s *scheduler) callHook(h Hook, trace *TaskTracer) {
+	start := s.now()
+	defer func() { trace.recordRun(start, s.since(start), nil) }()
+
+	limit := time.Duration(s.hookLimit.Load())
+	if limit <= 0 {
+		if err := s.safeHook(h); err != nil {
+			s.emit(Event{Kind: EventHookAbandoned, Tick: s.Ticks(), Err: err})
+		}
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.safeHook(h) }()
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			s.emit(Event{Kind: EventHookAbandoned, Tick: s.Ticks(), Err: err})
+		}
+	case <-timer.C:
+		s.emit(Event{Kind: EventHookAbandoned, Tick: s.Ticks(), Err: fmt.Errorf("%w after %v", ErrHookTimeout, limit)})
+	}
+}
+
+// Call a hook, converting a panic into an error.
+func (s *scheduler) safeHook(h Hook) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("hook panicked: %v", r)
+		}
+	}()
+	h(s)
+	return nil
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// StopReasonContext is the stop reason of a scheduler stopped because its context was done.
+const StopReasonContext = "context done"
+
+// StartContext starts s with the given tick duration, and stops it as soon as ctx is done.
+// It suits short-lived schedulers bound to a request or a session.
+func StartContext(ctx context.Context, s Scheduler, duration time.Duration) {
+	s.Start(duration)
+	go func() {
+		<-ctx.Done()
+		if ss, ok := s.(*scheduler); ok {
+			ss.stop(StopReasonContext)
+		} else {
+			s.Stop()
+		}
+	}()
+}
+
+// key of the request-scoped scheduler in the request context
+type contextKey struct{}
+
+// RequestScoped is an http middleware providing each request with its own scheduler, ticking every duration.
+// Handlers retrieve it with FromContext(r.Context()) and add their tasks, such as progress polling during a
+// long request. The scheduler is stopped when the handler returns or the request context is cancelled.
+func RequestScoped(duration time.Duration, next http.Handler, opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		s := New(opts...)
+		StartContext(ctx, s, duration)
+		defer s.Stop()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, contextKey{}, s)))
+	})
+}
+
+// FromContext returns the scheduler attached to ctx by RequestScoped, if any.
+func FromContext(ctx context.Context) (Scheduler, bool) {
+	s, ok := ctx.Value(contextKey{}).(Scheduler)
+	return s, ok
+}
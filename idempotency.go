@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// RunStore is a Store also recording the runs of the tasks with run keys, see Spec.RunKeys.
+// Each run of such a task has an idempotency key, recorded before the run and marked done after it.
+// A key already recorded is not run again : after a restart resuming from an older checkpoint, runs that already
+// happened, or that were interrupted before their completion was recorded, are not executed twice.
+type RunStore interface {
+	Store
+	// Record the start of the run identified by key. It returns false if key was already recorded.
+	BeginRun(key string) (bool, error)
+	// Record the completion of the run identified by key, successful or not.
+	EndRun(key string) error
+	// List the keys of the runs started and not completed, whose outcome is unknown.
+	PendingRuns() ([]string, error)
+}
+
+// Record the start of a run of e, if it has run keys and the store records runs.
+// It returns the key of the run, empty if none, and false if the run already happened.
+// If the key cannot be recorded, the task runs anyway : execution is at least once.
+func (s *scheduler) beginRun(e *entry) (string, bool) {
+	if !e.keyed || e.name == "" {
+		return "", true
+	}
+	rs, ok := s.getStore().(RunStore)
+	if !ok {
+		return "", true
+	}
+	s.locktasks.Lock()
+	e.runs++
+	key := fmt.Sprintf("%s#%d", e.name, e.runs)
+	s.locktasks.Unlock()
+
+	fresh, err := rs.BeginRun(key)
+	if err != nil {
+		s.log(slog.LevelError, "unable to record run, running anyway", "key", key, "error", err)
+		return "", true
+	}
+	return key, fresh
+}
+
+// Record the completion of a run started by beginRun.
+func (s *scheduler) endRun(key string) {
+	if key == "" {
+		return
+	}
+	if rs, ok := s.getStore().(RunStore); ok {
+		if err := rs.EndRun(key); err != nil {
+			s.log(slog.LevelError, "unable to record completion of run", "key", key, "error", err)
+		}
+	}
+}
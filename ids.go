@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// TaskID identifies a registration of a task, whatever the task value : the IDs are unique within the process and
+// increasing, even if the same task is added several times, or to several schedulers, or if its type is not comparable.
+type TaskID uint64
+
+// Last ID given to a registration, by any scheduler.
+var lastID atomic.Uint64
+
+// String is the printed value of the ID.
+func (id TaskID) String() string {
+	return fmt.Sprintf("#%d", uint64(id))
+}
+
+// unsafe registration of a new entry : it gets an ID, unless it has one, is wrapped with the middleware,
+// traced if the scheduler traces all tasks, and the watchers are notified.
+func (s *scheduler) register(e *entry) {
+	if e.id == 0 {
+		e.id = TaskID(lastID.Add(1))
+	}
+	if e.run == nil {
+		s.wrap(e)
+	}
+	if e.metrics == nil {
+		e.metrics = &taskMetrics{}
+	}
+	s.traced(e)
+	s.changed(ChangeAdded, e)
+}
+
+// Remove the registration identified by id, returning false if there is none.
+func (s *scheduler) RemoveID(id TaskID) bool {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	var found *entry
+	s.each(func(e *entry) {
+		if e.id == id {
+			found = e
+		}
+	})
+	if found == nil {
+		return false
+	}
+	s.removeEntry(found)
+	return true
+}
+
+// Get the ID of the registration.
+func (h *TaskHandle) ID() TaskID {
+	return h.e.id
+}
@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// TaskInfo describes a registration, when it runs next, and its last run.
+type TaskInfo struct {
+	ID       TaskID    `json:"id"`             // identity of the registration
+	Name     string    `json:"name,omitempty"` // name of the task, if any
+	Task     Task      `json:"-"`              // registered task
+	Period   int       `json:"period"`         // period in ticks, 0 for one-shot and cron tasks
+	Slot     int       `json:"slot"`           // tick within the period a periodic task runs at, see Offset
+	NextTick int       `json:"nextTick"`       // next tick the task runs at
+	NextTime time.Time `json:"nextTime"`       // estimated time of the next run, zero if the tick duration is unknown
+
+	Runs         int64         `json:"runs"`                // number of runs
+	LastRun      time.Time     `json:"lastRun"`             // start of the last run, zero if none
+	LastDuration time.Duration `json:"lastDuration"`        // duration of the last run
+	LastError    string        `json:"lastError,omitempty"` // error of the last run, empty if it succeeded
+}
+
+// List all the registrations, periodic, one-shot and cron, with their schedule and their last run, so that an admin
+// UI or a CLI can display the full schedule. They are sorted by period, then in the order they were added.
+func (s *scheduler) List() []TaskInfo {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	infos := []TaskInfo{}
+	s.each(func(e *entry) {
+		infos = append(infos, s.info(e))
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Period != infos[j].Period {
+			return infos[i].Period < infos[j].Period
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// List the registrations that would run at tick, in the order they would run : periodic tasks, taking the runs
+// skipped after errors into account, and one-shot tasks. Paused tasks and tasks not runnable in the current modes
+// are not listed, nor cron tasks, that depend on the time of the tick. Past ticks list nothing.
+func (s *scheduler) Due(tick int) []TaskInfo {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	now := s.ticks + s.inflight
+	if tick < now {
+		return nil
+	}
+	var due []*entry
+	for _, p := range s.periods() {
+		for i, e := range s.tasks[p] {
+			next := now + e.due(i, now)
+			if s.runnable(e) && tick >= next && (tick-next)%p == 0 {
+				due = append(due, e)
+			}
+		}
+	}
+	for _, e := range s.once[tick] {
+		if s.runnable(e) {
+			due = append(due, e)
+		}
+	}
+	byPriority(due)
+
+	infos := make([]TaskInfo, len(due))
+	for i, e := range due {
+		infos[i] = s.info(e)
+		infos[i].NextTick, infos[i].NextTime = tick, s.tickTime(tick)
+	}
+	return infos
+}
+
+// Get when t runs next : the earliest of its registrations, periodic, one-shot or cron. It returns false if t is not
+// scheduled. The next tick of a cron task is estimated from the tick duration.
+func (s *scheduler) NextRun(t Task) (TaskInfo, bool) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	var infos []TaskInfo
+	for _, e := range s.registrations(t) {
+		infos = append(infos, s.info(e))
+	}
+	if len(infos) == 0 {
+		return TaskInfo{}, false
+	}
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].NextTick < infos[j].NextTick })
+	return infos[0], true
+}
+
+// unsafe description of an entry, with its next and last runs.
+func (s *scheduler) info(e *entry) TaskInfo {
+	info := TaskInfo{
+		ID:           e.id,
+		Name:         e.name,
+		Task:         e.task,
+		Period:       e.period,
+		Runs:         e.outcomes.successes + e.outcomes.failures,
+		LastRun:      e.outcomes.lastRun,
+		LastDuration: e.outcomes.lastDuration,
+	}
+	if e.outcomes.lastErr != nil {
+		info.LastError = e.outcomes.lastErr.Error()
+	}
+	now := s.ticks + s.inflight
+	if i := s.index(e); i >= 0 {
+		o := s.offset(e, i)
+		info.Slot, info.NextTick = o.Slot, o.NextTick
+		info.NextTime = s.tickTime(o.NextTick)
+		return info
+	}
+	for at, v := range s.once {
+		for _, ee := range v {
+			if ee == e {
+				info.NextTick, info.NextTime = at, s.tickTime(at)
+				return info
+			}
+		}
+	}
+	for _, c := range s.crons {
+		if c.entry == e {
+			info.NextTick, info.NextTime = now, c.next
+			if d := s.tickDuration(); d > 0 {
+				info.NextTick += max(int((c.next.Sub(s.now())+d-1)/d), 0)
+			}
+		}
+	}
+	return info
+}
+
+// unsafe estimate of the time tick runs at, zero if the tick duration is unknown.
+func (s *scheduler) tickTime(tick int) time.Time {
+	d := s.tickDuration()
+	if d <= 0 {
+		return time.Time{}
+	}
+	return s.now().Add(time.Duration(tick-s.ticks-s.inflight+1) * d)
+}
+
+// Duration of the ticks, 0 if unknown.
+func (s *scheduler) tickDuration() time.Duration {
+	s.lockstats.RLock()
+	defer s.lockstats.RUnlock()
+
+	return s.duration
+}
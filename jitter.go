@@ -0,0 +1,28 @@
+package scheduler
+
+import "math/rand"
+
+// WithJitter shifts the phase of each periodic task by a random number of ticks, between 0 and maxTicks,
+// when it is added. Schedulers created with the same tasks then spread their runs instead of firing
+// simultaneously against shared resources. Tasks added with an explicit offset or their own jitter are not affected.
+// A value of 0 or less disables jitter, which is the default.
+func WithJitter(maxTicks int) Option {
+	return func(s *scheduler) {
+		s.jitter = max(maxTicks, 0)
+	}
+}
+
+// unsafe draw of the phase of an entry with jitter, added to the tasks of its period.
+// Entries without a fixed offset are shifted from the position they would have had, the others from their offset.
+func (s *scheduler) applyJitter(e *entry) {
+	if e.jitter == 0 && !e.fixed { // explicit offsets are deliberate
+		e.jitter = s.jitter
+	}
+	if e.jitter <= 0 {
+		return
+	}
+	if !e.fixed {
+		e.fixed, e.offset = true, len(s.tasks[e.period])
+	}
+	e.offset = phase(e.offset+rand.Intn(e.jitter+1), e.period)
+}
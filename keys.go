@@ -0,0 +1,60 @@
+package scheduler
+
+import "context"
+
+// Add tasks sheduled to run every 'period' ticks, that never run simultaneously with the other tasks sharing
+// the same concurrency key : with WithConcurrency, or when ticks overlap, a task waits for the run of another
+// task with the same key to finish, while unrelated tasks run in parallel.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddWithKey(period int, key string, t ...Task) {
+	if period <= 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, key: key})
+	}
+}
+
+// Get the lock of the concurrency key of an entry, nil if the entry has no key.
+// The lock is a channel holding a value while a run holds the key.
+func (s *scheduler) keyLock(e *entry) chan struct{} {
+	if e.key == "" {
+		return nil
+	}
+	s.lockkeys.Lock()
+	defer s.lockkeys.Unlock()
+
+	l, ok := s.keys[e.key]
+	if !ok {
+		l = make(chan struct{}, 1)
+		s.keys[e.key] = l
+	}
+	return l
+}
+
+// keyedTask runs a task holding its concurrency key, acquired when the run starts and released when it returns.
+// The run gives up waiting for the key once its context is done, such as on timeout, and a run abandoned on timeout
+// keeps the key until it actually returns.
+type keyedTask struct {
+	task Task
+	key  chan struct{}
+}
+
+func (k keyedTask) Run() error {
+	k.key <- struct{}{}
+	defer func() { <-k.key }()
+	return k.task.Run()
+}
+
+func (k keyedTask) RunContext(ctx context.Context) error {
+	select {
+	case k.key <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-k.key }()
+	return runTask(ctx, k.task)
+}
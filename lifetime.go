@@ -0,0 +1,40 @@
+package scheduler
+
+import "time"
+
+// Reasons for a scheduler stop.
+const (
+	StopReasonStopped   = "stopped"   // Stop was called
+	StopReasonCompleted = "completed" // RunFor ran all its ticks
+	StopReasonHandoff   = "handoff"   // Handoff transferred the schedule to another scheduler
+)
+
+// Lifetime accounts for the whole life of a scheduler, across restarts.
+type Lifetime struct {
+	Starts     int           // number of times the scheduler was started
+	Uptime     time.Duration // cumulative running time, including the current run
+	Running    bool          // scheduler currently running
+	StopReason string        // reason of the last stop, empty if never stopped
+}
+
+// Get the lifetime counters of the scheduler, accumulated across restarts.
+func (s *scheduler) Lifetime() Lifetime {
+	s.lockstats.RLock()
+	defer s.lockstats.RUnlock()
+
+	lt := Lifetime{
+		Starts:     s.starts,
+		Uptime:     s.uptime,
+		Running:    s.running(),
+		StopReason: s.stopReason,
+	}
+	if lt.Running {
+		lt.Uptime += s.actualElapsed()
+	}
+	return lt
+}
+
+// unsafe check that the scheduler is started and not stopped since.
+func (s *scheduler) running() bool {
+	return s.starts > s.stops
+}
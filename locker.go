@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLockTTL is the lock ttl of a task when the tick duration is unknown, such as when stepping.
+const DefaultLockTTL = time.Minute
+
+// Locker coordinates the instances of a multi-instance deployment, so that a single one runs each named task.
+// MemoryLocker and FileLocker are built-in implementations. A Redis or etcd one only needs to set key to owner
+// if it is unset, expired or already owned by owner, such as with a Redis SET NX PX guarded by an owner check.
+type Locker interface {
+	// Try to hold the lock of key for owner during ttl. It returns false if another owner holds it.
+	// The owner holding the lock gets it again, for a new ttl.
+	TryLock(key, owner string, ttl time.Duration) (bool, error)
+}
+
+// WithLocker sets the locker the scheduler acquires the lock of a named task with at each tick it is due, before
+// running it, the name being the key. A run whose lock is held by another instance is skipped, without error. The
+// lock is held by the scheduler until the next run of the task, for its period and one more tick, and renewed at each
+// run, so that the instance running a task keeps running it until it stops. Without a tick duration, such as when
+// stepping, the lock is held DefaultLockTTL. If the lock cannot be checked, the task runs anyway : execution is at least once. Unnamed tasks always run.
+func WithLocker(l Locker) Option {
+	return func(s *scheduler) {
+		s.locker = l
+	}
+}
+
+// Check whether this instance may run e, acquiring its lock if there is a locker.
+func (s *scheduler) elected(e *entry) bool {
+	if e.name == "" {
+		return true
+	}
+	s.lockstore.Lock()
+	l := s.locker
+	s.lockstore.Unlock()
+	if l == nil {
+		return true
+	}
+	s.locktasks.Lock()
+	period := max(e.period, 1)
+	s.locktasks.Unlock()
+	s.lockstats.RLock()
+	ttl := time.Duration(period+1) * s.duration // until the next run, with a tick to spare
+	s.lockstats.RUnlock()
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+
+	ok, err := l.TryLock(e.name, s.instance, ttl)
+	if err != nil {
+		s.log(slog.LevelError, "unable to lock task, running anyway", "task", e.name, "error", err)
+		return true
+	}
+	return ok
+}
+
+// Return a random identity for the instance.
+func newInstance() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MemoryLocker is a Locker for the schedulers of a process, safe for concurrent use.
+type MemoryLocker struct {
+	lock  sync.Mutex
+	locks map[string]memoryLock
+	now   func() time.Time
+}
+
+// memoryLock is a lock held in a MemoryLocker.
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+var _ Locker = &MemoryLocker{}
+
+// Return an empty MemoryLocker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: map[string]memoryLock{}, now: time.Now}
+}
+
+func (m *MemoryLocker) TryLock(key, owner string, ttl time.Duration) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	if l, ok := m.locks[key]; ok && l.owner != owner && now.Before(l.expires) {
+		return false, nil
+	}
+	m.locks[key] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// FileLocker is a Locker for the instances sharing a directory, such as on a single host or a shared volume
+// supporting flock. Each lock is a file named after its key, holding its owner and expiry, read and updated under
+// an exclusive file lock, so that a single process holds it at a time. It is safe for concurrent use. File locks are
+// not supported on all the platforms, where TryLock fails with errors.ErrUnsupported.
+type FileLocker struct {
+	lock sync.Mutex
+	dir  string
+}
+
+var _ Locker = &FileLocker{}
+
+// Return a FileLocker keeping its lock files in dir, which must exist.
+func NewFileLocker(dir string) *FileLocker {
+	return &FileLocker{dir: dir}
+}
+
+func (f *FileLocker) TryLock(key, owner string, ttl time.Duration) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	path := filepath.Join(f.dir, url.PathEscape(key)+".lock")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close() // releases the file lock
+	if err := lockFile(file); err != nil {
+		return false, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if len(data) > 0 { // empty when created
+		holder, expiry, _ := strings.Cut(string(data), " ")
+		expires, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid lock file %s : %w", path, err)
+		}
+		if holder != owner && now.UnixNano() < expires {
+			return false, nil // held by another owner
+		}
+	}
+	content := []byte(owner + " " + strconv.FormatInt(now.Add(ttl).UnixNano(), 10))
+	if err := file.Truncate(0); err != nil {
+		return false, err
+	}
+	if _, err := file.WriteAt(content, 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
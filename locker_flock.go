@@ -0,0 +1,18 @@
+//go:build darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd
+
+package scheduler
+
+import (
+	"os"
+	"syscall"
+)
+
+// Hold an exclusive lock on file, shared by all the processes, until it is closed.
+func lockFile(file *os.File) error {
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd)
+
+package scheduler
+
+import (
+	"errors"
+	"os"
+)
+
+// File locks are not supported on this platform.
+func lockFile(file *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// logState holds the logger set with SetLogger, nil to disable logging.
+type logState struct {
+	logger *slog.Logger
+}
+
+// Set the structured logger of the scheduler. A nil logger disables logging entirely.
+// Until it is set, the default slog logger is used.
+// Ticks are logged at debug level, start, stop and removed tasks at info level,
+// failed runs, overruns and abandoned tasks at warn level, and internal failures at error level.
+func (s *scheduler) SetLogger(l *slog.Logger) {
+	s.logs.Store(&logState{logger: l})
+}
+
+// Return the logger in use, nil if logging is disabled.
+func (s *scheduler) logger() *slog.Logger {
+	st := s.logs.Load()
+	if st == nil {
+		return slog.Default()
+	}
+	return st.logger
+}
+
+// Log msg with the key-value pairs of args, if the logger is enabled at level.
+func (s *scheduler) log(level slog.Level, msg string, args ...any) {
+	l := s.logger()
+	if l == nil || !l.Enabled(context.Background(), level) {
+		return
+	}
+	if s.name != "" {
+		args = append(args, "scheduler", s.name)
+	}
+	l.Log(context.Background(), level, msg, args...)
+}
+
+// Log the failed runs of a tick, and the entries removed by their error policy.
+func (s *scheduler) logRuns(run *tickRun, decisions map[*entry]decision) {
+	if s.logger() == nil {
+		return
+	}
+	for e, err := range run.errs {
+		s.log(slog.LevelWarn, "task failed", "task", e.label(), "tick", run.tick, "error", err)
+	}
+	for e, d := range decisions {
+		if d.remove {
+			s.log(slog.LevelInfo, "task removed", "task", e.label(), "tick", run.tick, "error", run.errs[e])
+		}
+	}
+}
+
+// Log the end of a tick, and whether it overran.
+func (s *scheduler) logTick(tick int, run *tickRun, busy, before, after, duration time.Duration, overran bool) {
+	if overran {
+		s.log(slog.LevelWarn, "tick overrun", "tick", tick, "busy", busy, "duration", duration)
+	}
+	s.log(slog.LevelDebug, "tick end", "tick", tick, "runs", len(run.ran), "errors", len(run.errs), "busy", busy,
+		"before", before, "after", after)
+}
+
+// Name of the entry for logs and dead letters : its name, or its printed task.
+func (e *entry) label() string {
+	if e.name != "" {
+		return e.name
+	}
+	return fmt.Sprint(e.task)
+}
@@ -0,0 +1,41 @@
+package scheduler
+
+// Add exempt tasks sheduled to run every 'period' ticks. Exempt tasks keep running in maintenance mode.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddExempt(period int, t ...Task) {
+	if period <= 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, exempt: true})
+	}
+}
+
+// Enter maintenance mode : ticks and statistics go on, but only exempt tasks run.
+// One-shot tasks due during maintenance are postponed until it ends. Use it for deploy windows,
+// instead of tearing the scheduler down.
+func (s *scheduler) EnterMaintenance() {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	s.maintenance = true
+}
+
+// Leave maintenance mode, all tasks run again.
+func (s *scheduler) ExitMaintenance() {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	s.maintenance = false
+}
+
+// Check whether the scheduler is in maintenance mode.
+func (s *scheduler) Maintenance() bool {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	return s.maintenance
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Middleware wraps a task with a cross-cutting concern, such as logging, retries or panic recovery, returning
+// the task run instead. Like HTTP middleware, it should call the wrapped task, with RunContext if it is a TaskCtx.
+type Middleware func(Task) Task
+
+// Wrap the tasks added from now on with the middleware, in order : the first one is the outermost, and the
+// middleware of a previous Use wraps the ones given here. Tasks already added are not wrapped.
+// The wrapper is built once, when the task is added. The task itself remains the registered one,
+// for Remove, the handles and the statistics.
+func (s *scheduler) Use(mw ...Middleware) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, m := range mw {
+		if m != nil {
+			s.middleware = append(s.middleware, m)
+		}
+	}
+}
+
+// unsafe wrapping of the task of e, done at registration.
+func (s *scheduler) wrap(e *entry) {
+	if len(s.middleware) == 0 {
+		return
+	}
+	var t Task = e.task
+	if e.commit {
+		t = commitRunner{task: e.task.(CommitTask)}
+	}
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		t = s.middleware[i](t)
+	}
+	e.run = t
+}
+
+// commitRunner runs a CommitTask through a new committer for each run, so that middleware wrapping it once
+// still checks each run commits.
+type commitRunner struct {
+	task CommitTask
+}
+
+func (c commitRunner) Run() error {
+	return c.RunContext(context.Background())
+}
+
+func (c commitRunner) RunContext(ctx context.Context) error {
+	return (&committer{task: c.task}).RunContext(ctx)
+}
+
+func (c commitRunner) String() string {
+	return fmt.Sprint(c.task)
+}
+
+// Recover is a Middleware converting a panic of the task into an error.
+func Recover(t Task) Task {
+	return TaskCtxFunc(func(ctx context.Context) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task %v panicked: %v", t, r)
+			}
+		}()
+		return runTask(ctx, t)
+	})
+}
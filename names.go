@@ -0,0 +1,107 @@
+package scheduler
+
+import "sort"
+
+// ErrDuplicateName is returned when adding a task under a name already in use. It matches ErrExists.
+var ErrDuplicateName = newError("task name already in use", ErrExists)
+
+// Add a task under a unique name, sheduled to run every 'period' ticks.
+// Named tasks can be found and removed by name, without holding the original Task value.
+// Names are released when their task is removed.
+func (s *scheduler) AddNamed(name string, period int, t Task) error {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	_, err := s.addSpec(Spec{Name: name, Period: period, Task: t})
+	return err
+}
+
+// Get the task registered under name.
+func (s *scheduler) Lookup(name string) (Task, bool) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	if e := s.named(name); e != nil {
+		return e.task, true
+	}
+	return nil, false
+}
+
+// Remove the task registered under name, returning false if there is none.
+func (s *scheduler) RemoveByName(name string) bool {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := s.named(name)
+	if e == nil {
+		return false
+	}
+	s.removeEntry(e)
+	return true
+}
+
+// Pause the runs of the task registered under name, returning false if there is none.
+func (s *scheduler) PauseByName(name string) bool {
+	return s.pauseNamed(name, true)
+}
+
+// Resume the runs of the task registered under name, returning false if there is none.
+func (s *scheduler) ResumeByName(name string) bool {
+	return s.pauseNamed(name, false)
+}
+
+// Set whether the runs of the task registered under name are paused, returning false if there is none.
+func (s *scheduler) pauseNamed(name string, paused bool) bool {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := s.named(name)
+	if e == nil {
+		return false
+	}
+	e.paused = paused
+	return true
+}
+
+// Get the sorted names of the named tasks.
+func (s *scheduler) Names() []string {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	names := []string{}
+	s.each(func(e *entry) {
+		if e.name != "" {
+			names = append(names, e.name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+// unsafe lookup of the entry registered under name, nil if none.
+// The empty name never matches.
+func (s *scheduler) named(name string) (found *entry) {
+	if name == "" {
+		return nil
+	}
+	s.each(func(e *entry) {
+		if e.name == name {
+			found = e
+		}
+	})
+	return found
+}
+
+// unsafe iteration over all the entries : periodic, one-shot and cron scheduled.
+func (s *scheduler) each(fn func(e *entry)) {
+	for _, m := range []map[int][]*entry{s.tasks, s.once} {
+		for _, v := range m {
+			for _, e := range v {
+				fn(e)
+			}
+		}
+	}
+	for _, c := range s.crons {
+		fn(c.entry)
+	}
+}
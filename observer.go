@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Default queue size of an observer.
+const DefaultObserverQueue = 64
+
+// Observer receives scheduler events on its own goroutine.
+type Observer interface {
+	Observe(ev Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(ev Event)
+
+func (f ObserverFunc) Observe(ev Event) {
+	f(ev)
+}
+
+// Observation is the registration of an Observer.
+// Events are queued for the observer, up to the queue size : when the queue is full, events are dropped and
+// counted, so that a slow observer never blocks the tick loop.
+type Observation struct {
+	s       *scheduler     // scheduler the observer is registered in, nil for a Composite
+	parts   []*Observation // observations of the parts of a Composite, feeding this one
+	lock    sync.Mutex
+	queue   chan Event
+	closed  bool
+	dropped atomic.Int64
+	done    chan struct{} // closed once the queued events are delivered
+}
+
+// Register o for the events matching kinds, queued up to queue events.
+// A 0 or negative queue uses DefaultObserverQueue.
+func (s *scheduler) Observe(kinds EventKind, o Observer, queue int) *Observation {
+	obs := newObservation(o, queue)
+	obs.s = s
+
+	s.lockevents.Lock()
+	defer s.lockevents.Unlock()
+
+	s.subscribers = append(s.subscribers, subscriber{kinds: kinds, fn: obs.offer, obs: obs})
+	return obs
+}
+
+// Create an observation delivering the events queued, up to queue, to o.
+// A 0 or negative queue uses DefaultObserverQueue.
+func newObservation(o Observer, queue int) *Observation {
+	if queue <= 0 {
+		queue = DefaultObserverQueue
+	}
+	obs := &Observation{queue: make(chan Event, queue), done: make(chan struct{})}
+	go func() {
+		defer close(obs.done)
+		for ev := range obs.queue {
+			o.Observe(ev)
+		}
+	}()
+	return obs
+}
+
+// Queue an event, or drop it if the queue is full or the observation is closed.
+func (obs *Observation) offer(ev Event) {
+	obs.lock.Lock()
+	defer obs.lock.Unlock()
+
+	if obs.closed {
+		return
+	}
+	select {
+	case obs.queue <- ev:
+	default:
+		obs.dropped.Add(1)
+	}
+}
+
+// Get the number of events dropped because the queue was full.
+func (obs *Observation) Dropped() int {
+	n := int(obs.dropped.Load())
+	for _, p := range obs.parts {
+		n += p.Dropped()
+	}
+	return n
+}
+
+// Unregister the observer, and wait for the queued events to be delivered. Closing it again does nothing.
+func (obs *Observation) Close() {
+	for _, p := range obs.parts {
+		p.Close()
+	}
+	if s := obs.s; s != nil {
+		s.lockevents.Lock()
+		subs := make([]subscriber, 0, len(s.subscribers)) // emit may still iterate over the previous slice
+		for _, sub := range s.subscribers {
+			if sub.obs != obs {
+				subs = append(subs, sub)
+			}
+		}
+		s.subscribers = subs
+		s.lockevents.Unlock()
+	}
+
+	obs.lock.Lock()
+	if !obs.closed {
+		obs.closed = true
+		close(obs.queue)
+	}
+	obs.lock.Unlock()
+	<-obs.done
+}
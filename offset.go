@@ -0,0 +1,84 @@
+package scheduler
+
+import "sort"
+
+// Add a task sheduled to run every 'period' ticks, on the ticks equal to offset modulo period.
+// Tasks added otherwise run at their position among the tasks of the same period, which spreads them
+// evenly but leaves their phase implicit. An explicit offset staggers heavy tasks deliberately.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddWithOffset(period, offset int, t Task) *TaskHandle {
+	if period <= 0 {
+		return nil
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := &entry{task: t, fixed: true, offset: phase(offset, period)}
+	s.addEntry(period, e)
+	return &TaskHandle{s: s, e: e}
+}
+
+// Normalize an offset within a period, in [0, period).
+func phase(offset, period int) int {
+	if period <= 0 {
+		return 0
+	}
+	return (offset%period + period) % period
+}
+
+// Offset describes when a registration of a periodic task runs.
+type Offset struct {
+	Period   int  `json:"period"`   // period in ticks
+	Slot     int  `json:"slot"`     // the task runs on the ticks equal to Slot modulo Period
+	Fixed    bool `json:"fixed"`    // slot set explicitly, see AddWithOffset, instead of the position within the period
+	NextTick int  `json:"nextTick"` // next tick the task runs at, taking the runs skipped after errors into account
+}
+
+// Get the effective offsets of the registrations of a periodic task, in the order they were added within each
+// period. Without an explicit offset, the slot of a task is its position among the tasks of the same period,
+// modulo the period : it changes when tasks added before it in that period are removed.
+func (s *scheduler) Offsets(t Task) []Offset {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	var offsets []Offset
+	for _, p := range s.periods() {
+		for i, e := range s.tasks[p] {
+			if sameTask(e.task, t) {
+				offsets = append(offsets, s.offset(e, i))
+			}
+		}
+	}
+	return offsets
+}
+
+// unsafe offset of the periodic entry at index i of its period.
+func (s *scheduler) offset(e *entry, i int) Offset {
+	o := Offset{Period: e.period, Slot: phase(i, e.period), Fixed: e.fixed}
+	if e.fixed {
+		o.Slot = e.offset
+	}
+	tick := s.ticks + s.inflight
+	o.NextTick = tick + e.due(i, tick)
+	return o
+}
+
+// unsafe index of a periodic entry within its period, -1 if it is not scheduled.
+func (s *scheduler) index(e *entry) int {
+	for i, ee := range s.tasks[e.period] {
+		if ee == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// unsafe sorted list of the periods of the periodic tasks.
+func (s *scheduler) periods() []int {
+	periods := make([]int, 0, len(s.tasks))
+	for p := range s.tasks {
+		periods = append(periods, p)
+	}
+	sort.Ints(periods)
+	return periods
+}
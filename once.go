@@ -0,0 +1,49 @@
+package scheduler
+
+import "time"
+
+// ErrNoDuration is returned when scheduling at a time before the tick duration is known. It matches ErrNotRunning.
+var ErrNoDuration = newError("tick duration unknown, start the scheduler first", ErrNotRunning)
+
+// Run a task a single time, after delayTicks ticks : 0 runs it at the next tick, 1 at the one after, ...
+// The task is deregistered once it has run, whatever its error. Negative delays are treated as 0.
+func (s *scheduler) RunOnce(delayTicks int, t Task) {
+	s.RunAt(s.Ticks()+max(delayTicks, 0), t)
+}
+
+// Run a task a single time, at the given tick, the first tick being 0.
+// If that tick has already passed, the task runs at the next tick.
+// The task is deregistered once it has run, whatever its error.
+func (s *scheduler) RunAt(tick int, t Task) {
+	tick = max(tick, s.Ticks())
+
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := &entry{task: t, outcomes: outcomes{recent: newRing(DefaultRateWindow)}}
+	s.register(e)
+	s.once[tick] = append(s.once[tick], e)
+}
+
+// Run a task a single time, at the first tick happening at or after t.
+// The tick is computed from the current tick duration : the task runs no earlier than t, and at most about one
+// tick duration late, unless ticks are delayed by overruns or the duration changes meanwhile.
+// A time already passed runs the task at the next tick.
+// It returns ErrNoDuration if the scheduler was never started, the tick duration being unknown.
+func (s *scheduler) ScheduleAt(t time.Time, task Task) error {
+	return s.ScheduleAfter(t.Sub(s.now()), task)
+}
+
+// Run a task a single time, at the first tick happening d from now or later. See ScheduleAt.
+func (s *scheduler) ScheduleAfter(d time.Duration, task Task) error {
+	s.lockstats.RLock()
+	duration := s.duration
+	s.lockstats.RUnlock()
+	if duration <= 0 {
+		return ErrNoDuration
+	}
+	// the next tick happens within a duration, the n-th one after it at least n durations from now
+	n := (max(d, 0) + duration - 1) / duration
+	s.RunOnce(int(n), task)
+	return nil
+}
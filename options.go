@@ -0,0 +1,76 @@
+package scheduler
+
+import "log/slog"
+
+// Option configures a scheduler at creation.
+type Option func(s *scheduler)
+
+// WithConcurrency runs the tasks due on the same tick concurrently, on up to n workers.
+// Tasks are dispatched in the order they would run serially: by increasing period, then in their
+// order within the period. A task starts only once the tasks before it have started, but it may finish
+// before them. The tick ends when all its tasks are finished.
+// The workers are shared by the ticks overlapping with OverrunRunConcurrently : when they are all busy, waiting
+// tasks get the next free worker by decreasing priority, whatever their tick.
+// A value of 0 or 1 runs the tasks serially, which is the default.
+func WithConcurrency(n int) Option {
+	return func(s *scheduler) {
+		s.workers = max(n, 0)
+	}
+}
+
+// WithName names the scheduler. The name is reported in its Status, and added to its log records.
+func WithName(name string) Option {
+	return func(s *scheduler) {
+		s.name = name
+	}
+}
+
+// WithLogger sets the structured logger of the scheduler, see SetLogger.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *scheduler) {
+		s.SetLogger(l)
+	}
+}
+
+// WithErrorPolicy sets the error policy of the scheduler, see SetErrorPolicy.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(s *scheduler) {
+		s.SetErrorPolicy(policy)
+	}
+}
+
+// WithErrorHandler sets the handler called for every failed run, see SetErrorHandler.
+func WithErrorHandler(h func(t Task, err error)) Option {
+	return func(s *scheduler) {
+		s.SetErrorHandler(h)
+	}
+}
+
+// WithHooks adds hooks run before and after each tick, see AddBeforeHook and AddAfterHook. A nil hook is not added.
+func WithHooks(before, after Hook) Option {
+	return func(s *scheduler) {
+		s.AddBeforeHook(before)
+		s.AddAfterHook(after)
+	}
+}
+
+// WithOverrunPolicy sets what happens when ticks overrun, see SetOverrunPolicy.
+func WithOverrunPolicy(p OverrunPolicy) Option {
+	return func(s *scheduler) {
+		s.SetOverrunPolicy(p)
+	}
+}
+
+// WithStore attaches a store to the scheduler, see SetStore.
+func WithStore(st Store) Option {
+	return func(s *scheduler) {
+		s.SetStore(st)
+	}
+}
+
+// WithSpanTracer sets the tracer starting the spans of ticks and task runs, see SetSpanTracer.
+func WithSpanTracer(t SpanTracer) Option {
+	return func(s *scheduler) {
+		s.SetSpanTracer(t)
+	}
+}
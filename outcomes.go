@@ -0,0 +1,110 @@
+package scheduler
+
+import "time"
+
+// Default number of runs over which the success rate is computed.
+const DefaultRateWindow = 100
+
+// Outcomes counts the successful and failed runs of a task.
+type Outcomes struct {
+	Successes int64   // total successful runs
+	Failures  int64   // total failed runs
+	Rate      float64 // success rate over the last DefaultRateWindow runs, 1 before the first run
+}
+
+// outcomes tracks the runs outcomes of an entry.
+type outcomes struct {
+	successes int64
+	failures  int64
+	recent    ring // success of the last runs
+
+	lastRun      time.Time     // start of the last run, zero if none
+	lastDuration time.Duration // duration of the last run
+	lastErr      error         // error of the last run, nil if it succeeded
+}
+
+// record a run outcome
+func (o *outcomes) add(success bool) {
+	if success {
+		o.successes++
+	} else {
+		o.failures++
+	}
+	o.recent.add(success)
+}
+
+// record the time, duration and error of the last run
+func (o *outcomes) last(start time.Time, d time.Duration, err error) {
+	o.lastRun, o.lastDuration, o.lastErr = start, d, err
+}
+
+// Get the success rate of a task over its last runs.
+// If the task was added several times, the rates of its registrations are averaged.
+// It returns false if the task is not scheduled.
+func (s *scheduler) SuccessRate(t Task) (float64, bool) {
+	o, ok := s.Outcomes(t)
+	return o.Rate, ok
+}
+
+// Get the success and failure counts of a task, together with its success rate over its last runs.
+// If the task was added several times, the counts of its registrations are summed.
+// It returns false if the task is not scheduled.
+func (s *scheduler) Outcomes(t Task) (Outcomes, bool) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	var o Outcomes
+	nb := 0
+	for _, v := range s.tasks {
+		for _, e := range v {
+			if sameTask(e.task, t) {
+				o.Successes += e.outcomes.successes
+				o.Failures += e.outcomes.failures
+				o.Rate += e.outcomes.recent.ratio()
+				nb++
+			}
+		}
+	}
+	if nb == 0 {
+		return Outcomes{}, false
+	}
+	o.Rate /= float64(nb)
+	return o, true
+}
+
+// ring is a fixed size window over the last boolean outcomes.
+type ring struct {
+	runs  []bool // ring buffer
+	next  int    // next position in runs
+	count int    // number of outcomes recorded, up to len(runs)
+	met   int    // number of true outcomes in the window
+}
+
+// Create a ring over the last n outcomes.
+func newRing(n int) ring {
+	return ring{runs: make([]bool, max(n, 1))}
+}
+
+// record an outcome, forgetting the oldest one if the window is full.
+func (r *ring) add(ok bool) {
+	if r.count == len(r.runs) {
+		if r.runs[r.next] {
+			r.met--
+		}
+	} else {
+		r.count++
+	}
+	r.runs[r.next] = ok
+	r.next = (r.next + 1) % len(r.runs)
+	if ok {
+		r.met++
+	}
+}
+
+// ratio of true outcomes in the window, 1 if empty.
+func (r *ring) ratio() float64 {
+	if r.count == 0 {
+		return 1
+	}
+	return float64(r.met) / float64(r.count)
+}
@@ -0,0 +1,49 @@
+package scheduler
+
+import "time"
+
+// OverrunPolicy decides what happens when the tasks of a tick take longer than the tick duration.
+type OverrunPolicy int32
+
+const (
+	// The next tick starts as soon as the overrunning one ends, further missed ticks are lost.
+	// This is the default.
+	OverrunQueue OverrunPolicy = iota
+	// The tick missed while overrunning is skipped, the next tick happens at the next tick date.
+	OverrunSkip
+	// Ticks start on time, running concurrently with the overrunning ones.
+	// Each tick still runs its own tasks, but a task with a short period may run concurrently with itself.
+	OverrunRunConcurrently
+	// Tasks of a tick not started once the tick duration is exceeded are not run in this tick.
+	// Tasks already running are not interrupted.
+	OverrunAbort
+)
+
+// Set the overrun policy. The policy survives a restart of the scheduler.
+func (s *scheduler) SetOverrunPolicy(p OverrunPolicy) {
+	s.overrun.Store(int32(p))
+}
+
+// Get the number of ticks whose tasks took longer than the tick duration, since start.
+func (s *scheduler) Overruns() int {
+	return int(s.overruns.Load())
+}
+
+// Set a Hook executed after every overrunning tick. nil removes it.
+// An EventOverrun event is also emitted for each overrun.
+func (s *scheduler) OnOverrun(h Hook) {
+	s.lockhooks.Lock()
+	defer s.lockhooks.Unlock()
+
+	s.overrunHook = h
+}
+
+// Signal a tick that lasted busy, longer than the tick duration.
+func (s *scheduler) signalOverrun(tick int, busy time.Duration) {
+	s.emit(Event{Kind: EventOverrun, Tick: tick, Duration: busy})
+
+	s.lockhooks.Lock()
+	h := s.overrunHook
+	s.lockhooks.Unlock()
+	s.runHook(h, s.overrunTrace)
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import "sync"
+
+// Owner owns tasks, that are removed from the scheduler when its Done channel is closed.
+// Any context.Context is an Owner. Components with a Close method can embed a Token.
+type Owner interface {
+	Done() <-chan struct{}
+}
+
+// Token is an Owner closed explicitly. The zero value is ready to use.
+type Token struct {
+	once sync.Once
+	lock sync.Mutex
+	done chan struct{}
+}
+
+// Return a new token.
+func NewToken() *Token {
+	return &Token{}
+}
+
+// Get the channel closed when the token is closed.
+func (t *Token) Done() <-chan struct{} {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.done == nil {
+		t.done = make(chan struct{})
+	}
+	return t.done
+}
+
+// Close the token, removing the tasks it owns. Closing a closed token does nothing.
+func (t *Token) Close() error {
+	t.once.Do(func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		if t.done == nil {
+			t.done = make(chan struct{})
+		}
+		close(t.done)
+	})
+	return nil
+}
+
+// Add tasks owned by owner, sheduled to run every 'period' ticks.
+// When the owner is done, all its tasks are removed automatically, even if it forgets to clean up.
+// Nothing is added for an owner already done.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddOwned(owner Owner, period int, t ...Task) []*TaskHandle {
+	select {
+	case <-owner.Done():
+		return nil
+	default:
+		return s.owned(owner).Add(period, t...)
+	}
+}
+
+// Get the scope of the tasks of owner, creating it with a goroutine closing it once the owner is done.
+func (s *scheduler) owned(owner Owner) *Scope {
+	s.lockowners.Lock()
+	defer s.lockowners.Unlock()
+
+	if c, ok := s.owners[owner]; ok {
+		return c
+	}
+	c := s.Child()
+	s.owners[owner] = c
+	go func() {
+		<-owner.Done()
+		cs := lockCurrent(&c.s, func(s *scheduler) *sync.Mutex { return &s.lockowners }) // moved by Handoff
+		if cs.owners[owner] == c {
+			delete(cs.owners, owner)
+		}
+		cs.lockowners.Unlock()
+		c.Close()
+	}()
+	return c
+}
@@ -0,0 +1,17 @@
+package scheduler
+
+// Suspend the ticks : the scheduler keeps running, but no task runs and the tick count does not increase until Resume.
+// A tick in progress completes. Pausing a paused scheduler does nothing.
+func (s *scheduler) Pause() {
+	s.paused.Store(true)
+}
+
+// Resume the ticks after a Pause. Resuming a scheduler that is not paused does nothing.
+func (s *scheduler) Resume() {
+	s.paused.Store(false)
+}
+
+// Check whether the ticks are suspended.
+func (s *scheduler) Paused() bool {
+	return s.paused.Load()
+}
@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrUnknownTask is returned when binding a plan spec whose name has no task. It matches ErrTaskNotFound.
+var ErrUnknownTask = newError("no task for spec name", ErrTaskNotFound)
+
+// SchedulePlan is a list of task specs, that can be built, previewed, serialized and applied to any scheduler.
+// Plans marshal to JSON with encoding/json. Tasks and policies are not serialized : an unmarshalled plan
+// gets its tasks back from their names with Bind.
+type SchedulePlan []Spec
+
+// Add all the tasks of the plan to s, or none of them if a spec is rejected.
+func (p SchedulePlan) Apply(s Scheduler) error {
+	return s.ApplyPlan(p)
+}
+
+// Set the task of each spec from its name. Specs that already have a task are kept as is.
+// It returns ErrUnknownTask for the first spec without task whose name is not in tasks.
+func (p SchedulePlan) Bind(tasks map[string]Task) error {
+	for i := range p {
+		if p[i].Task != nil {
+			continue
+		}
+		t, ok := tasks[p[i].Name]
+		if !ok {
+			return fmt.Errorf("%w : spec %d %q", ErrUnknownTask, i, p[i].Name)
+		}
+		p[i].Task = t
+	}
+	return nil
+}
+
+// Return, for each of the next ticks, the indexes of the specs that would run if the plan was applied
+// to an empty scheduler, in the order they would run. Specs with an invalid period are never run.
+// Jitter is random, it is ignored.
+func (p SchedulePlan) Preview(ticks int) [][]int {
+	slots := map[int][]int{} // spec indexes by period, in the order they are added
+	periods := []int{}
+	for i, sp := range p {
+		if sp.Period <= 0 {
+			continue
+		}
+		if _, ok := slots[sp.Period]; !ok {
+			periods = append(periods, sp.Period)
+		}
+		slots[sp.Period] = append(slots[sp.Period], i)
+	}
+	sort.Ints(periods)
+
+	preview := make([][]int, max(ticks, 0))
+	for tick := range preview {
+		run := []int{}
+		for _, per := range periods {
+			for i, j := range slots[per] {
+				slot := i
+				if p[j].FixedOffset {
+					slot = phase(p[j].Offset, per)
+				}
+				if slot%per == tick%per {
+					run = append(run, j)
+				}
+			}
+		}
+		sort.SliceStable(run, func(i, j int) bool { return p[run[i]].Priority > p[run[j]].Priority })
+		preview[tick] = run
+	}
+	return preview
+}
+
+// Add all the tasks of the plan, or none of them if a spec is rejected.
+// The returned error identifies the first rejected spec.
+func (s *scheduler) ApplyPlan(p SchedulePlan) error {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	added := make([]*entry, 0, len(p))
+	for i, sp := range p {
+		e, err := s.addSpec(sp)
+		if err != nil {
+			for _, e := range added { // roll back
+				s.removeEntry(e)
+			}
+			return fmt.Errorf("spec %d : %w", i, err)
+		}
+		added = append(added, e)
+	}
+	return nil
+}
+
+// Builder builds a SchedulePlan with a fluent syntax :
+//
+//	plan := NewBuilder().
+//		Every(5).Named("sync").WithTimeout(time.Second).Do(sync).
+//		Every(60).Named("report").Run(report).
+//		Build()
+//
+// Every starts a new spec, the other methods set the spec being built.
+type Builder struct {
+	specs []Spec
+}
+
+// Return an empty builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Start a new spec running every period ticks.
+func (b *Builder) Every(period int) *Builder {
+	b.specs = append(b.specs, Spec{Period: period})
+	return b
+}
+
+// Name the current spec.
+func (b *Builder) Named(name string) *Builder {
+	b.current().Name = name
+	return b
+}
+
+// Set the timeout of the current spec.
+func (b *Builder) WithTimeout(d time.Duration) *Builder {
+	b.current().Timeout = d
+	return b
+}
+
+// Set the error policy of the current spec.
+func (b *Builder) WithPolicy(p ErrorPolicy) *Builder {
+	b.current().Policy = p
+	return b
+}
+
+// Set the tenant of the current spec.
+func (b *Builder) ForTenant(tenant string) *Builder {
+	b.current().Tenant = tenant
+	return b
+}
+
+// Make the current spec an express task, that keeps running in degraded mode.
+func (b *Builder) Express() *Builder {
+	b.current().Express = true
+	return b
+}
+
+// Run the current spec at a fixed offset within its period.
+func (b *Builder) AtOffset(offset int) *Builder {
+	b.current().Offset = offset
+	b.current().FixedOffset = true
+	return b
+}
+
+// Set the priority of the current spec.
+func (b *Builder) WithPriority(priority int) *Builder {
+	b.current().Priority = priority
+	return b
+}
+
+// Make the current spec an exempt task, that keeps running in maintenance mode.
+func (b *Builder) Exempt() *Builder {
+	b.current().Exempt = true
+	return b
+}
+
+// Shift the phase of the current spec by a random number of ticks, up to maxTicks.
+func (b *Builder) WithJitter(maxTicks int) *Builder {
+	b.current().Jitter = maxTicks
+	return b
+}
+
+// Record the runs of the current spec with idempotency keys. See RunStore.
+func (b *Builder) WithRunKeys() *Builder {
+	b.current().RunKeys = true
+	return b
+}
+
+// Add tags to the current spec. See TagStats.
+func (b *Builder) Tagged(tags ...string) *Builder {
+	c := b.current()
+	c.Tags = append(c.Tags, tags...)
+	return b
+}
+
+// Set the concurrency key of the current spec.
+func (b *Builder) WithKey(key string) *Builder {
+	b.current().Key = key
+	return b
+}
+
+// Set the task of the current spec.
+func (b *Builder) Run(t Task) *Builder {
+	b.current().Task = t
+	return b
+}
+
+// Set the task of the current spec to a function.
+func (b *Builder) Do(fn func() error) *Builder {
+	return b.Run(TaskFunc(fn))
+}
+
+// Return the plan built so far. The builder can still be used afterwards.
+func (b *Builder) Build() SchedulePlan {
+	return append(SchedulePlan(nil), b.specs...)
+}
+
+// Spec being built. Without a previous Every, a spec without period is started, that will be rejected when applied.
+func (b *Builder) current() *Spec {
+	if len(b.specs) == 0 {
+		b.specs = append(b.specs, Spec{})
+	}
+	return &b.specs[len(b.specs)-1]
+}
@@ -0,0 +1,161 @@
+package scheduler
+
+// ErrorPolicy decides what happens to a task whose run failed with err.
+// failures is the number of consecutive failed runs of the task, including this one.
+// It returns whether the task is removed from the scheduler and, if it is kept,
+// how many of its next due runs are skipped.
+type ErrorPolicy func(t Task, err error, failures int) (remove bool, skip int)
+
+// RemoveOnError removes a task as soon as it fails. This is the default policy.
+func RemoveOnError(Task, error, int) (bool, int) {
+	return true, 0
+}
+
+// IgnoreErrors keeps a task scheduled whatever its errors.
+func IgnoreErrors(Task, error, int) (bool, int) {
+	return false, 0
+}
+
+// Retry keeps a failing task scheduled for n more attempts, and removes it after n+1 consecutive failures.
+func Retry(n int) ErrorPolicy {
+	return func(_ Task, _ error, failures int) (bool, int) {
+		return failures > n, 0
+	}
+}
+
+// Backoff keeps a failing task scheduled, but doubles the number of due runs skipped at each
+// consecutive failure, up to max skipped runs. The task runs normally again after a success.
+func Backoff(max int) ErrorPolicy {
+	return func(_ Task, _ error, failures int) (bool, int) {
+		skip := 1
+		for i := 1; i < failures && skip < max; i++ {
+			skip *= 2
+		}
+		return false, min(skip, max)
+	}
+}
+
+// Callback lets fn decide : the task is removed when fn returns true.
+func Callback(fn func(t Task, err error) bool) ErrorPolicy {
+	return func(t Task, err error, _ int) (bool, int) {
+		return fn(t, err), 0
+	}
+}
+
+// Add tasks sheduled to run every 'period' ticks, handled by policy when they fail.
+// A nil policy uses the scheduler policy.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddWithPolicy(period int, policy ErrorPolicy, t ...Task) {
+	if period <= 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, policy: policy})
+	}
+}
+
+// Set the error policy of tasks added without their own. A nil policy restores RemoveOnError.
+func (s *scheduler) SetErrorPolicy(policy ErrorPolicy) {
+	s.lockpolicy.Lock()
+	defer s.lockpolicy.Unlock()
+
+	s.policy = policy
+}
+
+// Set a handler called for every failed run, before the error policy applies, so that errors never vanish silently.
+// A nil handler removes it.
+func (s *scheduler) SetErrorHandler(h func(t Task, err error)) {
+	s.lockpolicy.Lock()
+	defer s.lockpolicy.Unlock()
+
+	s.errorHandler = h
+}
+
+// Set a callback called when the error policy decides to remove a periodic task, before it is removed, as a last
+// chance not to lose work : returning keep vetoes the removal, and a positive period also reschedules the task with
+// that period, restarting its failure count. nil removes the callback.
+func (s *scheduler) OnEvict(fn func(t Task, reason error) (keep bool, period int)) {
+	s.lockpolicy.Lock()
+	defer s.lockpolicy.Unlock()
+
+	s.evict = fn
+}
+
+// decision is the outcome of the error policy for a failed run.
+type decision struct {
+	remove bool
+	skip   int
+	period int // new period of an entry requeued by OnEvict, 0 to keep it
+}
+
+// Apply the error handler and policies to the failed runs.
+// It is called without holding the task lock, since handler and policies are user code.
+func (s *scheduler) decide(run *tickRun) map[*entry]decision {
+	if len(run.errs) == 0 {
+		return nil
+	}
+	s.lockpolicy.RLock()
+	global, handler, evict := s.policy, s.errorHandler, s.evict
+	s.lockpolicy.RUnlock()
+	if global == nil {
+		global = RemoveOnError
+	}
+
+	decisions := make(map[*entry]decision, len(run.errs))
+	for _, e := range run.ran {
+		err, ok := run.errs[e]
+		if !ok {
+			continue
+		}
+		if handler != nil {
+			handler(e.task, err)
+		}
+		policy := e.policy
+		if policy == nil {
+			policy = global
+		}
+		remove, skip := policy(e.task, err, e.failures+1)
+		d := decision{remove: remove, skip: max(skip, 0)}
+		if remove && evict != nil && e.period > 0 {
+			if keep, period := evict(e.task, err); keep {
+				d = decision{period: max(period, 0)}
+			}
+		}
+		decisions[e] = d
+	}
+	return decisions
+}
+
+// unsafe application of the policy decisions, and reset of the failure count of successful runs.
+func (s *scheduler) applyDecisions(run *tickRun, decisions map[*entry]decision) {
+	for _, e := range run.ran {
+		if e.left > 0 {
+			if e.left--; e.left == 0 {
+				s.removeEntry(e) // retired after its last run
+				run.retired = append(run.retired, e)
+			}
+		}
+		d, failed := decisions[e]
+		e.outcomes.add(!failed)
+		e.outcomes.last(run.starts[e], run.took[e], run.errs[e])
+		if !failed {
+			e.failures = 0
+			continue
+		}
+		e.failures++
+		switch {
+		case d.remove:
+			s.removeEntry(e)
+		case d.period > 0:
+			if s.index(e) >= 0 { // not removed meanwhile
+				s.move(e, d.period)
+				e.base, e.failures, e.skip = 0, 0, 0
+			}
+		default:
+			e.skip = d.skip
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import "sync"
+
+// workerPool limits the number of tasks running concurrently, shared by all the ticks of a scheduler.
+// When the pool is saturated, waiting tasks get the next free worker by decreasing priority, and in the
+// order they started waiting for the same priority, so that high priority tasks are not queued behind lower
+// priority ones, even those of a previous tick still being dispatched.
+type workerPool struct {
+	lock    sync.Mutex
+	free    int                     // number of free workers
+	waiting map[int][]chan struct{} // tasks waiting for a worker, by priority
+}
+
+// Create a pool of n workers.
+func newWorkerPool(n int) *workerPool {
+	return &workerPool{free: n, waiting: map[int][]chan struct{}{}}
+}
+
+// Wait for a free worker, for a task of the given priority.
+func (p *workerPool) acquire(priority int) {
+	p.lock.Lock()
+	if p.free > 0 && len(p.waiting) == 0 {
+		p.free--
+		p.lock.Unlock()
+		return
+	}
+	ch := make(chan struct{})
+	p.waiting[priority] = append(p.waiting[priority], ch)
+	p.lock.Unlock()
+	<-ch // the worker is handed over by release
+}
+
+// Release a worker, handing it over to the waiting task of highest priority, if any.
+func (p *workerPool) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	first, top := true, 0
+	for pr := range p.waiting {
+		if first || pr > top {
+			first, top = false, pr
+		}
+	}
+	if first { // no task waiting
+		p.free++
+		return
+	}
+	ch := p.waiting[top][0]
+	if p.waiting[top] = p.waiting[top][1:]; len(p.waiting[top]) == 0 {
+		delete(p.waiting, top)
+	}
+	close(ch)
+}
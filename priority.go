@@ -0,0 +1,26 @@
+package scheduler
+
+import "sort"
+
+// Add a task sheduled to run every 'period' ticks, with a priority.
+// Tasks due on the same tick run by decreasing priority, tasks of equal priority in the usual order :
+// periodic tasks by increasing period, then one-shot and cron tasks. Tasks added otherwise have priority 0.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddWithPriority(period, priority int, t Task) *TaskHandle {
+	if period <= 0 {
+		return nil
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := &entry{task: t, priority: priority}
+	s.addEntry(period, e)
+	return &TaskHandle{s: s, e: e}
+}
+
+// Order due entries by decreasing priority, keeping the order of entries with the same priority.
+func byPriority(due []*entry) {
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].priority > due[j].priority
+	})
+}
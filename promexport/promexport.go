@@ -0,0 +1,118 @@
+// Package promexport exposes the metrics of a scheduler in the Prometheus text exposition format,
+// without depending on the Prometheus client library :
+//
+//	http.Handle("/metrics", promexport.Collector(s))
+package promexport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/xavier268/scheduler"
+)
+
+// DefaultBuckets are the upper bounds of the tick duration histogram, in seconds.
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+// Exporter collects the metrics of a scheduler, and serves them to Prometheus.
+type Exporter struct {
+	s       scheduler.Scheduler
+	lock    sync.Mutex
+	buckets []float64 // histogram upper bounds
+	counts  []uint64  // number of ticks in each bucket, not cumulated
+	sum     float64   // total tick duration in seconds
+	count   uint64    // number of ticks observed
+	errors  uint64    // number of failed runs observed
+}
+
+// Collector starts collecting the metrics of s, with the DefaultBuckets.
+// Tick durations and task errors are counted from the moment the collector is created.
+func Collector(s scheduler.Scheduler) *Exporter {
+	return CollectorWithBuckets(s, DefaultBuckets)
+}
+
+// CollectorWithBuckets starts collecting the metrics of s, with the given increasing histogram bounds in seconds.
+func CollectorWithBuckets(s scheduler.Scheduler, buckets []float64) *Exporter {
+	e := &Exporter{s: s, buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+	s.Subscribe(scheduler.EventTick|scheduler.EventRun, e.observe)
+	return e
+}
+
+// Account for an event.
+func (e *Exporter) observe(ev scheduler.Event) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	switch ev.Kind {
+	case scheduler.EventRun:
+		if ev.Err != nil {
+			e.errors++
+		}
+	case scheduler.EventTick:
+		d := ev.Duration.Seconds()
+		i := 0
+		for i < len(e.buckets) && d > e.buckets[i] {
+			i++
+		}
+		e.counts[i]++
+		e.sum += d
+		e.count++
+	}
+}
+
+// Serve the metrics in the Prometheus text format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	e.WriteTo(w)
+}
+
+// Write the metrics in the Prometheus text format.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	st := e.s.Stats()
+	tasks := e.s.Tasks()
+
+	e.lock.Lock()
+	counts := append([]uint64(nil), e.counts...)
+	sum, count, errors := e.sum, e.count, e.errors
+	e.lock.Unlock()
+
+	cw := &countWriter{w: w}
+	metric := func(name, kind, help string, value any) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("scheduler_ticks_total", "counter", "Number of ticks since start.", st.Ticks)
+	metric("scheduler_task_runs_total", "counter", "Number of task runs since start.", st.Runs)
+	metric("scheduler_task_errors_total", "counter", "Number of failed task runs observed.", errors)
+	metric("scheduler_overruns_total", "counter", "Number of ticks longer than the tick duration.", st.Overruns)
+	metric("scheduler_load", "gauge", "Time spent running tasks as a ratio of the elapsed time.", st.Load)
+	metric("scheduler_tasks", "gauge", "Number of scheduled tasks.", tasks)
+
+	name := "scheduler_tick_duration_seconds"
+	fmt.Fprintf(cw, "# HELP %s Time spent in each tick.\n# TYPE %s histogram\n", name, name)
+	var cum uint64
+	for i, b := range e.buckets {
+		cum += counts[i]
+		fmt.Fprintf(cw, "%s_bucket{le=\"%v\"} %d\n", name, b, cum)
+	}
+	fmt.Fprintf(cw, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %v\n%s_count %d\n", name, count, name, sum, name, count)
+	return cw.n, cw.err
+}
+
+// countWriter counts the bytes written, and keeps the first error.
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
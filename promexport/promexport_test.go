@@ -0,0 +1,40 @@
+package promexport
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xavier268/scheduler"
+)
+
+func TestCollector(t *testing.T) {
+	s := scheduler.New()
+	e := Collector(s)
+	s.Add(1, scheduler.NoopTask(), scheduler.ErrTask(errors.New("boom")))
+	s.Start(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	s.Stop()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE scheduler_ticks_total counter",
+		"scheduler_task_errors_total 1\n",
+		"scheduler_tasks 1\n",
+		"# TYPE scheduler_tick_duration_seconds histogram",
+		`scheduler_tick_duration_seconds_bucket{le="+Inf"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("Expected %q in\n%s", want, body)
+		}
+	}
+	ticks := s.Ticks()
+	if !strings.Contains(body, "scheduler_tick_duration_seconds_count "+strconv.Itoa(ticks)+"\n") {
+		t.Fatalf("Expected %d ticks observed in\n%s", ticks, body)
+	}
+}
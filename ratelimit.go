@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter decides whether a run may start now. It is implemented by TokenBucket, and by *rate.Limiter
+// of golang.org/x/time/rate.
+type Limiter interface {
+	Allow() bool
+}
+
+// RateLimit returns a task running t only when limiter allows it, whatever the tick frequency : the runs denied
+// are skipped, and succeed without calling t. It protects downstream APIs from bursty periodic tasks.
+func RateLimit(t Task, limiter Limiter) Task {
+	return &limitedTask{task: t, limiter: limiter}
+}
+
+// limitedTask is the task returned by RateLimit.
+type limitedTask struct {
+	task    Task
+	limiter Limiter
+}
+
+func (l *limitedTask) Run() error {
+	return l.RunContext(context.Background())
+}
+
+func (l *limitedTask) RunContext(ctx context.Context) error {
+	if !l.limiter.Allow() {
+		return nil // skipped
+	}
+	return runTask(ctx, l.task)
+}
+
+func (l *limitedTask) String() string {
+	return fmt.Sprint(l.task)
+}
+
+// TokenBucket is a Limiter allowing rate runs per second on average, and bursts of up to burst runs.
+// It is safe for concurrent use.
+type TokenBucket struct {
+	lock   sync.Mutex
+	rate   float64   // tokens added per second
+	burst  float64   // capacity of the bucket
+	tokens float64   // tokens available at last
+	last   time.Time // time of the last update
+	now    func() time.Time
+}
+
+// Return a full TokenBucket, refilled with rate tokens per second up to burst tokens.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	b := &TokenBucket{rate: max(rate, 0), burst: float64(max(burst, 1)), now: time.Now}
+	b.tokens, b.last = b.burst, b.now()
+	return b
+}
+
+// Take a token if one is available.
+func (b *TokenBucket) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
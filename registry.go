@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+)
+
+// ErrAlreadyRegistered is returned by Register when the name is taken. It matches ErrExists.
+var ErrAlreadyRegistered = newError("scheduler name already registered", ErrExists)
+
+// registry is the global registry of the named schedulers of the process.
+var registry = struct {
+	lock       sync.Mutex
+	schedulers map[string]Scheduler
+}{schedulers: map[string]Scheduler{}}
+
+// Register s under name in the global registry, so that programs running several schedulers, such as a fast loop
+// and a slow loop, can find them with Get and stop them all with StopAll at exit. Using the registry is optional.
+func Register(name string, s Scheduler) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if _, ok := registry.schedulers[name]; ok {
+		return ErrAlreadyRegistered
+	}
+	registry.schedulers[name] = s
+	return nil
+}
+
+// Remove the scheduler registered under name, returning false if there is none.
+func Unregister(name string) bool {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	_, ok := registry.schedulers[name]
+	delete(registry.schedulers, name)
+	return ok
+}
+
+// Get the scheduler registered under name.
+func Get(name string) (Scheduler, bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	s, ok := registry.schedulers[name]
+	return s, ok
+}
+
+// Names of the registered schedulers, sorted.
+func Registered() []string {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	names := make([]string, 0, len(registry.schedulers))
+	for n := range registry.schedulers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stop all the registered schedulers concurrently, and wait for them. They stay registered, and can be restarted.
+func StopAll() {
+	registry.lock.Lock()
+	all := make([]Scheduler, 0, len(registry.schedulers))
+	for _, s := range registry.schedulers {
+		all = append(all, s)
+	}
+	registry.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range all {
+		wg.Add(1)
+		go func(s Scheduler) {
+			defer wg.Done()
+			s.Stop()
+		}(s)
+	}
+	wg.Wait()
+}
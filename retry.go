@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// BackoffStrategy returns the delay to wait before retry attempt n, n starting at 1 for the first retry.
+type BackoffStrategy func(n int) time.Duration
+
+// ConstantBackoff waits d before each retry.
+func ConstantBackoff(d time.Duration) BackoffStrategy {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff waits base before the first retry, and doubles the delay at each retry, up to max.
+func ExponentialBackoff(base, max time.Duration) BackoffStrategy {
+	return func(n int) time.Duration {
+		d := base
+		for i := 1; i < n && d < max; i++ {
+			d *= 2
+		}
+		return min(d, max)
+	}
+}
+
+// JitteredBackoff waits a random delay between 0 and the one of b, so that tasks failing together do not
+// retry together.
+func JitteredBackoff(b BackoffStrategy) BackoffStrategy {
+	return func(n int) time.Duration {
+		if d := b(n); d > 0 {
+			return time.Duration(rand.Int63n(int64(d) + 1))
+		}
+		return 0
+	}
+}
+
+// RetryTask returns a task running t up to attempts times within the same run, waiting backoff between attempts,
+// until it succeeds. Transient failures then do not reach the error policy, that would remove the task by default.
+// The run fails with the error of the last attempt. It stops waiting when the run context is cancelled.
+// A nil backoff retries at once. It is named RetryTask since Retry is the error policy.
+func RetryTask(t Task, attempts int, backoff BackoffStrategy) Task {
+	return &retryTask{task: t, attempts: max(attempts, 1), backoff: backoff}
+}
+
+// retryTask is the task returned by RetryTask.
+type retryTask struct {
+	task     Task
+	attempts int
+	backoff  BackoffStrategy
+}
+
+func (r *retryTask) Run() error {
+	return r.RunContext(context.Background())
+}
+
+func (r *retryTask) RunContext(ctx context.Context) error {
+	var err error
+	for n := 0; n < r.attempts; n++ {
+		if n > 0 && r.backoff != nil {
+			timer := time.NewTimer(r.backoff(n))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+		if err = runTask(ctx, r.task); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (r *retryTask) String() string {
+	return fmt.Sprint(r.task)
+}
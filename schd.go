@@ -1,37 +1,83 @@
 package scheduler
 
 import (
-	"log"
+	"context"
+	"io"
+	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const VERSION = "0.1.5"
 
 // Tasks are run at regular number of ticks.
-// If Task generates an error, it is removed from scheduler.
+// If Task generates an error, the error policy decides what happens to it. By default, it is removed from scheduler.
 type Task interface {
 	Run() error
 }
 
+// TaskCtx is a Task that can be cancelled.
+// When a task implements TaskCtx, the scheduler calls RunContext instead of Run,
+// with a context that is cancelled when the scheduler is stopped.
+type TaskCtx interface {
+	Task
+	RunContext(ctx context.Context) error
+}
+
 // Hook is executed before and after all tasks are run at every tick.
 type Hook func(s Scheduler)
 
 type Scheduler interface {
-	// Add tasks to the scheduler.
-	Add(period int, t ...Task)
+	// Add tasks to the scheduler, returning a handle per registration.
+	Add(period int, t ...Task) []*TaskHandle
+	// Wrap the tasks added from now on with middleware.
+	Use(mw ...Middleware)
 	// Remove a task from the scheduler.
 	Remove(t Task)
+	// Run a task once, delayTicks ticks from now.
+	RunOnce(delayTicks int, t Task)
+	// Run a task once, at the given tick.
+	RunAt(tick int, t Task)
+	// Run a task once, at the first tick at or after t.
+	ScheduleAt(t time.Time, task Task) error
+	// Run a task once, at the first tick at least d from now.
+	ScheduleAfter(d time.Duration, task Task) error
+	// Add a task running according to a cron expression.
+	AddCron(expr string, t Task) error
+	// Add a task running according to a parsed cron schedule.
+	AddSchedule(c *CronSchedule, t Task)
+	// Get the next run time of a cron scheduled task.
+	NextCron(t Task) (time.Time, bool)
 	// Create an new empty scheduler with the exact same tasks.
 	New() Scheduler
 
-	// Start the scheduler with the specified clock period.
+	// Start the scheduler with the specified clock period. A stopped scheduler can be started again.
 	Start(duration time.Duration)
-	// Stop the scheduler. A stopped scheduler cannot be restarted nor stopped again.
+	// Stop the scheduler, no tick starts once called. Stopping a scheduler not running does nothing.
 	Stop()
+	// Run n ticks in the calling goroutine, then stop.
+	RunFor(n int, duration time.Duration)
+	// Run the ticks in the calling goroutine until ctx is done, then stop.
+	RunUntil(ctx context.Context, duration time.Duration) error
+	// Run the next tick now, to drive the scheduler tick by tick instead of starting it.
+	Step()
+	// Reset the scheduler statistics.
+	ResetStats()
+	// Suspend the ticks, without stopping the scheduler.
+	Pause()
+	// Resume the ticks after a Pause.
+	Resume()
+	// Check whether the ticks are suspended.
+	Paused() bool
 
-	// Get the elapsed ticks since last scheduler (re)start.
+	// Get the elapsed ticks since scheduler creation or last ResetStats.
 	Ticks() int
+	// Change the tick duration, even while running.
+	SetDuration(d time.Duration)
+	// Get a consistent snapshot of the scheduler statistics.
+	Stats() Stats
 	// Get the calculated elapsed duration since last start
 	Elapsed() time.Duration
 	// Get the actual elapsedtime since last start
@@ -40,67 +86,443 @@ type Scheduler interface {
 	Tasks() int
 	// Get the average load of the last run
 	Load() float64
+	// Get the lifetime counters, accumulated across restarts.
+	Lifetime() Lifetime
+	// Get the stats of the k slowest traced tasks, by average duration.
+	TopSlow(k int) []TaskStat
+	// Get the stats of the k slowest traced tasks, by 99th percentile duration.
+	TopSlowP99(k int) []TaskStat
+	// Declare the SLA of a scheduled task.
+	SetSLA(t Task, sla SLA) bool
+	// Get the rolling SLA compliance of a task.
+	SLACompliance(t Task) (float64, bool)
+	// Get the rolling success rate of a task.
+	SuccessRate(t Task) (float64, bool)
+	// Get the success and failure counts of a task.
+	Outcomes(t Task) (Outcomes, bool)
 
+	// Add a Hook executed before all tasks are run at every tick, after the hooks added before it.
+	AddBeforeHook(h Hook) *HookHandle
+	// Add a Hook executed after all tasks are run at every tick, after the hooks added before it.
+	AddAfterHook(h Hook) *HookHandle
 	// Set a Hook that will be executed before all tasks are run at every tick.
+	// Deprecated: use AddBeforeHook.
 	SetBefore(h Hook)
 	// Set a Hook that will be executed after all tasks are run at every tick.
+	// Deprecated: use AddAfterHook.
 	SetAfter(h Hook)
+	// Get the number of hook calls dropped because the asynchronous queue was full.
+	DroppedHooks() int
+	// Get the duration statistics of the before and after hooks.
+	HookStats() (before, after TaskStat)
+
+	// Subscribe to the events matching kinds.
+	Subscribe(kinds EventKind, fn func(Event))
+	// Register an observer, receiving events through a bounded queue.
+	Observe(kinds EventKind, o Observer, queue int) *Observation
+	// Write all the events to w, one per line.
+	StreamEvents(w io.Writer, format Format) *Observation
+
+	// Multiply the period of a task by factor, until Unthrottle.
+	Throttle(t Task, factor float64) bool
+	// Restore the original period of a throttled task.
+	Unthrottle(t Task) bool
+	// Add a task with a priority, ordering the tasks due on the same tick.
+	AddWithPriority(period, priority int, t Task) *TaskHandle
+	// Add a task running only at the ticks a condition holds.
+	AddIf(period int, cond func() bool, t Task) *TaskHandle
+	// Add a task retiring after count runs.
+	AddTimes(period, count int, t Task) *TaskHandle
+	// Add a task removed after a wall-clock lifetime.
+	AddWithTTL(period int, ttl time.Duration, t Task) *TaskHandle
+	// Register a callback called for each task removed by the scheduler itself, with the reason.
+	OnTaskRemoved(fn func(t Task, reason error))
+	// Make a task run after other tasks due on the same tick, and be skipped if one of them failed.
+	DependsOn(t Task, on ...Task) error
+	// Add tasks running on the same ticks, each one after the previous one succeeded, rejecting cycles.
+	AddChain(period int, t ...Task) ([]*TaskHandle, error)
+	// Add a task running at a given offset within its period.
+	AddWithOffset(period, offset int, t Task) *TaskHandle
+	// Add tasks that are abandoned when a run exceeds timeout.
+	AddWithTimeout(period int, timeout time.Duration, t ...Task)
+	// Add tasks handled by policy when they fail.
+	AddWithPolicy(period int, policy ErrorPolicy, t ...Task)
+	// Set the error policy of tasks added without their own. nil restores the default, RemoveOnError.
+	SetErrorPolicy(policy ErrorPolicy)
+	// Set a handler called for every failed run, whatever the policy. nil removes it.
+	SetErrorHandler(h func(t Task, err error))
+	// Set a callback that may veto or requeue the removal of a task by its error policy.
+	OnEvict(fn func(t Task, reason error) (keep bool, period int))
+	// Add tasks whose runs must be committed, retried according to policy otherwise.
+	AddExactlyOnce(period int, policy ErrorPolicy, t ...CommitTask)
+	// Add express tasks, that keep running in degraded mode.
+	AddExpress(period int, t ...Task)
+	// Check whether the scheduler is in degraded mode.
+	Degraded() bool
+	// Add exempt tasks, that keep running in maintenance mode.
+	AddExempt(period int, t ...Task)
+	// Enter maintenance mode, only exempt tasks run.
+	EnterMaintenance()
+	// Leave maintenance mode.
+	ExitMaintenance()
+	// Check whether the scheduler is in maintenance mode.
+	Maintenance() bool
+	// Add tasks belonging to a tenant, within the tenant budget.
+	AddTenant(tenant string, period int, t ...Task) error
+	// Add the tasks described by specs, reporting an error for each rejected one.
+	AddBatch(specs []Spec) []error
+	// Add all the tasks of a plan, or none of them.
+	ApplyPlan(p SchedulePlan) error
+	// Apply the changes between two plans.
+	ApplyDiff(d PlanDiff) error
+	// Add a task under a unique name.
+	AddNamed(name string, period int, t Task) error
+	// Get the task registered under name.
+	Lookup(name string) (Task, bool)
+	// Remove the task registered under name.
+	RemoveByName(name string) bool
+	// Pause the runs of the task registered under name.
+	PauseByName(name string) bool
+	// Resume the runs of the task registered under name.
+	ResumeByName(name string) bool
+	// Get a snapshot of the scheduler state and of its tasks.
+	Status() Status
+	// Get the sorted names of the named tasks.
+	Names() []string
+	// Set the policy applied when a tick overruns its duration.
+	SetOverrunPolicy(p OverrunPolicy)
+	// Get the number of overrunning ticks since start.
+	Overruns() int
+	// Set a Hook executed after every overrunning tick.
+	OnOverrun(h Hook)
+	// Create a child scope, whose tasks are removed when it is closed.
+	Child() *Scope
+	// Get the group of tasks with the given name, managed as a unit.
+	Group(name string) *Group
+	// Transfer the schedule and statistics to another scheduler, switching execution at a tick boundary.
+	Handoff(target Scheduler) error
+	// Write the named tasks and the tick counter to w.
+	SaveSchedule(w io.Writer) error
+	// Read a schedule written by Save, creating its tasks by name with factory.
+	LoadSchedule(r io.Reader, factory TaskFactory) error
+	// List the tasks that would run at a tick.
+	Due(tick int) []TaskInfo
+	// Get when a task runs next.
+	NextRun(t Task) (TaskInfo, bool)
+	// List all the registrations with their schedule and last run.
+	List() []TaskInfo
+	// Change the period of a task, keeping its history and phase.
+	Reschedule(t Task, period int) bool
+	// Get the current stretch factor of the periods.
+	Stretch() float64
+	// Stop the scheduler, waiting for the running tasks until ctx is done.
+	StopContext(ctx context.Context) error
+	// Stop the scheduler, waiting for the running tasks up to d.
+	StopWithTimeout(d time.Duration) error
+	// Add tasks removed automatically when their owner is done.
+	AddOwned(owner Owner, period int, t ...Task) []*TaskHandle
+	// Set the store persisting the schedule and the dead letters.
+	SetStore(st Store)
+	// Save the named tasks and their run state to the store.
+	Checkpoint() error
+	// Add the tasks saved in the store, bound by name.
+	Restore(tasks map[string]Task) error
+	// Add tasks carrying tags, their runs being accounted per tag.
+	AddTagged(period int, tags []string, t ...Task)
+	// Get the statistics aggregated over the tasks carrying a tag.
+	TagStats(tag string) TagStats
+	// Get the statistics of all the tags, by decreasing busy duration.
+	TagRollups() []TagStats
+	// Set the structured logger, nil to disable logging.
+	SetLogger(l *slog.Logger)
+	// Set the tracer starting spans for ticks and task runs, nil to disable them.
+	SetSpanTracer(t SpanTracer)
+	// Get the stats of a task traced with WithTracing or wrapped in a TaskTracer.
+	TaskStats(t Task) (TaskStat, bool)
+	// Get the stats of all the traced tasks, by increasing period.
+	AllStats() []TaskStat
+	// Number of catch-up runs waiting for a tick.
+	CatchUpBacklog() int
+	// Add tasks that never run simultaneously with the other tasks sharing the same concurrency key.
+	AddWithKey(period int, key string, t ...Task)
+	// Number of runs queued by smoothing, waiting for a tick.
+	SmoothingBacklog() int
+	// Get the effective offsets of the registrations of a periodic task.
+	Offsets(t Task) []Offset
+	// Remove the registration identified by id.
+	RemoveID(id TaskID) bool
+	// Watch the changes of the schedule until ctx is done.
+	Watch(ctx context.Context) <-chan ScheduleChange
+	// Get the time of the last heartbeat.
+	LastHeartbeat() time.Time
+	// Set the budget of a tenant.
+	SetTenantBudget(tenant string, b TenantBudget)
+	// Get the statistics of a tenant.
+	TenantStats(tenant string) TenantStats
+}
+
+// entry is a single registration of a task in the scheduler.
+type entry struct {
+	task     Task          // registered task
+	name     string        // name of the task, empty if none
+	period   int           // period in ticks, 0 for tasks not run periodically
+	tenant   string        // tenant owning the task, empty if none
+	timeout  time.Duration // maximum duration of a run, 0 if unlimited
+	express  bool          // keeps running in degraded mode
+	exempt   bool          // keeps running in maintenance mode
+	jitter   int           // maximum random shift of the phase, in ticks, drawn when added
+	keyed    bool          // runs recorded with idempotency keys
+	runs     int           // number of runs, counted for keyed entries
+	commit   bool          // exactly-once mode, runs must be committed
+	tags     []string      // tags the runs are accounted in
+	trace    *TaskTracer   // tracer set by WithTracing, nil if none
+	key      string        // concurrency key, runs sharing it never overlap
+	queued   bool          // due run queued in the smoothing bucket
+	id       TaskID        // identity of the registration
+	metrics  *taskMetrics  // last values recorded with TaskEnv.Record
+	run      Task          // task wrapped by the middleware, nil if none
+	paused   bool          // runs suspended through its handle
+	fixed    bool          // runs at offset within its period, instead of at its index
+	offset   int           // tick within the period the task runs at, if fixed
+	base     int           // original period of a throttled task, 0 if not throttled
+	priority int           // tasks due on the same tick run by decreasing priority
+	sla      *slaState     // service level tracking, nil if none
+	after    []Task        // tasks the entry runs after when due on the same tick, see DependsOn
+	cond     func() bool   // the entry runs only at the ticks it holds, nil if always
+	left     int           // runs left before the entry retires, 0 if unlimited
+	expires  time.Time     // time the entry is removed at, zero if never
+
+	policy   ErrorPolicy // error policy of the task, nil for the scheduler policy
+	failures int         // number of consecutive failed runs
+	skip     int         // number of due runs to skip before running again
+	outcomes outcomes    // success and failure counts
+}
+
+// Copy the registration, without its run history.
+func (e *entry) clone() *entry {
+	ee := *e
+	ee.failures, ee.skip, ee.queued, ee.id, ee.metrics, ee.run = 0, 0, false, 0, nil, nil
+	ee.outcomes = outcomes{recent: newRing(DefaultRateWindow)}
+	ee.after = slices.Clone(e.after)
+	if e.sla != nil {
+		ee.sla = &slaState{sla: e.sla.sla, runs: newRing(e.sla.sla.Window)}
+	}
+	if e.trace != nil {
+		ee.trace = e.trace.fresh()
+	}
+	return &ee
 }
 
 // scheduler is responsible for holding tasks and running them at regular intervals.
 type scheduler struct {
-	done   chan struct{}  // channel for signalling scheduler closing
-	wg     sync.WaitGroup // wait group for scheduler closing
-	ticker *time.Ticker   // ticker for scheduling
+	lockrun sync.Mutex         // serializes Start and Stop
+	ctx     context.Context    // context passed to the tasks, cancelled on Stop
+	cancel  context.CancelFunc // cancel ctx
+	wg      sync.WaitGroup     // wait group for scheduler closing
+	ticker  Ticker             // ticker for scheduling
+	clock   Clock              // source of time, the system clock by default
+	paused  atomic.Bool        // ticks are suspended
+
+	lockstats sync.RWMutex     // lock for scheduler stats
+	duration  time.Duration    // duration of each tick
+	ticks     int              // total number of ticks since start
+	slots     time.Duration    // sum of the tick durations since start, the duration may change
+	load      time.Duration    // total running duration since last scheduler start
+	lastTick  time.Duration    // duration of the last tick
+	lastHooks [2]time.Duration // durations of the before and after hooks of the last tick
+	maxTick   time.Duration    // maximum duration of a tick
+	runs      int              // number of task runs
+	failures  int              // number of failed task runs
+
+	locktasks    sync.Mutex         // lock for scheduler tasks
+	tasks        map[int][]*entry   // database of active tasks
+	once         map[int][]*entry   // one-shot tasks, by the tick they run at
+	crons        []*cronEntry       // cron scheduled tasks
+	lastCheck    time.Time          // time of the last cron check, to detect clock steps
+	degraded     bool               // only express tasks run
+	degrade      degradation        // degraded mode thresholds and recent load
+	adapt        adaptation         // adaptive mode thresholds and stretch of the periods
+	maintenance  bool               // only exempt tasks run
+	tenants      map[string]*tenant // tenant budgets and statistics
+	tags         map[string]*rollup // tag statistics
+	groups       map[string]*Group  // groups by name
+	scopes       map[*Scope]bool    // scopes not closed
+	expiring     []*entry           // entries with a TTL
+	inflight     int                // number of ticks started and not finished
+	catchup      int                // maximum catch-up runs per tick, 0 if disabled
+	backlog      []*entry           // catch-up runs waiting for a tick
+	smoothPeriod int                // minimum period of the smoothed tasks
+	smoothRate   int                // maximum smoothed runs started per tick, 0 if disabled
+	bucket       []*entry           // smoothed runs waiting for a tick
+	middleware   []Middleware       // wrappers of the tasks added, see Use
+
+	workers       int           // maximum number of tasks running concurrently, 0 or 1 to run them serially
+	pool          *workerPool   // workers shared by the ticks, nil when running serially
+	jitter        int           // default maximum random shift of the task phases, in ticks
+	tracing       bool          // trace all tasks, see WithTracing
+	traceHalfLife time.Duration // half-life of the statistics of the tracers created by WithTracing
+	name          string        // name of the scheduler, see WithName
+
+	periodDeadline atomic.Bool // derive the context deadline of periodic runs from their period
+
+	lockpolicy   sync.RWMutex                           // lock for the error policy and handler
+	policy       ErrorPolicy                            // error policy for tasks without their own
+	errorHandler func(t Task, e error)                  // called for every failed run, nil if none
+	evict        func(t Task, reason error) (bool, int) // last chance before a removal, nil if none
+
+	beforeTick   []*HookHandle // Hooks called before all tasks are run at every tick, guarded by lockhooks
+	afterTick    []*HookHandle // Hooks called after all tasks are run at every tick, guarded by lockhooks
+	legacyBefore *HookHandle   // hook set with SetBefore, guarded by lockhooks
+	legacyAfter  *HookHandle   // hook set with SetAfter, guarded by lockhooks
+	beforeTrace  *TaskTracer   // durations of the before hook calls
+	afterTrace   *TaskTracer   // durations of the after hook calls
+	overrunHook  Hook          // hook executed after an overrunning tick
+	overrunTrace *TaskTracer   // durations of the overrun hook
+
+	lockhooks sync.Mutex      // lock for the asynchronous hook queue
+	hookSize  int             // size of the asynchronous hook queue, 0 if hooks are synchronous
+	hookQueue chan tracedHook // queue of hooks to run asynchronously, nil if hooks run on the tick goroutine
+	hookDrops atomic.Int64    // number of hook calls dropped because the queue was full
+	hookLimit atomic.Int64    // maximum duration of a hook in nanoseconds, 0 if unlimited
+
+	lockkeys sync.Mutex               // lock for the concurrency keys
+	keys     map[string]chan struct{} // lock of each concurrency key, see keyLock
+
+	lockflight sync.Mutex     // lock for the runs in flight
+	flight     map[*entry]int // number of runs in flight of each entry
+
+	lockstore sync.Mutex // lock for the store
+	store     Store      // persistence of the schedule, nil if none
+	locker    Locker     // coordination of the named tasks between instances, nil if none
+	instance  string     // identity of the scheduler as a lock owner
+	shard     *sharding  // partition of the named tasks between instances, nil if none
+
+	lockowners sync.Mutex       // lock for the owners
+	owners     map[Owner]*Scope // scope of the tasks of each owner not yet done
+
+	overrun  atomic.Int32 // overrun policy
+	overruns atomic.Int64 // number of overrunning ticks since start
+
+	anomaly atomic.Uint64 // anomaly threshold, as the float64 bits of a number of standard deviations
 
-	lockstats sync.RWMutex  // lock for scheduler stats
-	duration  time.Duration // duration of each tick
-	ticks     int           // total number of ticks since start
-	load      time.Duration // total running duration since last scheduler start
+	heartbeat *entry       // heartbeat task, nil if none, guarded by locktasks
+	lastBeat  atomic.Int64 // time of the last heartbeat, in unix nanoseconds, 0 if none
 
-	locktasks sync.Mutex     // lock for scheduler tasks
-	tasks     map[int][]Task // database of active tasks
+	lockwatch sync.Mutex // lock for the watchers, taken with the task lock held
+	watchers  []*watcher // Watch listeners
 
-	beforeTick Hook // Hook called before all tasks are run at every tick
-	afterTick  Hook // Hook called after all tasks are run at every tick
+	lockevents  sync.RWMutex // lock for event subscribers
+	subscribers []subscriber // event listeners
 
-	actualStartTime time.Time // time scheduler was started
-	actualStopTime  time.Time // time scheduler was stopped
+	logs  atomic.Pointer[logState]  // logger set with SetLogger, nil for the default logger
+	spans atomic.Pointer[spanState] // tracer set with SetSpanTracer, nil if none
+
+	actualStartTime time.Time     // time scheduler was started
+	actualStopTime  time.Time     // time scheduler was stopped
+	starts          int           // number of starts
+	stops           int           // number of completed stops
+	uptime          time.Duration // cumulative running time of the previous runs
+	stopReason      string        // reason of the last stop
 
 }
 
 // Create a new scheduler with the tasks copied from s.
 func (s *scheduler) New() Scheduler {
 
-	ss := New()
+	ss := New(WithConcurrency(s.workers), WithJitter(s.jitter), WithClock(s.clock))
+	ss.(*scheduler).tracing = s.tracing
+	ss.(*scheduler).traceHalfLife = s.traceHalfLife
+	ss.(*scheduler).name = s.name
+	s.lockpolicy.RLock()
+	ss.SetErrorPolicy(s.policy)
+	ss.SetErrorHandler(s.errorHandler)
+	ss.OnEvict(s.evict)
+	s.lockpolicy.RUnlock()
+	ss.(*scheduler).logs.Store(s.logs.Load())
+	ss.(*scheduler).spans.Store(s.spans.Load())
+	ss.(*scheduler).anomaly.Store(s.anomaly.Load())
+	ss.(*scheduler).periodDeadline.Store(s.periodDeadline.Load())
+	ss.(*scheduler).hookLimit.Store(s.hookLimit.Load())
+	s.lockhooks.Lock()
+	ss.(*scheduler).hookSize = s.hookSize
+	s.lockhooks.Unlock()
+	s.lockstore.Lock()
+	ss.(*scheduler).locker, ss.(*scheduler).shard = s.locker, s.shard
+	s.lockstore.Unlock()
 
+	now := s.Ticks()
 	s.locktasks.Lock()
 	defer s.locktasks.Unlock()
+	ss.(*scheduler).middleware = slices.Clone(s.middleware)
+	ss.(*scheduler).degrade.enter, ss.(*scheduler).degrade.exit = s.degrade.enter, s.degrade.exit
+	ss.(*scheduler).adapt.high, ss.(*scheduler).adapt.low, ss.(*scheduler).adapt.max = s.adapt.high, s.adapt.low, s.adapt.max
+	ss.(*scheduler).catchup = s.catchup
+	ss.(*scheduler).smoothPeriod, ss.(*scheduler).smoothRate = s.smoothPeriod, s.smoothRate
 
 	for p, v := range s.tasks {
-		ss.(*scheduler).tasks[p] = append([]Task{}, v...) // force copy
+		for _, e := range v {
+			if e == s.heartbeat { // the heartbeat of the copy records its own beats
+				ss.(*scheduler).setHeartbeat(p, e.task.(*heartbeatTask).sinks)
+				continue
+			}
+			ss.(*scheduler).addEntry(p, e.clone()) // tasks with jitter get a new phase
+		}
+	}
+	for at, v := range s.once { // keep the remaining delay
+		for _, e := range v {
+			ee := e.clone()
+			ss.(*scheduler).register(ee)
+			ss.(*scheduler).once[at-now] = append(ss.(*scheduler).once[at-now], ee)
+		}
+	}
+	for _, c := range s.crons {
+		ee := c.clone()
+		ss.(*scheduler).register(ee)
+		ss.(*scheduler).crons = append(ss.(*scheduler).crons, &cronEntry{entry: ee, sched: c.sched, next: c.sched.Next(ss.(*scheduler).now())})
+	}
+	for n, tn := range s.tenants {
+		ss.(*scheduler).tenants[n] = &tenant{budget: tn.budget}
 	}
 	return ss
 }
 
-// New creates a new empty scheduler.
-func New() Scheduler {
-	return &scheduler{
-		done:     make(chan struct{}),
+// New creates a new empty scheduler, configured with the provided options.
+func New(opts ...Option) Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &scheduler{
+		ctx:      ctx,
+		cancel:   cancel,
 		wg:       sync.WaitGroup{},
 		ticker:   nil,
+		clock:    systemClock{},
 		duration: 0,
 		ticks:    0,
 		load:     0,
-		tasks:    map[int][]Task{},
-		beforeTick: func(s Scheduler) {
-		},
-		afterTick: func(s Scheduler) {
-		},
+		tasks:    map[int][]*entry{},
+		once:     map[int][]*entry{},
+		tenants:  map[string]*tenant{},
+		tags:     map[string]*rollup{},
+		groups:   map[string]*Group{},
+		scopes:   map[*Scope]bool{},
+		keys:     map[string]chan struct{}{},
+		flight:   map[*entry]int{},
+		owners:   map[Owner]*Scope{},
+		instance: newInstance(),
+
+		beforeTrace:  Trace(nil),
+		afterTrace:   Trace(nil),
+		overrunTrace: Trace(nil),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	if s.workers > 1 {
+		s.pool = newWorkerPool(s.workers)
+	}
+	return s
 }
 
-// Total number of ticks since scheduler creation
+// Total number of ticks since scheduler creation, or since the last ResetStats.
 func (s *scheduler) Ticks() int {
 	s.lockstats.RLock()
 	defer s.lockstats.RUnlock()
@@ -108,32 +530,41 @@ func (s *scheduler) Ticks() int {
 	return s.ticks
 }
 
-// Set a Hook that will be executed before all tasks are run at every tick.
-func (s *scheduler) SetBefore(h Hook) {
-	s.beforeTick = h
-}
-
-// Set a Hook that will be executed after all tasks are run at every tick.
-func (s *scheduler) SetAfter(h Hook) {
-	s.afterTick = h
-}
-
 // Add tasks sheduled to run every 'period' ticks.
 // Negative or 0 period tasks are not scheduled.
 // If same atsk is added multiple times, it will be called treated as separate tasks.
-func (s *scheduler) Add(period int, t ...Task) {
+// The returned handles, one per task, manipulate each registration separately.
+func (s *scheduler) Add(period int, t ...Task) []*TaskHandle {
 	if period <= 0 {
-		return
+		return nil
 	}
 	s.locktasks.Lock()
 	defer s.locktasks.Unlock()
 
-	s.add(period, t...)
+	return s.add(period, t...)
 }
 
 // unsafe add
-func (s *scheduler) add(period int, t ...Task) {
-	s.tasks[period] = append(s.tasks[period], t...)
+func (s *scheduler) add(period int, t ...Task) []*TaskHandle {
+	hs := make([]*TaskHandle, len(t))
+	for i, tt := range t {
+		e := &entry{task: tt}
+		s.addEntry(period, e)
+		hs[i] = &TaskHandle{s: s, e: e}
+	}
+	return hs
+}
+
+// unsafe add of a prepared entry
+func (s *scheduler) addEntry(period int, e *entry) {
+	e.period = period
+	e.outcomes.recent = newRing(DefaultRateWindow)
+	s.applyJitter(e)
+	s.register(e)
+	s.tasks[period] = append(s.tasks[period], e)
+	if !e.expires.IsZero() {
+		s.expiring = append(s.expiring, e)
+	}
 }
 
 // Remove a given task from the scheduler, preserving order of other tasks.
@@ -147,85 +578,583 @@ func (s *scheduler) Remove(t Task) {
 
 // unsafe remove.
 func (s *scheduler) remove(t Task) {
-	for p, v := range s.tasks {
-		for i, tt := range v {
-			if tt == t {
-				s.tasks[p] = append(v[:i], v[i+1:]...) // order is preserved
-				break
+	for _, m := range []map[int][]*entry{s.tasks, s.once} {
+		for p, v := range m {
+			for i, e := range v {
+				if sameTask(e.task, t) {
+					m[p] = append(v[:i], v[i+1:]...) // order is preserved
+					s.changed(ChangeRemoved, e)
+					break
+				}
 			}
 		}
 	}
+	for i, c := range s.crons {
+		if sameTask(c.task, t) {
+			s.crons = append(s.crons[:i], s.crons[i+1:]...)
+			s.changed(ChangeRemoved, c.entry)
+			break
+		}
+	}
+}
+
+// unsafe removal of a specific entry.
+func (s *scheduler) removeEntry(e *entry) {
+	if s.unlink(e) {
+		s.changed(ChangeRemoved, e)
+	}
+}
+
+// unsafe unlinking of a specific entry from the schedule, returning false if it was not scheduled.
+func (s *scheduler) unlink(e *entry) bool {
+	s.backlog = slices.DeleteFunc(s.backlog, func(b *entry) bool { return b == e })
+	if e.queued {
+		e.queued = false
+		s.bucket = slices.DeleteFunc(s.bucket, func(b *entry) bool { return b == e })
+	}
+	for _, m := range []map[int][]*entry{s.tasks, s.once} {
+		for p, v := range m {
+			for i, ee := range v {
+				if ee == e {
+					m[p] = append(v[:i], v[i+1:]...) // order is preserved
+					return true
+				}
+			}
+		}
+	}
+	for i, c := range s.crons {
+		if c.entry == e {
+			s.crons = append(s.crons[:i], s.crons[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Run the next tick now, calling the tasks due, as the ticker of a started scheduler would.
+// It lets tests and simulations drive a scheduler manually, tick by tick, without ever starting it :
+// the duration set with SetDuration, if any, is then used for the tenant shares and the overrun deadline.
+// Like the ticks, Step does nothing while the scheduler is paused. Step returns once the tasks of the tick ended.
+func (s *scheduler) Step() {
+	if s.paused.Load() {
+		return
+	}
+	s.tick()
 }
 
 // Force the next tick from scheduler, calling the active tasks scheduled to run at that time.
-// Task that return an error are removed from scheduler.
+// Task that return an error are handled according to their error policy.
+// Tasks run without holding the task lock, so they may add or remove tasks themselves.
 func (s *scheduler) tick() {
-	start := time.Now()
+	start := s.now()
+	s.lockstats.RLock()
+	duration, ctx := s.duration, s.ctx
+	s.lockstats.RUnlock()
 
-	if s.beforeTick != nil {
-		s.beforeTick(s)
+	s.runHooks(&s.beforeTick, s.beforeTrace)
+	before := s.since(start)
+
+	s.locktasks.Lock()
+	tick := s.ticks + s.inflight // ticks still running when overrunning concurrently
+	s.inflight++
+	expired := s.expire(start)
+	due := s.due(tick)
+	shares := s.tenantShares(duration)
+	s.locktasks.Unlock()
+	s.log(slog.LevelDebug, "tick start", "tick", tick, "due", len(due))
+
+	var deadline time.Time // zero if tasks may start at any time
+	if OverrunPolicy(s.overrun.Load()) == OverrunAbort && duration > 0 {
+		deadline = start.Add(duration)
 	}
+	ctx, span := s.startSpan(ctx, SpanTick)
+	run := s.runDue(ctx, tick, due, shares, deadline)
+	decisions := s.decide(run)
 
 	s.locktasks.Lock()
-	for p, v := range s.tasks {
-		k := s.ticks % p
-		for i := k; i < len(v); i += p {
-			err := v[i].Run()
-			if err != nil { // If tasks returns an error, it is removed from scheduler
-				s.remove(v[i])
+	s.tenantAccount(run.used, run.shed)
+	s.tagMerge(run.tags)
+	s.applyDecisions(run, decisions)
+	s.locktasks.Unlock()
+	s.deadLetters(run, decisions)
+	s.emitRemovals(run, decisions, expired)
+	s.logRuns(run, decisions)
+
+	afterStart := s.now()
+	s.runHooks(&s.afterTick, s.afterTrace)
+	after := s.since(afterStart)
+
+	busy := s.since(start)
+	endTickSpan(span, run, busy)
+	s.locktasks.Lock()
+	s.inflight--
+	s.lockstats.Lock()
+	s.load = s.load + busy
+	s.ticks += 1
+	s.slots += duration
+	s.lastTick, s.maxTick = busy, max(s.maxTick, busy)
+	s.lastHooks = [2]time.Duration{before, after}
+	s.runs += len(run.ran)
+	s.failures += len(run.errs)
+	overran := duration > 0 && busy > duration
+	if overran {
+		s.overruns.Add(1) // counted with the other stats, for consistent snapshots
+	}
+	s.lockstats.Unlock()
+	s.locktasks.Unlock()
+
+	s.emit(Event{Kind: EventTick, Tick: tick, Duration: busy, BeforeHooks: before, AfterHooks: after})
+	s.logTick(tick, run, busy, before, after, duration, overran)
+	if overran {
+		s.signalOverrun(tick, busy)
+	}
+	s.checkDegradation(busy, duration)
+	s.adjust()
+}
+
+// tickRun collects the outcome of the runs of a tick.
+type tickRun struct {
+	tick    int                      // tick number
+	ctx     context.Context          // context of the tick, the tasks contexts derive from it
+	lock    sync.Mutex               // lock for concurrent runs
+	ran     []*entry                 // entries that were run
+	errs    map[*entry]error         // errors of the failed runs
+	used    map[string]time.Duration // time used by each tenant
+	shed    map[string]int           // runs shed for each tenant
+	tags    map[string]*rollup       // runs of each tag
+	ok      map[*entry]bool          // entries that ran successfully
+	deps    map[*entry][]*entry      // due entries each entry depends on, nil if none
+	done    map[*entry]chan struct{} // closed when an entry depended on is over, on the worker pool
+	retired []*entry                 // entries removed after their last run, see AddTimes
+	starts  map[*entry]time.Time     // start of the runs
+	took    map[*entry]time.Duration // duration of the runs
+}
+
+// Run the due entries, serially or on the worker pool.
+// Entries are dispatched in order, the next one starting only once the previous one is started.
+// On the worker pool, entries wait for a worker behind the waiting entries of higher priority.
+// Entries not started before a non zero deadline are not run.
+func (s *scheduler) runDue(ctx context.Context, tick int, due []*entry, shares map[string]time.Duration, deadline time.Time) *tickRun {
+	run := &tickRun{
+		tick:   tick,
+		ctx:    ctx,
+		errs:   map[*entry]error{},
+		used:   map[string]time.Duration{},
+		shed:   map[string]int{},
+		tags:   map[string]*rollup{},
+		ok:     map[*entry]bool{},
+		starts: map[*entry]time.Time{},
+		took:   map[*entry]time.Duration{},
+		deps:   byDependencies(due),
+	}
+	if run.deps != nil && s.pool != nil {
+		run.done = map[*entry]chan struct{}{}
+		for _, dd := range run.deps {
+			for _, d := range dd {
+				run.done[d] = make(chan struct{})
 			}
 		}
 	}
-	s.locktasks.Unlock()
 
-	if s.afterTick != nil {
-		s.afterTick(s)
+	var wg sync.WaitGroup
+	for _, e := range due {
+		if !deadline.IsZero() && s.now().After(deadline) {
+			break // the tick overran, the remaining entries are aborted
+		}
+		if e.cond != nil && !e.cond() {
+			run.finish(e)
+			continue // the condition does not hold at this tick
+		}
+		run.lock.Lock()
+		if max, ok := shares[e.tenant]; ok && run.used[e.tenant] >= max {
+			run.shed[e.tenant]++ // tenant exhausted its share of this tick
+			run.lock.Unlock()
+			run.finish(e)
+			continue
+		}
+		run.lock.Unlock()
+
+		if s.pool == nil {
+			if s.ready(e, run) {
+				s.runRecord(e, run)
+			}
+			continue
+		}
+		if deps := run.deps[e]; len(deps) > 0 { // waits for its dependencies before taking a worker
+			run.lock.Lock()
+			wait := make([]chan struct{}, 0, len(deps))
+			for _, d := range deps {
+				if c, ok := run.done[d]; ok {
+					wait = append(wait, c)
+				}
+			}
+			run.lock.Unlock()
+			wg.Add(1)
+			go func(e *entry) {
+				defer wg.Done()
+				defer run.finish(e)
+				for _, c := range wait {
+					<-c
+				}
+				if !s.ready(e, run) {
+					return
+				}
+				s.pool.acquire(e.priority)
+				defer s.pool.release()
+				s.runRecord(e, run)
+			}(e)
+			continue
+		}
+		s.pool.acquire(e.priority)
+		wg.Add(1)
+		go func(e *entry) {
+			defer wg.Done()
+			defer s.pool.release()
+			defer run.finish(e)
+			s.runRecord(e, run)
+		}(e)
 	}
+	wg.Wait()
+	return run
+}
 
-	s.load = s.load + time.Since(start)
-	s.ticks += 1
+// Run a single entry and record its outcome in run.
+func (s *scheduler) runRecord(e *entry, run *tickRun) {
+	ctx, span := s.startSpan(s.withEnv(run.ctx, e, run.tick), SpanTask)
+	d, ran, err := s.runEntry(ctx, e)
+	endTaskSpan(span, e, run.tick, d, err)
+	if !ran { // neither a success nor a failure, the run belongs to another instance or was done before
+		return
+	}
+	s.emit(Event{Kind: EventRun, Tick: run.tick, Task: e.task, TaskID: e.id, Err: err, Duration: d})
+	if err != nil {
+		s.emit(Event{Kind: EventTaskError, Tick: run.tick, Task: e.task, TaskID: e.id, Err: err, Duration: d})
+	}
+
+	run.lock.Lock()
+	defer run.lock.Unlock()
+
+	run.used[e.tenant] += d
+	run.starts[e], run.took[e] = s.now().Add(-d), d
+	run.tagAccount(e, d, err)
+	run.ran = append(run.ran, e)
+	if err != nil { // the error policy decides what happens to the task
+		run.errs[e] = err
+	} else {
+		run.ok[e] = true
+	}
+}
+
+// Run a single entry with a context derived from ctx, returning its duration and error.
+// It returns false, without running it, if the run is skipped : owned by another instance, or already done.
+func (s *scheduler) runEntry(ctx context.Context, e *entry) (time.Duration, bool, error) {
+	s.track(e, 1)
+	defer s.track(e, -1)
+
+	if !s.inShard(e) || !s.elected(e) {
+		return 0, false, nil // run by another instance
+	}
+	key, fresh := s.beginRun(e)
+	if !fresh {
+		return 0, false, nil // already run before a restart
+	}
+	defer s.endRun(key)
+
+	detect := s.anomalyDetector(e)
+	t := e.target()
+	if key := s.keyLock(e); key != nil { // acquired by the run, within its timeout
+		t = keyedTask{task: t, key: key}
+	}
+	start := s.now()
+	var err error
+	if e.timeout > 0 {
+		err = s.runTimeout(ctx, e, t)
+	} else {
+		ctx, cancel := s.periodContext(ctx, e)
+		err = runTask(ctx, t)
+		cancel()
+	}
+	d := s.since(start)
+	if e.trace != nil {
+		e.trace.recordRun(start, d, err)
+	}
+	if detect != nil {
+		detect()
+	}
+	s.recordSLA(e, d, err)
+	return d, true, err
+}
+
+// Run a task, with ctx if it is a TaskCtx.
+func runTask(ctx context.Context, t Task) error {
+	if tc, ok := t.(TaskCtx); ok {
+		return tc.RunContext(ctx)
+	}
+	return t.Run()
+}
+
+// unsafe list of the entries due at the given tick.
+// Entries with runs to skip are not listed, and have one less run to skip.
+// One-shot entries due at this tick are listed next, and deregistered, followed by due cron entries and
+// catch-up runs.
+// Entries not runnable in the current mode are not listed, one-shot ones being postponed to the next tick.
+// Smoothed entries are queued, and listed when they leak from the bucket.
+// Entries are listed by increasing period, then in their order within the period.
+func (s *scheduler) due(tick int) []*entry {
+	var due []*entry
+	for _, p := range s.periods() {
+		v := s.tasks[p]
+		k := tick % p
+		for i, e := range v {
+			slot := i // implicit phase : the index within the period
+			if e.fixed {
+				slot = e.offset
+			}
+			if slot%p != k {
+				continue
+			}
+			if e.skip > 0 { // backing off after errors
+				e.skip--
+				continue
+			}
+			switch {
+			case !s.runnable(e):
+			case s.smoothed(p):
+				s.enqueue(e)
+			default:
+				due = append(due, e)
+			}
+		}
+	}
+	due = s.leak(due)
+	for _, e := range s.once[tick] { // one-shot tasks run after periodic ones
+		if s.runnable(e) {
+			due = append(due, e)
+			s.changed(ChangeRemoved, e) // over once run
+		} else {
+			s.once[tick+1] = append(s.once[tick+1], e) // postponed, not lost
+		}
+	}
+	delete(s.once, tick)
+	for _, e := range s.dueCron(s.now()) {
+		if s.runnable(e) {
+			due = append(due, e)
+		}
+	}
+	due = s.catchUpDue(due)
+	byPriority(due)
+	return due
+}
+
+// unsafe check that a due entry may run : not paused, and allowed in the current scheduler modes.
+func (s *scheduler) runnable(e *entry) bool {
+	return !e.paused && (!s.maintenance || e.exempt) && (!s.degraded || e.express)
 }
 
 // Start the scheduler asynchoneously, generating ticks every duration.
-// If scheduler was already started, even if stopped, it will panic.
+// A stopped scheduler can be started again, keeping its tasks, hooks and statistics.
+// Starting a running scheduler will panic.
 func (s *scheduler) Start(duration time.Duration) {
+	s.spawn(s.begin(duration))
+}
 
-	if s.ticker != nil {
-		panic("trying to start a scheduler already used, please create a new one and start it")
+// Run the tick loop started by begin in a goroutine.
+func (s *scheduler) spawn(ticker Ticker, ctx context.Context) {
+	go func() {
+		defer s.wg.Done()
+		s.loop(ticker, ctx, nil, 0)
+	}()
+}
+
+// Run n ticks every duration in the calling goroutine, then stop the scheduler and return.
+// Only the ticks that ran count, not the ones suspended by Pause. It returns earlier if the scheduler is stopped.
+// Running a running scheduler will panic, as starting it would. Negative or 0 n does nothing.
+func (s *scheduler) RunFor(n int, duration time.Duration) {
+	if n <= 0 {
+		return
 	}
+	ticker, ctx := s.begin(duration)
+	s.loop(ticker, ctx, nil, n)
+	s.wg.Done()
+	s.stop(StopReasonCompleted)
+}
 
+// Run the ticks every duration in the calling goroutine until ctx is done, then stop the scheduler and return
+// ctx.Err(). It returns nil if the scheduler was stopped first.
+// Running a running scheduler will panic, as starting it would.
+func (s *scheduler) RunUntil(ctx context.Context, duration time.Duration) error {
+	ticker, run := s.begin(duration)
+	s.loop(ticker, run, ctx.Done(), 0)
+	s.wg.Done()
+	if run.Err() != nil { // stopped
+		return nil
+	}
+	s.stop(StopReasonContext)
+	return ctx.Err()
+}
+
+// Start the scheduler state and its ticker, counting the tick loop in the wait group.
+// It returns the ticker and the context of the run, cancelled on stop.
+func (s *scheduler) begin(duration time.Duration) (Ticker, context.Context) {
+	s.lockrun.Lock()
+	defer s.lockrun.Unlock()
+
+	return s.open(duration)
+}
+
+// unsafe begin, lockrun being held.
+func (s *scheduler) open(duration time.Duration) (Ticker, context.Context) {
+	s.lockstats.Lock()
+	if s.running() {
+		s.lockstats.Unlock()
+		panic(newError("trying to start a scheduler already running, please stop it first", ErrAlreadyStarted))
+	}
+	if s.ctx.Err() != nil { // cancelled by the previous stop
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	}
 	s.duration = duration
-	s.ticker = time.NewTicker(duration) // create and start ticker
-	s.wg.Add(1)                         // wait group for the associated goroutine
-	s.actualStartTime = time.Now()      // register actual start date
-	go func() {
-		defer s.wg.Done()
-		for range s.ticker.C {
-			select {
-			case <-s.done:
-				// log.Println("DEBUG : goroutine terminated")
-				return // scheduler close - normal goroutine exit
-			default: // tick
+	s.ticker = s.clock.NewTicker(duration) // create and start ticker
+	s.wg.Add(1)                            // wait group for the tick loop
+	s.actualStartTime = s.now()            // register actual start date
+	s.starts++
+	ticker, ctx := s.ticker, s.ctx
+	s.lockstats.Unlock()
+
+	s.startHooks()
+	s.log(slog.LevelInfo, "scheduler started", "duration", duration)
+	return ticker, ctx
+}
+
+// Run the ticks until ctx is cancelled by a stop, until is closed, or n ticks ran if n is positive.
+// ctx is checked before each tick, so no tick starts once a stop is requested, even if the ticker fired meanwhile.
+func (s *scheduler) loop(ticker Ticker, ctx context.Context, until <-chan struct{}, n int) {
+	var last time.Time // time the last tick was processed
+	for {
+		select {
+		case <-ctx.Done():
+			return // scheduler close - normal exit
+		case <-until:
+			return
+		case now := <-ticker.C():
+			if ctx.Err() != nil {
+				return
+			}
+			if s.paused.Load() {
+				ack(ticker)
+				continue
+			}
+			s.catchUp(last, now)
+			last = now
+			switch OverrunPolicy(s.overrun.Load()) {
+			case OverrunRunConcurrently:
+				s.wg.Add(1) // the tick loop is still counted, stop cannot be waiting yet
+				go func() {
+					defer s.wg.Done()
+					s.tick()
+				}()
+			case OverrunSkip:
+				s.tick()
+				select {
+				case <-ticker.C(): // drop the tick missed while overrunning
+				default:
+				}
+			default:
 				s.tick()
 			}
+			ack(ticker)
+			if n--; n == 0 {
+				return
+			}
 		}
-		log.Println("Unexpected : no more ticks to process")
-	}()
+	}
 }
 
-// Stop the scheduler. Stopping a not started scheduler will panic.
-// A stopped scheduler should not be started nagain or it will panic.
+// Acknowledge a handled tick to the ticker, if it waits for it.
+func ack(t Ticker) {
+	if a, ok := t.(tickAcker); ok {
+		a.ticked()
+	}
+}
+
+// Stop the scheduler, waiting for the tasks of the current tick to finish.
+// No tick starts once Stop is called : at most the tick already running completes, or the ticks running
+// concurrently with OverrunRunConcurrently. Stop returns without waiting for the next tick.
+// A stopped scheduler can be started again. Stopping a scheduler that is not running does nothing.
 func (s *scheduler) Stop() {
+	s.stop(StopReasonStopped)
+}
+
+// Stop the scheduler, recording why.
+func (s *scheduler) stop(reason string) {
+	s.stopContext(context.Background(), reason)
+}
+
+// Stop the scheduler, recording why, waiting for the tasks of the current tick until ctx is done.
+// If ctx is done first, the stop completes in the background once the tasks finish.
+func (s *scheduler) stopContext(ctx context.Context, reason string) error {
+	s.lockrun.Lock()
+
+	s.lockstats.Lock()
+	if !s.running() {
+		s.lockstats.Unlock()
+		s.lockrun.Unlock()
+		return nil
+	}
+	s.cancel() // signal close request, and cancel running tasks
+	s.lockstats.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer s.lockrun.Unlock() // a restart waits for the stop to complete
 
-	if s.ticker == nil {
-		panic("trying to stop a scheduler never started, please create a new one and stop it")
+		s.wg.Wait()   // wait for scheduler to finish tasks in current tick.
+		s.stopHooks() // release the hook goroutine, queued hooks still run
+
+		s.lockstats.Lock()
+		s.ticker.Stop()            // stop ticker
+		s.actualStopTime = s.now() // register actual stop date
+		s.stops++
+		s.uptime += s.actualStopTime.Sub(s.actualStartTime)
+		s.stopReason = reason
+		s.lockstats.Unlock()
+		s.log(slog.LevelInfo, "scheduler stopped", "reason", reason)
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return &StopError{Err: ctx.Err(), Pending: s.inFlight()}
+	}
+}
+
+// Reset the statistics : ticks, load and elapsed durations, hook, tenant and tag statistics.
+// Since task phases depend on the tick count, tasks restart their cycle as if they were just added.
+// Lifetime counters are not reset.
+func (s *scheduler) ResetStats() {
+	s.locktasks.Lock() // the tick count also sets the task phases
+	s.lockstats.Lock()
+	s.ticks = 0
+	s.slots = 0
+	s.load = 0
+	s.lastTick, s.maxTick = 0, 0
+	s.lastHooks = [2]time.Duration{}
+	s.runs, s.failures = 0, 0
+	if s.running() {
+		s.actualStartTime = s.now()
 	}
-	s.done <- struct{}{}          // signal close request
-	s.wg.Wait()                   // wait for scheduler to finish tasks in current tick.
-	s.actualStopTime = time.Now() // register actual stop date
-	s.ticker.Stop()               // stop ticker
+	s.lockstats.Unlock()
+	for _, tn := range s.tenants {
+		tn.busy, tn.shed = 0, 0
+	}
+	clear(s.tags)
+	s.locktasks.Unlock()
 
-	return
+	s.beforeTrace.Reset()
+	s.afterTrace.Reset()
+	s.overrunTrace.Reset()
+	s.overruns.Store(0)
 }
 
 // Number of active tasks.
@@ -238,34 +1167,66 @@ func (s *scheduler) Tasks() int {
 	for _, v := range s.tasks {
 		nb += len(v)
 	}
-	return nb
+	for _, v := range s.once {
+		nb += len(v)
+	}
+	return nb + len(s.crons)
 }
 
 // Return load as a percentage of the time spent running tasks versus duration between ticks.
-// Calculation will be wrong if duration was changed.
 func (s *scheduler) Load() float64 {
 	s.lockstats.RLock()
 	defer s.lockstats.RUnlock()
-	if s.ticks == 0 {
+	if s.ticks == 0 || s.elapsed() <= 0 { // no load before the tick duration is known
 		return 0.
 	}
-	return float64(s.load) / float64(s.Elapsed())
+	return float64(s.load) / float64(s.elapsed())
 }
 
 // Return the calculated elapsed duration since last start, based on actual tick slots used.
+// Each tick counts for the tick duration in effect when it ran, so changing the duration keeps it correct.
 // Calculation is underestimated when tasks overruns and some ticks are skipped.
 func (s *scheduler) Elapsed() time.Duration {
 	s.lockstats.RLock()
 	defer s.lockstats.RUnlock()
 
-	return s.duration * (time.Duration)(s.ticks)
+	return s.elapsed()
+}
+
+// unsafe elapsed
+func (s *scheduler) elapsed() time.Duration {
+	return s.slots
+}
+
+// Change the tick duration. A running scheduler resets its ticker, the next tick happens d from now.
+// Negative or 0 durations are ignored.
+func (s *scheduler) SetDuration(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	s.lockstats.Lock()
+	defer s.lockstats.Unlock()
+
+	s.duration = d
+	if s.running() {
+		s.ticker.Reset(d)
+	}
 }
 
 // Return the actual elapsed time since last start.
+// It is measured with the monotonic clock, so wall-clock steps (NTP, manual changes) do not affect it.
 func (s *scheduler) ActualElapsed() time.Duration {
-	if s.actualStopTime.Before(s.actualStartTime) {
+	s.lockstats.RLock()
+	defer s.lockstats.RUnlock()
+
+	return s.actualElapsed()
+}
+
+// unsafe actual elapsed
+func (s *scheduler) actualElapsed() time.Duration {
+	if s.running() {
 		// currently running ...
-		return time.Since(s.actualStartTime)
+		return s.since(s.actualStartTime)
 	}
 	return s.actualStopTime.Sub(s.actualStartTime)
 }
@@ -1,7 +1,20 @@
 package scheduler
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -178,3 +191,3245 @@ func ps(s Scheduler, trace *TaskTracer) {
 		trace.MaxDuration(),
 		trace.StandardDeviationDuration())
 }
+
+type sleepTask time.Duration
+
+func (t sleepTask) Run() error {
+	time.Sleep(time.Duration(t))
+	return nil
+}
+
+func TestTenantQuota(t *testing.T) {
+	s := New()
+	s.SetTenantBudget("a", TenantBudget{MaxTasks: 2, MaxShare: 0.5})
+
+	t1, t2, t3 := sleepTask(10*time.Millisecond), sleepTask(11*time.Millisecond), sleepTask(12*time.Millisecond)
+	if err := s.AddTenant("a", 1, t1, t2, t3); err != ErrTenantQuota {
+		t.Fatalf("Expected quota error, got %v", err)
+	}
+	if err := s.AddTenant("a", 1, t1, t2); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	s.Add(1, t3)
+
+	s.(*scheduler).duration = 10 * time.Millisecond
+	s.(*scheduler).tick()
+
+	st := s.TenantStats("a")
+	if st.Tasks != 2 || st.Shed != 1 {
+		t.Fatalf("Expected 2 tasks and 1 shed run, got %+v", st)
+	}
+	if st.Busy < 10*time.Millisecond || st.Load <= 0 {
+		t.Fatalf("Expected tenant load to be accounted, got %+v", st)
+	}
+	if s.TenantStats("").Shed != 0 {
+		t.Fatalf("Expected no shed run for tasks without tenant")
+	}
+}
+
+func TestAsyncHooks(t *testing.T) {
+	s := New(WithAsyncHooks(1))
+	release, started := make(chan struct{}), make(chan struct{}, 10)
+	calls := make(chan struct{}, 10)
+	s.SetAfter(func(_ Scheduler) {
+		started <- struct{}{}
+		<-release // a slow observer
+		calls <- struct{}{}
+	})
+	s.(*scheduler).startHooks()
+
+	start := time.Now()
+	s.(*scheduler).tick()
+	<-started
+	s.(*scheduler).tick()
+	s.(*scheduler).tick()
+	if time.Since(start) > time.Second {
+		t.Fatalf("Slow hook extended the ticks")
+	}
+	// first call is running, second is queued, third is dropped
+	if s.DroppedHooks() != 1 {
+		t.Fatalf("Expected 1 dropped hook, got %d", s.DroppedHooks())
+	}
+	close(release)
+	<-calls
+	<-calls
+	s.(*scheduler).stopHooks()
+}
+
+func TestHookGuard(t *testing.T) {
+	s := New(WithHookTimeout(10 * time.Millisecond))
+	var events []Event
+	s.Subscribe(EventHookAbandoned, func(ev Event) { events = append(events, ev) })
+
+	s.SetBefore(func(_ Scheduler) { panic("boom") })
+	s.(*scheduler).tick()
+	if len(events) != 1 || events[0].Err == nil {
+		t.Fatalf("Expected a panic event, got %v", events)
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	s.SetBefore(nil)
+	s.SetAfter(func(_ Scheduler) { <-block })
+	s.(*scheduler).tick()
+	if len(events) != 2 || !errors.Is(events[1].Err, ErrHookTimeout) || events[1].Tick != 1 {
+		t.Fatalf("Expected a timeout event, got %v", events)
+	}
+}
+
+func TestHookStats(t *testing.T) {
+	s := New()
+	s.SetBefore(func(_ Scheduler) { time.Sleep(time.Millisecond) })
+	for i := 0; i < 3; i++ {
+		s.(*scheduler).tick()
+	}
+	before, after := s.HookStats()
+	if before.Count != 3 || before.Max < time.Millisecond {
+		t.Fatalf("Expected 3 traced runs of at least 1ms, got %d, max %v", before.Count, before.Max)
+	}
+	if after.Count != 0 {
+		t.Fatalf("Expected no traced run for a missing hook, got %d", after.Count)
+	}
+
+	var ev Event
+	s.Subscribe(EventTick, func(e Event) { ev = e })
+	s.(*scheduler).tick()
+	if st := s.Stats(); st.BeforeHooks < time.Millisecond || st.AfterHooks > st.BeforeHooks || st.BeforeHooks > st.LastTick {
+		t.Fatalf("Expected the hook durations of the last tick, got %v and %v", st.BeforeHooks, st.AfterHooks)
+	}
+	if ev.BeforeHooks < time.Millisecond || ev.BeforeHooks > ev.Duration {
+		t.Fatalf("Expected the hook durations in the tick event, got %+v", ev)
+	}
+}
+
+type ctxTask chan struct{}
+
+func (t ctxTask) Run() error { return nil }
+
+func (t ctxTask) RunContext(ctx context.Context) error {
+	t <- struct{}{}
+	<-ctx.Done() // run until cancelled
+	return ctx.Err()
+}
+
+func TestTaskContext(t *testing.T) {
+	s := New()
+	task := make(ctxTask, 1)
+	s.Add(1, task)
+	s.Start(time.Millisecond)
+	<-task // task is running
+	s.Stop()
+	if s.Tasks() != 0 {
+		t.Fatalf("Expected cancelled task to be removed, got %d tasks", s.Tasks())
+	}
+}
+
+func TestTopSlow(t *testing.T) {
+	s := New()
+	fast, slow := Trace(sleepTask(0)), Trace(sleepTask(2*time.Millisecond))
+	s.Add(1, fast, slow, testTask(0))
+	s.(*scheduler).tick()
+
+	top := s.TopSlow(1)
+	if len(top) != 1 || top[0].Task != slow || top[0].Count != 1 {
+		t.Fatalf("Expected the slow task first, got %+v", top)
+	}
+	if len(s.TopSlow(-1)) != 2 {
+		t.Fatalf("Expected only traced tasks")
+	}
+
+	steady, spiky := Trace(NoopTask()), Trace(NoopTask())
+	for i := 0; i < 100; i++ {
+		steady.record(2 * time.Millisecond)
+		spiky.record(time.Duration(i/98) * 50 * time.Millisecond) // 2 runs of 50ms
+	}
+	s = New()
+	s.Add(1, steady, spiky)
+	if top := s.TopSlow(1); len(top) != 1 || top[0].Task != steady {
+		t.Fatalf("Expected the task with the highest average first, got %+v", top)
+	}
+	if top := s.TopSlowP99(-1); len(top) != 2 || top[0].Task != spiky || top[0].P99 != 50*time.Millisecond {
+		t.Fatalf("Expected the task with the highest 99th percentile first, got %+v", top)
+	}
+}
+
+func TestTraceDecayClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := New(WithClock(clock))
+	tr := Trace(NoopTask())
+	tr.SetHalfLife(time.Minute)
+	s.Add(1, tr)
+	for i := 0; i < 2*TraceSamples; i++ {
+		tr.record(time.Millisecond)
+	}
+	clock.Advance(10 * time.Minute) // the weight of the previous runs is divided by 1024
+	for i := 0; i < TraceSamples; i++ {
+		tr.record(100 * time.Millisecond)
+	}
+	if c := tr.Count(); c < TraceSamples || c > TraceSamples+2 {
+		t.Fatalf("Expected the count to decay on the scheduler clock, got %d", c)
+	}
+	if p := tr.Percentile(1); p != 100*time.Millisecond || len(tr.samples) != TraceSamples {
+		t.Fatalf("Expected the sample to describe the recent runs, got P1 %v", p)
+	}
+}
+
+type varTask struct{ d *time.Duration }
+
+func (t varTask) Run() error {
+	time.Sleep(*t.d)
+	return nil
+}
+
+func TestAnomaly(t *testing.T) {
+	s := New(WithAnomalyDetection(5))
+	d := time.Millisecond
+	tr := Trace(varTask{&d})
+	s.Add(1, tr)
+	var events []Event
+	s.Subscribe(EventAnomaly, func(ev Event) { events = append(events, ev) })
+
+	for i := 0; i < anomalyMinRuns; i++ {
+		s.(*scheduler).tick()
+	}
+	d = 50 * time.Millisecond
+	s.(*scheduler).tick()
+	if len(events) != 1 || events[0].Task != tr || events[0].Duration < d {
+		t.Fatalf("Expected one anomaly, got %v", events)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	s := New()
+	var events []Event
+	s.Subscribe(EventTaskTimeout, func(ev Event) { events = append(events, ev) })
+
+	task := make(ctxTask, 1)
+	s.AddWithTimeout(1, 10*time.Millisecond, task, sleepTask(0))
+	s.(*scheduler).tick()
+
+	if s.Tasks() != 1 {
+		t.Fatalf("Expected the timed out task to be removed, got %d tasks", s.Tasks())
+	}
+	if len(events) != 1 || events[0].Task != task || !errors.Is(events[0].Err, ErrTaskTimeout) {
+		t.Fatalf("Expected a timeout event, got %v", events)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	s := New(WithConcurrency(4))
+	d := 20 * time.Millisecond
+	s.Add(1, sleepTask(d), sleepTask(d), sleepTask(d), sleepTask(d))
+
+	start := time.Now()
+	s.(*scheduler).tick()
+	if el := time.Since(start); el < d || el > 3*d {
+		t.Fatalf("Expected tasks to run concurrently, tick lasted %v", el)
+	}
+	if s.New().(*scheduler).workers != 4 {
+		t.Fatalf("Expected copied scheduler to keep its concurrency")
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	s := New(WithCatchUp(2), WithSmoothing(10, 3), WithHookTimeout(time.Second), WithHeartbeat(1))
+	c := s.New().(*scheduler)
+	if c.catchup != 2 || c.smoothRate != 3 || c.hookLimit.Load() != int64(time.Second) {
+		t.Fatalf("Expected copied scheduler to keep its options")
+	}
+	if c.heartbeat == nil || c.heartbeat.task.(*heartbeatTask).s != c {
+		t.Fatalf("Expected copied scheduler to run its own heartbeat")
+	}
+	c.Step()
+	if c.LastHeartbeat().IsZero() || !s.LastHeartbeat().IsZero() {
+		t.Fatalf("Expected the heartbeat of the copy to be recorded by the copy only")
+	}
+}
+
+func TestSLA(t *testing.T) {
+	s := New()
+	d := time.Duration(0)
+	task := varTask{&d}
+	s.Add(1, task)
+	if !s.SetSLA(task, SLA{Within: 5 * time.Millisecond, Target: 0.75, Window: 4}) {
+		t.Fatalf("Expected SLA to be set")
+	}
+	var events []Event
+	s.Subscribe(EventSLABreach, func(ev Event) { events = append(events, ev) })
+
+	for i := 0; i < 3; i++ {
+		s.(*scheduler).tick()
+	}
+	d = 10 * time.Millisecond
+	s.(*scheduler).tick() // 3 of 4 compliant
+	if c, _ := s.SLACompliance(task); c != 0.75 || len(events) != 0 {
+		t.Fatalf("Expected 0.75 compliance without breach, got %v, %v", c, events)
+	}
+	s.(*scheduler).tick() // 2 of 4 compliant
+	if c, _ := s.SLACompliance(task); c != 0.5 || len(events) != 1 {
+		t.Fatalf("Expected 0.5 compliance with a breach, got %v, %v", c, events)
+	}
+	s.(*scheduler).tick()
+	if len(events) != 1 {
+		t.Fatalf("Expected a single breach event, got %v", events)
+	}
+}
+
+type countTask struct {
+	runs *int
+	err  error
+}
+
+func (t countTask) Run() error {
+	*t.runs++
+	return t.err
+}
+
+func TestErrorPolicy(t *testing.T) {
+	s := New()
+	fail := errors.New("fail")
+	var retried, backedOff, ignored int
+	var handled []error
+	s.SetErrorHandler(func(_ Task, err error) { handled = append(handled, err) })
+
+	s.AddWithPolicy(1, Retry(2), countTask{&retried, fail})
+	s.AddWithPolicy(1, Backoff(4), countTask{&backedOff, fail})
+	s.SetErrorPolicy(IgnoreErrors)
+	s.Add(1, countTask{&ignored, fail})
+
+	for i := 0; i < 10; i++ {
+		s.(*scheduler).tick()
+	}
+	if retried != 3 {
+		t.Fatalf("Expected 3 attempts before removal, got %d", retried)
+	}
+	// runs at ticks 0, 2, 5, 10 : skipping 1, 2, then 4 runs
+	if backedOff != 3 {
+		t.Fatalf("Expected 3 runs with backoff, got %d", backedOff)
+	}
+	if ignored != 10 {
+		t.Fatalf("Expected 10 runs when ignoring errors, got %d", ignored)
+	}
+	if s.Tasks() != 2 || len(handled) != retried+backedOff+ignored {
+		t.Fatalf("Expected 2 tasks and all errors handled, got %d tasks, %d errors", s.Tasks(), len(handled))
+	}
+}
+
+type flipTask struct{ runs *int }
+
+func (t flipTask) Run() error {
+	*t.runs++
+	if *t.runs%2 == 0 {
+		return errors.New("flapping")
+	}
+	return nil
+}
+
+func TestSuccessRate(t *testing.T) {
+	s := New()
+	s.SetErrorPolicy(IgnoreErrors)
+	runs := 0
+	task := flipTask{&runs}
+	s.Add(1, task)
+	if r, ok := s.SuccessRate(task); !ok || r != 1 {
+		t.Fatalf("Expected a success rate of 1 before any run, got %v", r)
+	}
+	for i := 0; i < 10; i++ {
+		s.(*scheduler).tick()
+	}
+	o, ok := s.Outcomes(task)
+	if !ok || o.Successes != 5 || o.Failures != 5 || o.Rate != 0.5 {
+		t.Fatalf("Expected 5 successes and 5 failures, got %+v", o)
+	}
+	if _, ok := s.SuccessRate(testTask(0)); ok {
+		t.Fatalf("Expected no success rate for an unknown task")
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	s := New()
+	var once, at int
+	s.RunOnce(2, countTask{&once, nil})
+	s.RunAt(1, countTask{&at, errors.New("ignored")})
+	if s.Tasks() != 2 {
+		t.Fatalf("Expected 2 pending tasks, got %d", s.Tasks())
+	}
+	s.(*scheduler).tick()
+	if once != 0 || at != 0 {
+		t.Fatalf("Expected no run at tick 0")
+	}
+	s.(*scheduler).tick()
+	if once != 0 || at != 1 || s.Tasks() != 1 {
+		t.Fatalf("Expected RunAt task to run once at tick 1, got %d runs, %d tasks", at, s.Tasks())
+	}
+	s.RunAt(0, countTask{&at, nil}) // past tick, runs at next tick
+	for i := 0; i < 5; i++ {
+		s.(*scheduler).tick()
+	}
+	if once != 1 || at != 2 || s.Tasks() != 0 {
+		t.Fatalf("Expected each task to run once, got %d, %d runs, %d tasks", once, at, s.Tasks())
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	s := New()
+	if lt := s.Lifetime(); lt.Starts != 0 || lt.Running || lt.StopReason != "" {
+		t.Fatalf("Unexpected lifetime for a new scheduler %+v", lt)
+	}
+	s.Start(time.Millisecond)
+	if lt := s.Lifetime(); lt.Starts != 1 || !lt.Running {
+		t.Fatalf("Unexpected lifetime for a running scheduler %+v", lt)
+	}
+	time.Sleep(5 * time.Millisecond)
+	s.Stop()
+	lt := s.Lifetime()
+	if lt.Running || lt.StopReason != StopReasonStopped || lt.Uptime < 5*time.Millisecond || lt.Uptime != s.ActualElapsed() {
+		t.Fatalf("Unexpected lifetime for a stopped scheduler %+v", lt)
+	}
+}
+
+func TestDegradation(t *testing.T) {
+	s := New(WithDegradation(0.5, 0.1))
+	var heavy, express int
+	var kinds []EventKind
+	s.Subscribe(EventDegraded|EventRestored, func(ev Event) { kinds = append(kinds, ev.Kind) })
+	s.Add(1, countTask{&heavy, nil}, sleepTask(20*time.Millisecond))
+	s.AddExpress(1, countTask{&express, nil})
+	s.(*scheduler).duration = 10 * time.Millisecond
+
+	for i := 0; i < 3 && !s.Degraded(); i++ {
+		s.(*scheduler).tick()
+	}
+	if !s.Degraded() || len(kinds) != 1 || kinds[0] != EventDegraded {
+		t.Fatalf("Expected degraded mode, got events %v", kinds)
+	}
+	h := heavy
+	for i := 0; i < 20 && s.Degraded(); i++ {
+		s.(*scheduler).tick()
+	}
+	if s.Degraded() || len(kinds) != 2 || kinds[1] != EventRestored {
+		t.Fatalf("Expected restored mode, got events %v", kinds)
+	}
+	if heavy != h || express <= h {
+		t.Fatalf("Expected only express tasks to run while degraded, got %d heavy, %d express", heavy, express)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	s := New()
+	s.Start(time.Millisecond)
+	defer s.Stop()
+
+	s.Pause()
+	time.Sleep(5 * time.Millisecond) // let a tick in progress complete
+	ticks := s.Ticks()
+	time.Sleep(20 * time.Millisecond)
+	if !s.Paused() || s.Ticks() != ticks {
+		t.Fatalf("Expected no tick while paused, got %d then %d", ticks, s.Ticks())
+	}
+	s.Resume()
+	time.Sleep(20 * time.Millisecond)
+	if s.Paused() || s.Ticks() == ticks {
+		t.Fatalf("Expected ticks after resume")
+	}
+}
+
+type deadlineTask struct{ left *time.Duration }
+
+func (t deadlineTask) Run() error { return nil }
+
+func (t deadlineTask) RunContext(ctx context.Context) error {
+	if dl, ok := ctx.Deadline(); ok {
+		*t.left = time.Until(dl)
+	}
+	return nil
+}
+
+func TestPeriodDeadline(t *testing.T) {
+	var left time.Duration
+	s := New()
+	s.Add(3, deadlineTask{&left})
+	s.(*scheduler).duration = time.Second
+	s.(*scheduler).tick()
+	if left != 0 {
+		t.Fatalf("Expected no deadline by default")
+	}
+
+	s = New(WithPeriodDeadline())
+	s.Add(3, deadlineTask{&left})
+	s.(*scheduler).duration = time.Second
+	s.(*scheduler).tick()
+	if left <= 2*time.Second || left > 3*time.Second {
+		t.Fatalf("Expected a deadline of 3 ticks, got %v", left)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	s := New()
+	runs := 0
+	s.Add(1, countTask{&runs, nil})
+	s.Stop() // not running, does nothing
+
+	for i := 0; i < 2; i++ {
+		s.Start(time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		s.Stop()
+		s.Stop()
+		if runs == 0 || s.Tasks() != 1 {
+			t.Fatalf("Expected the task to run after start %d, got %d runs, %d tasks", i+1, runs, s.Tasks())
+		}
+	}
+	if lt := s.Lifetime(); lt.Starts != 2 || lt.Running {
+		t.Fatalf("Expected 2 starts, got %+v", lt)
+	}
+	if s.Ticks() != runs {
+		t.Fatalf("Expected ticks to accumulate across restarts, got %d ticks for %d runs", s.Ticks(), runs)
+	}
+	s.ResetStats()
+	if s.Ticks() != 0 || s.Load() != 0 {
+		t.Fatalf("Expected stats to be reset")
+	}
+}
+
+func TestRequestScoped(t *testing.T) {
+	runs := make(chan struct{}, 100)
+	h := RequestScoped(time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := FromContext(r.Context())
+		if !ok {
+			t.Errorf("Expected a request scheduler")
+			return
+		}
+		s.Add(1, chanTask(runs))
+		<-runs // polled at least once during the request
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New()
+	StartContext(ctx, s, time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	if lt := s.Lifetime(); lt.Running || lt.StopReason != StopReasonContext {
+		t.Fatalf("Expected scheduler to stop with its context, got %+v", lt)
+	}
+}
+
+type chanTask chan struct{}
+
+func (t chanTask) Run() error {
+	select {
+	case t <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func TestAddBatch(t *testing.T) {
+	s := New()
+	s.SetTenantBudget("a", TenantBudget{MaxTasks: 1})
+
+	errs := s.AddBatch([]Spec{
+		{Period: 1, Task: sleepTask(1)},
+		{Period: 0, Task: sleepTask(2)},
+		{Period: 2, Task: nil},
+		{Period: 3, Task: sleepTask(3), Tenant: "a"},
+		{Period: 3, Task: sleepTask(4), Tenant: "a"},
+		{Period: 4, Task: sleepTask(5), Express: true, Timeout: time.Second},
+	})
+	want := []error{nil, ErrInvalidPeriod, ErrNilTask, nil, ErrTenantQuota, nil}
+	for i, err := range errs {
+		if err != want[i] {
+			t.Fatalf("Spec %d : expected %v, got %v", i, want[i], err)
+		}
+	}
+	if n := s.Tasks(); n != 3 {
+		t.Fatalf("Expected 3 tasks, got %d", n)
+	}
+	if e := s.(*scheduler).tasks[4][0]; !e.express || e.timeout != time.Second {
+		t.Fatalf("Expected spec options to be applied, got %+v", e)
+	}
+}
+
+func TestSetDuration(t *testing.T) {
+	s := New()
+	ss := s.(*scheduler)
+	ss.duration = 10 * time.Millisecond
+	ss.tick()
+	ss.tick()
+	s.SetDuration(time.Millisecond)
+	ss.tick()
+	if el := s.Elapsed(); el != 21*time.Millisecond {
+		t.Fatalf("Expected elapsed to follow the duration change, got %v", el)
+	}
+
+	s.ResetStats()
+	s.Start(time.Hour)
+	s.SetDuration(time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	s.Stop()
+	if s.Ticks() == 0 {
+		t.Fatalf("Expected the ticker to be reset to the new duration")
+	}
+	if el := s.Elapsed(); el != time.Duration(s.Ticks())*time.Millisecond {
+		t.Fatalf("Expected elapsed of %d ticks, got %v", s.Ticks(), el)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	runs := 0
+	plan := NewBuilder().
+		Every(1).Named("count").WithTimeout(time.Second).Do(func() error { runs++; return nil }).
+		Every(2).Named("sleep").Express().Run(sleepTask(1)).
+		Named("orphan").
+		Build()
+	if len(plan) != 2 || plan[0].Name != "count" || plan[0].Timeout != time.Second || !plan[1].Express || plan[1].Name != "orphan" {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	if len(NewBuilder().Named("x").Build()) != 1 {
+		t.Fatalf("Expected a spec without period")
+	}
+
+	s := New()
+	if err := plan.Apply(s); err != nil {
+		t.Fatal(err)
+	}
+	s.(*scheduler).tick()
+	s.(*scheduler).tick()
+	if runs != 2 || s.Tasks() != 2 {
+		t.Fatalf("Expected 2 runs of 2 tasks, got %d runs of %d tasks", runs, s.Tasks())
+	}
+}
+
+func TestNamed(t *testing.T) {
+	s := New()
+	if err := s.AddNamed("a", 1, sleepTask(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddNamed("a", 2, sleepTask(2)); err != ErrDuplicateName {
+		t.Fatalf("Expected duplicate name error, got %v", err)
+	}
+	if err := s.AddBatch([]Spec{{Name: "b", Period: 3, Task: sleepTask(3)}})[0]; err != nil {
+		t.Fatal(err)
+	}
+	s.Add(1, sleepTask(1)) // same value, unnamed
+
+	if tt, ok := s.Lookup("b"); !ok || tt != sleepTask(3) {
+		t.Fatalf("Expected to find task b, got %v", tt)
+	}
+	if n := s.Names(); len(n) != 2 || n[0] != "a" || n[1] != "b" {
+		t.Fatalf("Unexpected names %v", n)
+	}
+	if !s.RemoveByName("a") || s.RemoveByName("a") || s.Tasks() != 2 {
+		t.Fatalf("Expected only the named task to be removed, %d tasks left", s.Tasks())
+	}
+	if _, ok := s.Lookup("a"); ok {
+		t.Fatalf("Expected name a to be released")
+	}
+}
+
+func TestTaskHandle(t *testing.T) {
+	s := New()
+	runs := 0
+	task := countTask{runs: &runs}
+	hs := s.Add(1, task, task)
+	if len(hs) != 2 || hs[0].Task() != task {
+		t.Fatalf("Expected 2 handles, got %v", hs)
+	}
+	ss := s.(*scheduler)
+
+	hs[0].Pause()
+	ss.tick()
+	if runs != 1 || !hs[0].Stats().Paused {
+		t.Fatalf("Expected only the second registration to run, got %d runs", runs)
+	}
+	hs[0].Resume()
+	ss.tick()
+	if runs != 3 {
+		t.Fatalf("Expected both registrations to run, got %d runs", runs)
+	}
+
+	if !hs[1].Reschedule(3) || hs[1].Stats().Period != 3 || hs[1].Stats().Outcomes.Successes != 2 {
+		t.Fatalf("Expected rescheduling to keep history, got %+v", hs[1].Stats())
+	}
+	if !hs[0].Cancel() || hs[0].Cancel() || hs[0].Stats().Active {
+		t.Fatalf("Expected cancel to remove the first registration only once")
+	}
+	if s.Tasks() != 1 || !hs[1].Stats().Active {
+		t.Fatalf("Expected the second registration to remain, got %d tasks", s.Tasks())
+	}
+}
+
+func TestSchedulePlan(t *testing.T) {
+	plan := NewBuilder().
+		Every(2).Named("a").Run(sleepTask(1)).
+		Every(1).Named("b").Run(sleepTask(2)).
+		Every(2).Named("c").WithTimeout(time.Second).Run(sleepTask(3)).
+		Build()
+
+	pv := plan.Preview(2)
+	if fmt.Sprint(pv) != "[[1 0] [1 2]]" {
+		t.Fatalf("Unexpected preview %v", pv)
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back SchedulePlan
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if err := back.Bind(map[string]Task{"a": sleepTask(1)}); !errors.Is(err, ErrUnknownTask) {
+		t.Fatalf("Expected unknown task error, got %v", err)
+	}
+	if err := back.Bind(map[string]Task{"a": sleepTask(1), "b": sleepTask(2), "c": sleepTask(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if back[2].Timeout != time.Second || back[2].Task != sleepTask(3) {
+		t.Fatalf("Unexpected round trip %+v", back[2])
+	}
+
+	s := New()
+	s.AddNamed("c", 1, sleepTask(4))
+	if err := back.Apply(s); !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("Expected duplicate name error, got %v", err)
+	}
+	if s.Tasks() != 1 {
+		t.Fatalf("Expected a rejected plan to add nothing, got %d tasks", s.Tasks())
+	}
+	s.RemoveByName("c")
+	if err := back.Apply(s); err != nil || s.Tasks() != 3 {
+		t.Fatalf("Expected the plan to be applied, got %v with %d tasks", err, s.Tasks())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	runs := 0
+	a := NewBuilder().
+		Every(1).Named("keep").Run(sleepTask(1)).
+		Every(2).Named("change").Run(sleepTask(2)).
+		Every(3).Named("drop").Run(sleepTask(3)).
+		Every(4).Run(countTask{runs: &runs}).
+		Build()
+	b := NewBuilder().
+		Every(1).Named("keep").Run(sleepTask(1)).
+		Every(5).Named("change").Run(sleepTask(2)).
+		Every(4).Run(countTask{runs: &runs}).
+		Every(6).Named("new").Run(sleepTask(6)).
+		Build()
+
+	d := Diff(a, b)
+	if len(d.Added) != 1 || d.Added[0].Name != "new" || len(d.Removed) != 1 || d.Removed[0].Name != "drop" ||
+		len(d.Modified) != 1 || d.Modified[0].New.Period != 5 {
+		t.Fatalf("Unexpected diff %+v", d)
+	}
+	if !Diff(b, b).Empty() {
+		t.Fatalf("Expected no change between identical plans")
+	}
+	if d := Diff(NewBuilder().Every(1).Do(nil).Build(), NewBuilder().Every(1).Do(nil).Build()); len(d.Added) != 1 {
+		t.Fatalf("Expected distinct function tasks not to match, got %+v", d)
+	}
+
+	s := New()
+	if err := a.Apply(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplyDiff(d); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Names(); fmt.Sprint(n) != "[change keep new]" || s.Tasks() != 4 {
+		t.Fatalf("Unexpected names %v with %d tasks", n, s.Tasks())
+	}
+	if e := s.(*scheduler).named("change"); e.period != 5 {
+		t.Fatalf("Expected change to be rescheduled, got period %d", e.period)
+	}
+
+	s.AddNamed("other", 1, sleepTask(7))
+	bad := PlanDiff{Removed: []Spec{{Name: "keep"}}, Added: []Spec{{Name: "other", Period: 1, Task: sleepTask(8)}}}
+	if err := s.ApplyDiff(bad); !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("Expected duplicate name error, got %v", err)
+	}
+	if _, ok := s.Lookup("keep"); !ok {
+		t.Fatalf("Expected a rejected diff to be rolled back")
+	}
+}
+
+func TestAddWithOffset(t *testing.T) {
+	s := New()
+	ss := s.(*scheduler)
+	var a, b, c int
+	s.Add(3, countTask{runs: &a})
+	h := s.AddWithOffset(3, 5, countTask{runs: &b}) // same as offset 2
+	s.AddWithOffset(3, 0, countTask{runs: &c})
+
+	ran := []string{}
+	for i := 0; i < 3; i++ {
+		a0, b0, c0 := a, b, c
+		ss.tick()
+		ran = append(ran, fmt.Sprint(a-a0, b-b0, c-c0))
+	}
+	if fmt.Sprint(ran) != "[1 0 1 0 0 0 0 1 0]" {
+		t.Fatalf("Unexpected runs %v", ran)
+	}
+	if !h.Reschedule(2) { // due at tick 5, the phase is kept : odd ticks
+		t.Fatalf("Expected reschedule to succeed")
+	}
+	if o, _ := h.Offset(); o.NextTick != 3 {
+		t.Fatalf("Expected the next run at the next odd tick, got %d", o.NextTick)
+	}
+	ss.ticks = 5
+	b0 := b
+	ss.tick()
+	if b != b0+1 {
+		t.Fatalf("Expected a run at an odd tick")
+	}
+	ss.tick()
+	if b != b0+1 {
+		t.Fatalf("Expected no run at an even tick")
+	}
+
+	plan := NewBuilder().Every(2).Run(sleepTask(1)).Every(2).AtOffset(0).Run(sleepTask(2)).Build()
+	if pv := plan.Preview(2); fmt.Sprint(pv) != "[[0 1] []]" {
+		t.Fatalf("Unexpected preview %v", pv)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	s := New()
+	runs := 0
+	task := countTask{runs: &runs}
+	h := s.Add(2, task)[0]
+
+	if s.Throttle(task, 0) || s.Throttle(sleepTask(1), 2) || s.Unthrottle(task) {
+		t.Fatalf("Expected invalid throttling to be rejected")
+	}
+	if !s.Throttle(task, 2.4) || h.Stats().Period != 5 {
+		t.Fatalf("Expected period 5, got %d", h.Stats().Period)
+	}
+	s.Throttle(task, 0.1)
+	if h.Stats().Period != 1 {
+		t.Fatalf("Expected period to be throttled from the original one, down to 1, got %d", h.Stats().Period)
+	}
+	for i := 0; i < 4; i++ {
+		s.(*scheduler).tick()
+	}
+	if runs != 4 {
+		t.Fatalf("Expected 4 runs, got %d", runs)
+	}
+	if !s.Unthrottle(task) || h.Stats().Period != 2 || s.Unthrottle(task) {
+		t.Fatalf("Expected original period to be restored, got %d", h.Stats().Period)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	s := New()
+	order := []int{}
+	rec := func(i int) Task { return &funcTask{fn: func() error { order = append(order, i); return nil }} }
+	s.Add(1, rec(0))
+	s.AddWithPriority(2, 5, rec(1))
+	s.AddWithPriority(1, -1, rec(2))
+	s.RunOnce(0, rec(3))
+	s.AddWithPriority(3, 5, rec(4))
+
+	s.(*scheduler).tick()
+	if fmt.Sprint(order) != "[1 4 0 3 2]" {
+		t.Fatalf("Unexpected order %v", order)
+	}
+
+	plan := NewBuilder().Every(1).Run(sleepTask(1)).Every(1).WithPriority(1).Run(sleepTask(2)).Build()
+	if pv := plan.Preview(1); fmt.Sprint(pv) != "[[1 0]]" {
+		t.Fatalf("Unexpected preview %v", pv)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	s := New()
+	ss := s.(*scheduler)
+	var normal, exempt, once int
+	s.Add(1, countTask{runs: &normal})
+	s.AddExempt(1, countTask{runs: &exempt})
+	s.AddBatch([]Spec{{Period: 1, Task: countTask{runs: &exempt}, Exempt: true}})
+
+	s.EnterMaintenance()
+	s.RunOnce(0, countTask{runs: &once})
+	ss.tick()
+	if !s.Maintenance() || normal != 0 || exempt != 2 || once != 0 || s.Ticks() != 1 {
+		t.Fatalf("Expected only exempt tasks to run, got %d normal, %d exempt, %d once runs", normal, exempt, once)
+	}
+	s.ExitMaintenance()
+	ss.tick()
+	if s.Maintenance() || normal != 1 || exempt != 4 || once != 1 {
+		t.Fatalf("Expected all tasks to run, got %d normal, %d exempt, %d once runs", normal, exempt, once)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	s := New(WithJitter(9))
+	for i := 0; i < 50; i++ {
+		s.Add(10, sleepTask(i))
+	}
+	s.AddWithOffset(10, 3, sleepTask(100))
+	s.AddBatch([]Spec{{Period: 10, Task: sleepTask(101), FixedOffset: true, Offset: 3, Jitter: 2}})
+
+	ss := s.(*scheduler)
+	phases := map[int]bool{}
+	for _, e := range ss.tasks[10][:50] {
+		if !e.fixed || e.offset < 0 || e.offset >= 10 {
+			t.Fatalf("Expected a jitter phase within the period, got %+v", e)
+		}
+		phases[e.offset] = true
+	}
+	if len(phases) < 2 {
+		t.Fatalf("Expected random phases, got %v", phases)
+	}
+	if e := ss.tasks[10][50]; e.offset != 3 {
+		t.Fatalf("Expected an explicit offset to be kept, got %d", e.offset)
+	}
+	if e := ss.tasks[10][51]; e.offset < 3 || e.offset > 5 {
+		t.Fatalf("Expected an offset within the task jitter, got %d", e.offset)
+	}
+	if c := s.New().(*scheduler); c.jitter != 9 || len(c.tasks[10]) != 52 {
+		t.Fatalf("Expected the jitter and tasks to be copied")
+	}
+}
+
+func TestOverrunPolicy(t *testing.T) {
+	s := New()
+	ss := s.(*scheduler)
+	ss.duration = 5 * time.Millisecond
+	hooked, events := 0, 0
+	s.OnOverrun(func(Scheduler) { hooked++ })
+	s.Subscribe(EventOverrun, func(Event) { events++ })
+
+	runs := 0
+	slow := &funcTask{fn: func() error { runs++; time.Sleep(10 * time.Millisecond); return nil }}
+	s.Add(1, slow, slow)
+	ss.tick()
+	if runs != 2 || s.Overruns() != 1 || hooked != 1 || events != 1 {
+		t.Fatalf("Expected an overrun, got %d runs, %d overruns, %d hooks, %d events", runs, s.Overruns(), hooked, events)
+	}
+
+	s.SetOverrunPolicy(OverrunAbort)
+	ss.tick()
+	if runs != 3 || s.Overruns() != 2 {
+		t.Fatalf("Expected the second task to be aborted, got %d runs, %d overruns", runs, s.Overruns())
+	}
+	s.ResetStats()
+	if s.Overruns() != 0 {
+		t.Fatalf("Expected overruns to be reset")
+	}
+}
+
+func TestOverrunConcurrently(t *testing.T) {
+	s := New()
+	s.SetOverrunPolicy(OverrunRunConcurrently)
+	var running, most atomic.Int32
+	s.Add(1, &funcTask{fn: func() error {
+		n := running.Add(1)
+		if n > most.Load() {
+			most.Store(n)
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	}})
+	s.Start(5 * time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	s.Stop()
+	if most.Load() < 2 || running.Load() != 0 {
+		t.Fatalf("Expected overlapping ticks, waited for by Stop, got %d concurrent, %d running", most.Load(), running.Load())
+	}
+	if s.Overruns() == 0 {
+		t.Fatalf("Expected overruns to be counted")
+	}
+	if lt := s.Ticks(); lt < 4 {
+		t.Fatalf("Expected ticks to start on time, got %d", lt)
+	}
+}
+
+func TestScope(t *testing.T) {
+	s := New()
+	s.Add(1, sleepTask(1))
+	c := s.Child()
+	c.Add(1, sleepTask(1), sleepTask(2))
+	c.AddBatch([]Spec{{Period: 2, Task: sleepTask(3)}, {Period: 0, Task: sleepTask(4)}})
+	n := c.Child()
+	n.Add(3, sleepTask(5))
+	if s.Tasks() != 5 || c.Tasks() != 3 || n.Tasks() != 1 {
+		t.Fatalf("Unexpected tasks : %d in scheduler, %d in scope, %d nested", s.Tasks(), c.Tasks(), n.Tasks())
+	}
+
+	c.Remove(sleepTask(1))
+	if s.Tasks() != 4 || c.Tasks() != 2 {
+		t.Fatalf("Expected only the scope registration to be removed, got %d tasks", s.Tasks())
+	}
+
+	c.Close()
+	c.Close()
+	if s.Tasks() != 1 || n.Tasks() != 0 {
+		t.Fatalf("Expected closing to remove the scope and nested tasks, got %d tasks", s.Tasks())
+	}
+	if c.Add(1, sleepTask(6)) != nil || c.AddBatch([]Spec{{Period: 1, Task: sleepTask(6)}})[0] != ErrScopeClosed {
+		t.Fatalf("Expected a closed scope to reject tasks")
+	}
+	if cc := c.Child(); cc.Add(1, sleepTask(7)) != nil || s.Tasks() != 1 {
+		t.Fatalf("Expected the child of a closed scope to be closed")
+	}
+}
+
+func TestStopWithTimeout(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	stuck := &funcTask{fn: func() error { <-release; return nil }}
+	s.Add(1, stuck)
+	s.Start(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	err := s.StopWithTimeout(10 * time.Millisecond)
+	var se *StopError
+	if !errors.As(err, &se) || !errors.Is(err, context.DeadlineExceeded) || len(se.Pending) != 1 || se.Pending[0] != stuck {
+		t.Fatalf("Expected the stuck task to be reported, got %v", err)
+	}
+	close(release)
+	s.Stop() // waits for the background stop
+	if lt := s.Lifetime(); lt.Running {
+		t.Fatalf("Expected the stop to complete once the task finished")
+	}
+	if err := s.StopContext(context.Background()); err != nil {
+		t.Fatalf("Expected stopping a stopped scheduler to succeed, got %v", err)
+	}
+}
+
+func TestAddOwned(t *testing.T) {
+	s := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	tok := NewToken()
+	s.Add(1, sleepTask(1))
+	s.AddOwned(ctx, 1, sleepTask(2), sleepTask(3))
+	s.AddOwned(ctx, 2, sleepTask(4))
+	s.AddOwned(tok, 1, sleepTask(5))
+	if s.Tasks() != 5 || len(s.(*scheduler).owners) != 2 {
+		t.Fatalf("Expected 5 tasks for 2 owners, got %d tasks", s.Tasks())
+	}
+
+	cancel()
+	tok.Close()
+	tok.Close()
+	time.Sleep(10 * time.Millisecond)
+	if s.Tasks() != 1 {
+		t.Fatalf("Expected the owned tasks to be removed, got %d tasks", s.Tasks())
+	}
+	if s.AddOwned(tok, 1, sleepTask(6)) != nil || s.Tasks() != 1 {
+		t.Fatalf("Expected nothing to be added for a closed owner")
+	}
+	s.(*scheduler).lockowners.Lock()
+	defer s.(*scheduler).lockowners.Unlock()
+	if len(s.(*scheduler).owners) != 0 {
+		t.Fatalf("Expected owners to be released")
+	}
+}
+
+func TestObserver(t *testing.T) {
+	s := New().(*scheduler)
+	release, started := make(chan struct{}), make(chan struct{}, 10)
+	got := make(chan Event, 10)
+	slow := s.Observe(EventAnomaly, ObserverFunc(func(ev Event) { started <- struct{}{}; <-release; got <- ev }), 2)
+	fast := s.Observe(EventAll, ObserverFunc(func(ev Event) {}), 0)
+
+	s.emit(Event{Kind: EventAnomaly})
+	<-started
+	for i := 0; i < 4; i++ { // never blocks, the slow observer holds 1 event and queues 2
+		s.emit(Event{Kind: EventAnomaly, Tick: i})
+	}
+	s.emit(Event{Kind: EventOverrun})
+	if slow.Dropped() != 2 || fast.Dropped() != 0 {
+		t.Fatalf("Expected 2 dropped events for the slow observer only, got %d and %d", slow.Dropped(), fast.Dropped())
+	}
+
+	close(release)
+	slow.Close()
+	slow.Close()
+	if len(got) != 3 {
+		t.Fatalf("Expected the queued events to be delivered on close, got %d", len(got))
+	}
+	s.emit(Event{Kind: EventAnomaly})
+	fast.Close()
+	if len(got) != 3 || len(s.subscribers) != 0 {
+		t.Fatalf("Expected closed observers to be unregistered")
+	}
+}
+
+func TestScheduleAfter(t *testing.T) {
+	s := New()
+	if err := s.ScheduleAfter(time.Second, sleepTask(1)); err != ErrNoDuration {
+		t.Fatalf("Expected unknown duration error, got %v", err)
+	}
+	ss := s.(*scheduler)
+	ss.duration = 10 * time.Millisecond
+	s.ScheduleAfter(25*time.Millisecond, sleepTask(1))
+	s.ScheduleAt(time.Now().Add(-time.Hour), sleepTask(2))
+	if len(ss.once[3]) != 1 || len(ss.once[0]) != 1 {
+		t.Fatalf("Unexpected one-shot ticks %v", ss.once)
+	}
+
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	fake := New(WithClock(clock)).(*scheduler)
+	fake.duration = time.Minute
+	fake.ScheduleAt(clock.Now().Add(90*time.Second), sleepTask(3))
+	if len(fake.once[2]) != 1 {
+		t.Fatalf("Expected the time to be read on the scheduler clock, got %v", fake.once)
+	}
+
+	ran := make(chan time.Time, 1)
+	s2 := New()
+	s2.Start(5 * time.Millisecond)
+	defer s2.Stop()
+	start := time.Now()
+	s2.ScheduleAt(start.Add(30*time.Millisecond), &funcTask{fn: func() error { ran <- time.Now(); return nil }})
+	at := <-ran
+	if at.Sub(start) < 30*time.Millisecond {
+		t.Fatalf("Expected the task to run no earlier than scheduled, ran after %v", at.Sub(start))
+	}
+}
+
+func TestTaskFunc(t *testing.T) {
+	s := New()
+	runs := 0
+	f := TaskFunc(func() error { runs++; return nil })
+	g := TaskOf(func() { runs += 10 })
+	var seen context.Context
+	c := TaskCtxFunc(func(ctx context.Context) error { seen = ctx; return nil })
+	fail := ErrTask(errors.New("boom"))
+	s.Add(1, f, g, c, NoopTask(), NoopTask(), fail)
+	if s.Tasks() != 6 {
+		t.Fatalf("Expected distinct tasks, got %d", s.Tasks())
+	}
+
+	s.(*scheduler).tick()
+	if runs != 11 || seen == nil || s.Tasks() != 5 {
+		t.Fatalf("Unexpected runs %d, context %v, %d tasks", runs, seen, s.Tasks())
+	}
+	s.Remove(f)
+	if s.Tasks() != 4 {
+		t.Fatalf("Expected function tasks to be removable, got %d tasks", s.Tasks())
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	s := New()
+	var js, txt strings.Builder
+	o1 := s.StreamEvents(&js, FormatJSON)
+	o2 := s.StreamEvents(&txt, FormatText)
+	s.AddNamed("ok", 1, NoopTask())
+	s.Add(1, ErrTask(errors.New("boom")))
+	s.(*scheduler).tick()
+	o1.Close()
+	o2.Close()
+
+	lines := strings.Split(strings.TrimSpace(js.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 2 runs, an error, a removal and a tick, got %q", lines)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil || rec["kind"] != "Run" || rec["error"] != "boom" {
+		t.Fatalf("Unexpected run record %v : %v", rec, err)
+	}
+	if !strings.Contains(txt.String(), `tick 0 Run`) || !strings.Contains(txt.String(), `error="boom"`) {
+		t.Fatalf("Unexpected text stream %q", txt.String())
+	}
+	if k := EventRun | EventTick; k.String() != "Tick|Run" {
+		t.Fatalf("Unexpected kind name %v", k)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := New()
+	ss := s.(*scheduler)
+	ss.duration = 5 * time.Millisecond
+	s.Add(1, sleepTask(10*time.Millisecond), NoopTask())
+	s.Add(2, ErrTask(errors.New("boom")))
+	ss.tick()
+	ss.tick()
+
+	st := s.Stats()
+	if st.Ticks != 2 || st.Runs != 5 || st.Errors != 1 || st.Overruns != 2 || st.Elapsed != 10*time.Millisecond {
+		t.Fatalf("Unexpected stats %+v", st)
+	}
+	if st.LastTick < 10*time.Millisecond || st.MaxTick < st.LastTick || st.Load <= 1 {
+		t.Fatalf("Unexpected tick durations %+v", st)
+	}
+	s.ResetStats()
+	if st := s.Stats(); st.Ticks != 0 || st.Runs != 0 || st.MaxTick != 0 || st.Overruns != 0 {
+		t.Fatalf("Expected stats to be reset, got %+v", st)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := New()
+	if err := s.Checkpoint(); err != ErrNoStore {
+		t.Fatalf("Expected no store error, got %v", err)
+	}
+	st := &MemoryStore{}
+	s.SetStore(st)
+	ss := s.(*scheduler)
+	runs := 0
+	tasks := map[string]Task{"a": countTask{runs: &runs}, "b": NoopTask()}
+	s.AddNamed("a", 3, tasks["a"])
+	s.AddBatch([]Spec{{Name: "b", Period: 4, Task: tasks["b"], Timeout: time.Second}})
+	s.AddNamed("fail", 1, ErrTask(errors.New("boom")))
+	s.Add(1, NoopTask())
+	ss.tick() // a runs at tick 0, next at 3
+	ss.tick()
+
+	if dl, _ := st.DeadLetters(); len(dl) != 1 || dl[0].Name != "fail" || dl[0].Err != "boom" || dl[0].Tick != 0 {
+		t.Fatalf("Unexpected dead letters %+v", dl)
+	}
+	if err := s.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if specs, _ := st.LoadSpecs(); len(specs) != 2 || specs[1].Timeout != time.Second {
+		t.Fatalf("Expected the named tasks to be saved, got %+v", specs)
+	}
+
+	r := New()
+	r.SetStore(st)
+	if err := r.Restore(map[string]Task{"a": tasks["a"]}); !errors.Is(err, ErrUnknownTask) || r.Tasks() != 0 {
+		t.Fatalf("Expected unknown task error, got %v", err)
+	}
+	if err := r.Restore(tasks); err != nil {
+		t.Fatal(err)
+	}
+	before := runs
+	r.(*scheduler).tick() // due 1 tick after the checkpoint : not yet
+	if runs != before {
+		t.Fatalf("Expected a to keep its phase")
+	}
+	r.(*scheduler).tick()
+	if runs != before+1 || r.Tasks() != 2 {
+		t.Fatalf("Expected a to run 2 ticks after restore, got %d runs, %d tasks", runs-before, r.Tasks())
+	}
+}
+
+func TestRunKeys(t *testing.T) {
+	st := &MemoryStore{}
+	runs := 0
+	pay := countTask{runs: &runs}
+	s := New()
+	s.SetStore(st)
+	s.AddBatch([]Spec{{Name: "pay", Period: 1, Task: pay, RunKeys: true}})
+	s.(*scheduler).tick()
+	s.Checkpoint() // after run 1
+	s.(*scheduler).tick()
+	st.BeginRun("pay#3") // run 3 interrupted by a crash
+	if runs != 2 {
+		t.Fatalf("Expected 2 runs, got %d", runs)
+	}
+
+	r := New()
+	r.SetStore(st)
+	if err := r.Restore(map[string]Task{"pay": pay}); err != nil {
+		t.Fatal(err)
+	}
+	r.(*scheduler).tick() // run 2 already done
+	r.(*scheduler).tick() // run 3 in doubt
+	if runs != 2 {
+		t.Fatalf("Expected recorded runs not to be executed again, got %d runs", runs)
+	}
+	if o, _ := r.Outcomes(pay); r.Stats().Runs != 0 || o.Successes != 0 {
+		t.Fatalf("Expected the recorded runs to be skipped, got %d runs and %+v", r.Stats().Runs, o)
+	}
+	r.(*scheduler).tick()
+	if runs != 3 {
+		t.Fatalf("Expected run 4 to execute, got %d runs", runs)
+	}
+	if p, _ := st.PendingRuns(); len(p) != 1 || p[0] != "pay#3" {
+		t.Fatalf("Expected run 3 to be reported pending, got %v", p)
+	}
+}
+
+// commitTask commits its runs once it was called more than skip times.
+type commitTask struct {
+	runs, commits *int
+	skip          int
+}
+
+func (c commitTask) Run() error { return nil }
+
+func (c commitTask) RunCommit(_ context.Context, commit func()) error {
+	*c.runs++
+	if *c.runs > c.skip {
+		commit()
+		*c.commits++
+	}
+	return nil
+}
+
+func TestExactlyOnce(t *testing.T) {
+	runs, commits := 0, 0
+	var errs []error
+	s := New()
+	s.SetErrorHandler(func(_ Task, err error) { errs = append(errs, err) })
+	s.AddExactlyOnce(1, Retry(2), commitTask{runs: &runs, commits: &commits, skip: 2})
+	for i := 0; i < 4; i++ {
+		s.(*scheduler).tick()
+	}
+	if runs != 4 || commits != 2 {
+		t.Fatalf("Expected 4 runs and 2 commits, got %d and %d", runs, commits)
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], ErrNotCommitted) {
+		t.Fatalf("Expected 2 uncommitted runs, got %v", errs)
+	}
+
+	if errs := s.AddBatch([]Spec{{Period: 1, Task: NoopTask(), Commit: true}}); !errors.Is(errs[0], ErrNotCommitTask) {
+		t.Fatalf("Expected a plain task to be rejected, got %v", errs)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	s := New()
+	PublishExpvar("scheduler_test", s)
+	s.(*scheduler).tick()
+	r := New()
+	PublishExpvar("scheduler_test", r) // republished
+	r.(*scheduler).duration = time.Millisecond
+	r.(*scheduler).tick()
+	r.(*scheduler).tick()
+
+	var st Stats
+	if err := json.Unmarshal([]byte(expvar.Get("scheduler_test").String()), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Ticks != 2 {
+		t.Fatalf("Expected the stats of the last scheduler published, got %+v", st)
+	}
+}
+
+func TestTagStats(t *testing.T) {
+	runs := 0
+	s := New()
+	s.SetErrorPolicy(IgnoreErrors)
+	s.AddTagged(1, []string{"billing", "team-a"}, countTask{runs: &runs})
+	s.AddTagged(2, []string{"team-a"}, countTask{runs: &runs, err: errors.New("fail")})
+	s.AddBatch([]Spec{{Period: 1, Task: sleepTask(time.Millisecond), Tags: []string{"team-b"}}})
+	for i := 0; i < 4; i++ {
+		s.(*scheduler).tick()
+	}
+
+	if st := s.TagStats("team-a"); st.Tasks != 2 || st.Runs != 6 || st.Errors != 2 || st.ErrorRate != 2./6 {
+		t.Fatalf("Unexpected team-a stats %+v", st)
+	}
+	if st := s.TagStats("billing"); st.Runs != 4 || st.Errors != 0 {
+		t.Fatalf("Unexpected billing stats %+v", st)
+	}
+	if r := s.TagRollups(); len(r) != 3 || r[0].Tag != "team-b" || r[0].Busy < 4*time.Millisecond {
+		t.Fatalf("Expected team-b to be the busiest tag, got %+v", r)
+	}
+	s.ResetStats()
+	if st := s.TagStats("team-a"); st.Tasks != 2 || st.Runs != 0 {
+		t.Fatalf("Expected tag stats to be reset, got %+v", st)
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	var buf strings.Builder
+	runs := 0
+	s := New()
+	s.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	s.Add(1, countTask{runs: &runs, err: errors.New("fail")})
+	s.(*scheduler).tick()
+	for _, msg := range []string{"tick start", "task failed", "task removed", "tick end"} {
+		if !strings.Contains(buf.String(), `msg="`+msg+`"`) {
+			t.Fatalf("Expected %q to be logged, got :\n%s", msg, buf.String())
+		}
+	}
+
+	buf.Reset()
+	s.SetLogger(nil)
+	s.Add(1, countTask{runs: &runs, err: errors.New("fail")})
+	s.(*scheduler).tick()
+	if buf.Len() != 0 {
+		t.Fatalf("Expected logging to be disabled, got :\n%s", buf.String())
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	auth := AdminAuth{
+		Authenticate: TokenAuth(map[string]string{"secret-ops": "ops", "secret-view": "viewer"}),
+		Authorize:    AllowOps(map[string][]Operation{"ops": {OpStatus, OpRemove}, "viewer": {OpStatus}}),
+	}
+	h := auth.Guard(OpRemove, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		fmt.Fprint(w, p)
+	}))
+	for token, code := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized,
+		"secret-view": http.StatusForbidden, "secret-ops": http.StatusOK} {
+		r := httptest.NewRequest(http.MethodPost, "/remove", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Fatalf("Expected %d for token %q, got %d", code, token, w.Code)
+		}
+		if code == http.StatusOK && w.Body.String() != "ops" {
+			t.Fatalf("Expected the principal to be passed to the handler, got %q", w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	AdminAuth{}.Guard(OpStatus, http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected requests to be rejected without authenticator, got %d", w.Code)
+	}
+}
+
+// spanRecorder records the spans started, and their parent.
+type spanRecorder struct {
+	lock  sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name, parent string
+	attrs        []slog.Attr
+	err          error
+}
+
+type spanKey struct{}
+
+func (r *spanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	sp := &recordedSpan{name: name, parent: parent}
+	r.lock.Lock()
+	r.spans = append(r.spans, sp)
+	r.lock.Unlock()
+	return context.WithValue(ctx, spanKey{}, name), sp
+}
+
+func (sp *recordedSpan) End(attrs []slog.Attr, err error) {
+	sp.attrs, sp.err = attrs, err
+}
+
+func TestSpanTracer(t *testing.T) {
+	rec := &spanRecorder{}
+	var inner string
+	s := New()
+	s.SetSpanTracer(rec)
+	s.SetErrorPolicy(IgnoreErrors)
+	s.AddNamed("inner", 2, TaskCtxFunc(func(ctx context.Context) error {
+		inner, _ = ctx.Value(spanKey{}).(string)
+		return nil
+	}))
+	s.Add(1, ErrTask(errors.New("fail")))
+	s.(*scheduler).tick()
+
+	if len(rec.spans) != 3 || rec.spans[0].name != SpanTick || rec.spans[1].parent != SpanTick {
+		t.Fatalf("Expected a tick span with 2 task spans, got %+v", rec.spans)
+	}
+	if inner != SpanTask {
+		t.Fatalf("Expected TaskCtx tasks to run with their span context, got %q", inner)
+	}
+	failed := rec.spans[1] // shorter periods run first
+	if failed.err == nil || failed.attrs[1].Value.Int64() != 1 {
+		t.Fatalf("Expected the failed task span to record its error and its period, got %+v", failed)
+	}
+
+	s.SetSpanTracer(nil)
+	s.(*scheduler).tick()
+	if len(rec.spans) != 3 {
+		t.Fatalf("Expected spans to be disabled, got %d spans", len(rec.spans))
+	}
+}
+
+func TestWithTracing(t *testing.T) {
+	slow, fast := sleepTask(2*time.Millisecond), NoopTask()
+	explicit := Trace(NoopTask())
+	s := New(WithTracing())
+	s.Add(2, slow)
+	s.Add(1, fast, explicit)
+	for i := 0; i < 4; i++ {
+		s.(*scheduler).tick()
+	}
+
+	st, ok := s.TaskStats(slow)
+	if !ok || st.Task != slow || st.Count != 2 || st.Average < 2*time.Millisecond {
+		t.Fatalf("Unexpected stats of the slow task %+v", st)
+	}
+	if st, ok := s.TaskStats(explicit); !ok || st.Count != 4 || explicit.Count() != 4 {
+		t.Fatalf("Expected an explicit tracer not to be traced twice, got %+v", st)
+	}
+	if all := s.AllStats(); len(all) != 3 || all[2].Task != slow {
+		t.Fatalf("Expected the stats of all tasks by period, got %+v", all)
+	}
+	if top := s.TopSlow(1); len(top) != 1 || top[0].Task != slow {
+		t.Fatalf("Expected the slow task first, got %+v", top)
+	}
+	s.Remove(slow)
+	if _, ok := s.TaskStats(slow); ok {
+		t.Fatal("Expected a traced task to be removed as added")
+	}
+}
+
+func TestAdminHandlers(t *testing.T) {
+	s := New()
+	s.AddNamed("sync", 2, NoopTask())
+	s.AddTagged(1, []string{"team-a"}, NoopTask())
+	s.(*scheduler).tick()
+
+	get := func(h http.Handler, method, target string) (*httptest.ResponseRecorder, Status) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		var st Status
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w, st
+	}
+	inspect := InspectHandler(s)
+	if w, st := get(inspect, http.MethodGet, "/"); w.Code != http.StatusOK || st.Stats.Ticks != 1 || len(st.Tasks) != 2 ||
+		st.Tasks[0].Tags[0] != "team-a" || st.Tasks[1].Name != "sync" {
+		t.Fatalf("Unexpected status %d %+v", w.Code, st)
+	}
+	if w, _ := get(inspect, http.MethodPost, "/?op=remove&name=sync"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected the inspection handler to be read-only, got %d", w.Code)
+	}
+
+	control := ControlHandler(s, nil)
+	if w, st := get(control, http.MethodPost, "/?op=pause&name=sync"); w.Code != http.StatusOK || !st.Tasks[1].Paused {
+		t.Fatalf("Expected the task to be paused, got %d %+v", w.Code, st)
+	}
+	if w, st := get(control, http.MethodPost, "/?op=pause"); w.Code != http.StatusOK || !st.Paused {
+		t.Fatalf("Expected the ticks to be paused, got %d %+v", w.Code, st)
+	}
+	if w, _ := get(control, http.MethodPost, "/?op=remove&name=other"); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected an unknown task to be reported, got %d", w.Code)
+	}
+	if w, st := get(control, http.MethodPost, "/?op=remove&name=sync"); w.Code != http.StatusOK || len(st.Tasks) != 1 {
+		t.Fatalf("Expected the task to be removed, got %d %+v", w.Code, st)
+	}
+
+	guarded := ControlHandler(s, &AdminAuth{Authenticate: TokenAuth(map[string]string{"t": "viewer"}),
+		Authorize: AllowOps(map[string][]Operation{"viewer": {OpStatus}})})
+	r := httptest.NewRequest(http.MethodPost, "/?op=resume", nil)
+	r.Header.Set("Authorization", "Bearer t")
+	w := httptest.NewRecorder()
+	guarded.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || !s.Paused() {
+		t.Fatalf("Expected the operation to be forbidden, got %d", w.Code)
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	a, b, c := 0, 0, 0
+	s := New(WithCatchUp(1))
+	ss := s.(*scheduler)
+	ss.duration = 10 * time.Millisecond
+	s.Add(3, countTask{runs: &a})
+	s.Add(5, countTask{runs: &b})
+	s.Add(2, countTask{runs: &c})
+	ss.tick() // all run at tick 0
+	last := time.Now()
+
+	ss.catchUp(last, last.Add(5*ss.duration)) // ticks 1 to 4 missed, b is next due at tick 5
+	if n := s.CatchUpBacklog(); n != 2 {
+		t.Fatalf("Expected 2 tasks to catch up, got %d", n)
+	}
+	ss.tick() // tick 1 : nothing due, c catches up
+	if a != 1 || b != 1 || c != 2 {
+		t.Fatalf("Expected c to catch up first, got %d %d %d", a, b, c)
+	}
+	ss.tick() // tick 2 : c due, a catches up
+	if a != 2 || b != 1 || c != 3 || s.CatchUpBacklog() != 0 {
+		t.Fatalf("Expected a to catch up next, got %d %d %d", a, b, c)
+	}
+
+	ss = New().(*scheduler)
+	ss.duration = 10 * time.Millisecond
+	ss.Add(3, NoopTask())
+	ss.tick()
+	ss.catchUp(last, last.Add(time.Second))
+	if n := ss.CatchUpBacklog(); n != 0 {
+		t.Fatalf("Expected catch-up to be disabled, got %d", n)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tr := Trace(NoopTask())
+	if p := tr.Percentile(99); p != 0 {
+		t.Fatalf("Expected no percentile without runs, got %v", p)
+	}
+	for i := 100; i > 0; i-- {
+		tr.record(time.Duration(i) * time.Millisecond)
+	}
+	for p, d := range map[float64]int{0: 1, 50: 50, 90: 90, 99: 99, 100: 100} {
+		if got := tr.Percentile(p); got != time.Duration(d)*time.Millisecond {
+			t.Fatalf("Expected P%v to be %dms, got %v", p, d, got)
+		}
+	}
+
+	tr.Reset()
+	for i := 0; i < 10*TraceSamples; i++ {
+		tr.record(time.Duration(i%100) * time.Millisecond)
+	}
+	if len(tr.samples) != TraceSamples {
+		t.Fatalf("Expected the sample to be bounded, got %d", len(tr.samples))
+	}
+	if p := tr.Percentile(50); p < 40*time.Millisecond || p > 60*time.Millisecond {
+		t.Fatalf("Expected the sampled median to be about 50ms, got %v", p)
+	}
+}
+
+func TestWorkerPoolPriority(t *testing.T) {
+	p := newWorkerPool(1)
+	p.acquire(0) // saturated
+	order := make(chan int, 3)
+	wait := func(priority, waiters int) {
+		go func() {
+			p.acquire(priority)
+			order <- priority
+			p.release()
+		}()
+		for { // wait until queued
+			p.lock.Lock()
+			n := 0
+			for _, w := range p.waiting {
+				n += len(w)
+			}
+			p.lock.Unlock()
+			if n == waiters {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	wait(0, 1)
+	wait(5, 2)
+	wait(1, 3)
+	p.release()
+	for _, want := range []int{5, 1, 0} {
+		if got := <-order; got != want {
+			t.Fatalf("Expected priority %d to get the worker, got %d", want, got)
+		}
+	}
+	p.acquire(0) // the last task releases the worker
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.free != 0 || len(p.waiting) != 0 {
+		t.Fatalf("Expected a single worker and no task waiting, got %d free and %v", p.free, p.waiting)
+	}
+}
+
+func TestTraceHistory(t *testing.T) {
+	runs := 0
+	tr := Trace(flipTask{runs: &runs})
+	tr.SetHistory(3)
+	for i := 0; i < 5; i++ {
+		tr.Run()
+	}
+	h := tr.History()
+	if len(h) != 3 {
+		t.Fatalf("Expected the last 3 runs, got %+v", h)
+	}
+	for i := 1; i < len(h); i++ {
+		if h[i].Start.Before(h[i-1].Start) {
+			t.Fatalf("Expected the oldest run first, got %+v", h)
+		}
+	}
+	if (h[0].Err == nil) == (h[1].Err == nil) {
+		t.Fatalf("Expected the errors of the runs to be recorded, got %+v", h)
+	}
+	tr.Reset()
+	if h := tr.History(); len(h) != 0 {
+		t.Fatalf("Expected the history to be reset, got %+v", h)
+	}
+}
+
+func TestTraceErrors(t *testing.T) {
+	ok := true
+	tr := Trace(TaskFunc(func() error {
+		if ok {
+			return nil
+		}
+		return errors.New("down")
+	}))
+	tr.Run()
+	ok = false
+	tr.Run()
+	tr.Run()
+	if tr.ErrorCount() != 2 || tr.ConsecutiveErrors() != 2 || tr.LastError() == nil {
+		t.Fatalf("Expected 2 consecutive errors, got %d %d %v", tr.ErrorCount(), tr.ConsecutiveErrors(), tr.LastError())
+	}
+	ok = true
+	tr.Run()
+	if tr.ErrorCount() != 2 || tr.ConsecutiveErrors() != 0 || tr.LastError() == nil {
+		t.Fatalf("Expected the streak to end and the last error to be kept, got %d %d %v",
+			tr.ErrorCount(), tr.ConsecutiveErrors(), tr.LastError())
+	}
+}
+
+func TestConcurrencyKey(t *testing.T) {
+	var running, peak, other atomic.Int32
+	keyed := func() error {
+		n := running.Add(1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	}
+	s := New(WithConcurrency(4))
+	s.AddWithKey(1, "db", TaskFunc(keyed), TaskFunc(keyed), TaskFunc(keyed))
+	s.Add(1, TaskFunc(func() error {
+		time.Sleep(2 * time.Millisecond) // while the first keyed task runs
+		if running.Load() > 0 {
+			other.Add(1)
+		}
+		return nil
+	}))
+	start := time.Now()
+	s.(*scheduler).tick()
+
+	if peak.Load() != 1 {
+		t.Fatalf("Expected tasks sharing a key to run one at a time, got %d at once", peak.Load())
+	}
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Fatalf("Expected the keyed runs to be serialized, the tick took %v", d)
+	}
+	if other.Load() != 1 {
+		t.Fatal("Expected unrelated tasks to run in parallel")
+	}
+}
+
+func TestConcurrencyKeyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	runs := 0
+	stuck, next := TaskFunc(func() error { <-release; return nil }), countTask{runs: &runs}
+	s := New()
+	s.AddBatch([]Spec{{Period: 1, Task: stuck, Key: "db", Timeout: 5 * time.Millisecond},
+		{Period: 1, Task: next, Key: "db", Timeout: 5 * time.Millisecond, Policy: Retry(1)}})
+	ticked := make(chan struct{})
+	go func() {
+		s.(*scheduler).tick() // the stuck run is abandoned, and keeps the key
+		close(ticked)
+	}()
+	select {
+	case <-ticked:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a run waiting for the key of an abandoned run to time out")
+	}
+	if o, _ := s.Outcomes(next); runs != 0 || o.Failures != 1 {
+		t.Fatalf("Expected the run waiting for the key to fail, got %d runs and %+v", runs, o)
+	}
+}
+
+func TestSmoothing(t *testing.T) {
+	runs, other := 0, 0
+	s := New(WithSmoothing(100, 3))
+	for i := 0; i < 10; i++ {
+		s.AddWithOffset(3600, 0, countTask{runs: &runs}) // all due at tick 0
+	}
+	s.Add(1, countTask{runs: &other})
+	s.(*scheduler).tick()
+	if runs != 3 || other != 1 || s.SmoothingBacklog() != 7 {
+		t.Fatalf("Expected 3 runs to leak at the first tick, got %d, %d queued", runs, s.SmoothingBacklog())
+	}
+	for i := 0; i < 3; i++ {
+		s.(*scheduler).tick()
+	}
+	if runs != 10 || other != 4 || s.SmoothingBacklog() != 0 {
+		t.Fatalf("Expected all the runs over 4 ticks, got %d, %d queued", runs, s.SmoothingBacklog())
+	}
+}
+
+func TestTraceSnapshot(t *testing.T) {
+	tr := Trace(ErrTask(errors.New("down")))
+	for i := 1; i <= 4; i++ {
+		tr.record(time.Duration(i) * time.Millisecond)
+	}
+	tr.Run()
+	sn := tr.Snapshot()
+	if sn.Count != 5 || sn.Errors != 1 || sn.LastError != "down" || sn.Max != 4*time.Millisecond || sn.P50 != 2*time.Millisecond {
+		t.Fatalf("Unexpected snapshot %+v", sn)
+	}
+
+	b, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back TraceSnapshot
+	if err := json.Unmarshal(b, &back); err != nil || back != sn {
+		t.Fatalf("Expected the tracer to marshal its snapshot, got %s", b)
+	}
+	if b, _ := json.Marshal(Stats{Ticks: 3}); !strings.Contains(string(b), `"ticks":3`) {
+		t.Fatalf("Expected stats to marshal with lower case keys, got %s", b)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := New(WithTracing())
+	s.AddNamed("sync", 1, NoopTask())
+	s.(*scheduler).tick()
+	srv := httptest.NewServer(Handler(s))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st Status
+	json.NewDecoder(resp.Body).Decode(&st)
+	resp.Body.Close()
+	if len(st.Tasks) != 1 || st.Tasks[0].Trace == nil || st.Tasks[0].Trace.Count != 1 {
+		t.Fatalf("Expected the status with the tracer stats, got %+v", st)
+	}
+
+	resp, err = http.PostForm(srv.URL, map[string][]string{"op": {"remove"}, "name": {"sync"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || s.Tasks() != 0 {
+		t.Fatalf("Expected the task to be removed, got %d", resp.StatusCode)
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	a, b, c := NoopTask(), NoopTask(), NoopTask()
+	s := New()
+	s.Add(3, a, b)
+	h := s.AddWithOffset(3, 7, c)
+	s.Add(5, a)
+	s.(*scheduler).tick()
+
+	offsets := s.Offsets(a)
+	if len(offsets) != 2 || offsets[0] != (Offset{Period: 3, Slot: 0, NextTick: 3}) || offsets[1] != (Offset{Period: 5, Slot: 0, NextTick: 5}) {
+		t.Fatalf("Unexpected offsets of a %+v", offsets)
+	}
+	if o, ok := h.Offset(); !ok || o != (Offset{Period: 3, Slot: 1, Fixed: true, NextTick: 1}) {
+		t.Fatalf("Unexpected offset of c %+v", o)
+	}
+	s.Remove(a)
+	if o := s.Offsets(b); len(o) != 1 || o[0].Slot != 0 || o[0].NextTick != 3 {
+		t.Fatalf("Expected b to take the slot of a, got %+v", o)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var buf strings.Builder
+	var handled, before int
+	runs := 0
+	s := New(
+		WithName("billing"),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+		WithErrorPolicy(IgnoreErrors),
+		WithErrorHandler(func(Task, error) { handled++ }),
+		WithHooks(func(Scheduler) { before++ }, nil),
+		WithStore(&MemoryStore{}),
+	)
+	s.Add(1, countTask{runs: &runs, err: errors.New("fail")})
+	s.(*scheduler).tick()
+	s.(*scheduler).tick()
+
+	if runs != 2 || handled != 2 || before != 2 {
+		t.Fatalf("Expected the options to apply, got %d runs, %d errors handled, %d hooks", runs, handled, before)
+	}
+	if s.Checkpoint() != nil {
+		t.Fatal("Expected the store to be set")
+	}
+	if st := s.Status(); st.Name != "billing" {
+		t.Fatalf("Expected the name in the status, got %q", st.Name)
+	}
+	s.Start(time.Millisecond)
+	s.Stop()
+	if !strings.Contains(buf.String(), "scheduler=billing") {
+		t.Fatalf("Expected the name in the logs, got %s", buf.String())
+	}
+}
+
+// sliceTask is not comparable, comparing it as an interface value panics.
+type sliceTask []int
+
+func (t sliceTask) Run() error { return nil }
+
+func TestTaskIDs(t *testing.T) {
+	task := sliceTask{1, 2}
+	s := New()
+	h := s.Add(1, task, task, NoopTask())
+	if h[0].ID() == 0 || h[0].ID() == h[1].ID() || h[2].ID() <= h[1].ID() {
+		t.Fatalf("Expected unique increasing IDs, got %v %v %v", h[0].ID(), h[1].ID(), h[2].ID())
+	}
+	var ids []TaskID
+	s.Subscribe(EventRun, func(ev Event) { ids = append(ids, ev.TaskID) })
+	s.Remove(task) // does not panic, a non comparable task only matches through its ID
+	s.(*scheduler).tick()
+	if len(ids) != 3 || ids[0] != h[0].ID() || ids[1] != h[1].ID() {
+		t.Fatalf("Expected the events to carry the IDs, got %v", ids)
+	}
+	if !s.RemoveID(h[1].ID()) || s.RemoveID(h[1].ID()) || s.Tasks() != 2 {
+		t.Fatal("Expected the registration to be removed by ID once")
+	}
+}
+
+func TestStopWithinOneTick(t *testing.T) {
+	s := New()
+	s.Start(time.Hour)
+	start := time.Now()
+	s.Stop()
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected Stop not to wait for the next tick, took %v", d)
+	}
+
+	s = New()
+	var started atomic.Int32
+	release := make(chan struct{})
+	s.Add(1, &funcTask{fn: func() error { started.Add(1); <-release; return nil }})
+	s.Start(time.Millisecond)
+	for started.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond) // ticks are pending while the task is stuck
+		close(release)
+	}()
+	s.Stop()
+	if n := started.Load(); n != 1 {
+		t.Fatalf("Expected no tick to start once stopped, got %d runs", n)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := New(WithClock(clock))
+	runs := 0
+	s.Add(2, &countTask{runs: &runs})
+	s.Start(time.Second)
+	clock.Advance(4 * time.Second)
+	if runs != 2 || s.Ticks() != 4 {
+		t.Fatalf("Expected 4 ticks and 2 runs, got %d ticks and %d runs", s.Ticks(), runs)
+	}
+	s.SetDuration(time.Minute)
+	clock.Advance(time.Minute + time.Second)
+	if s.Ticks() != 5 {
+		t.Fatalf("Expected the new duration to apply, got %d ticks", s.Ticks())
+	}
+	s.Stop()
+	if d := s.ActualElapsed(); d != time.Minute+5*time.Second {
+		t.Fatalf("Expected the elapsed time of the fake clock, got %v", d)
+	}
+	clock.Advance(time.Hour) // no ticker left
+	if s.Ticks() != 5 {
+		t.Fatalf("Expected no tick once stopped, got %d", s.Ticks())
+	}
+}
+
+func TestStep(t *testing.T) {
+	s := New()
+	runs := 0
+	s.Add(3, &countTask{runs: &runs})
+	for i := 0; i < 6; i++ {
+		s.Step()
+	}
+	if runs != 2 || s.Ticks() != 6 {
+		t.Fatalf("Expected 6 ticks and 2 runs, got %d ticks and %d runs", s.Ticks(), runs)
+	}
+	if s.Load() != 0 {
+		t.Fatalf("Expected no load without a tick duration, got %v", s.Load())
+	}
+	s.Pause()
+	s.Step()
+	if s.Ticks() != 6 {
+		t.Fatalf("Expected no tick while paused, got %d", s.Ticks())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := s.Watch(ctx)
+
+	h := s.Add(2, NoopTask())[0]
+	h.Reschedule(5)
+	s.RunOnce(0, NoopTask())
+	s.(*scheduler).tick()
+	h.Cancel()
+
+	want := []struct {
+		kind   ChangeKind
+		period int
+	}{{ChangeAdded, 2}, {ChangeRescheduled, 5}, {ChangeAdded, 0}, {ChangeRemoved, 0}, {ChangeRemoved, 5}}
+	for i, w := range want {
+		c := <-changes
+		if c.Kind != w.kind || c.Spec.Period != w.period {
+			t.Fatalf("Change %d : expected %v with period %d, got %v with period %d", i, w.kind, w.period, c.Kind, c.Spec.Period)
+		}
+	}
+	cancel()
+	for range changes { // closed once ctx is done
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	path := filepath.Join(t.TempDir(), "beat")
+	var beats []time.Time
+	s := New(WithClock(clock), WithHeartbeat(2, FileHeartbeat(path), HeartbeatFunc(func(t time.Time) error {
+		beats = append(beats, t)
+		return nil
+	})))
+	live := LivenessHandler(s, time.Minute)
+	rec := httptest.NewRecorder()
+	live.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 before the first heartbeat, got %d", rec.Code)
+	}
+
+	s.EnterMaintenance()
+	sent := &s.(*scheduler).heartbeat.task.(*heartbeatTask).sent
+	for i := 0; i < 3; i++ {
+		s.Step()
+		sent.Wait()
+	}
+	if len(beats) != 2 || !s.LastHeartbeat().Equal(beats[1]) {
+		t.Fatalf("Expected 2 heartbeats in maintenance mode, got %v", beats)
+	}
+	if b, err := os.ReadFile(path); err != nil || !strings.HasPrefix(string(b), beats[1].Format(time.RFC3339Nano)) {
+		t.Fatalf("Expected the heartbeat file to hold the last heartbeat, got %q, %v", b, err)
+	}
+	rec = httptest.NewRecorder()
+	live.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after a heartbeat, got %d", rec.Code)
+	}
+	clock.Advance(2 * time.Minute) // no tick runs within, without a tick duration
+	rec = httptest.NewRecorder()
+	live.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 once the heartbeat is older than the maximum age on the scheduler clock, got %d", rec.Code)
+	}
+}
+
+func TestHeartbeatHungSink(t *testing.T) {
+	hung, release := make(chan struct{}), make(chan struct{})
+	s := New(WithHeartbeat(1, HeartbeatFunc(func(time.Time) error {
+		hung <- struct{}{}
+		<-release
+		return nil
+	})))
+	s.Step()
+	<-hung
+	done := make(chan struct{})
+	go func() {
+		s.Step()
+		s.Step()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a hung sink not to block the ticks")
+	}
+	close(release)
+	s.(*scheduler).heartbeat.task.(*heartbeatTask).sent.Wait()
+}
+
+func TestRunFor(t *testing.T) {
+	s := New()
+	runs := 0
+	s.Add(2, &countTask{runs: &runs})
+	s.RunFor(6, time.Millisecond)
+	if runs != 3 || s.Ticks() != 6 {
+		t.Fatalf("Expected 6 ticks and 3 runs, got %d ticks and %d runs", s.Ticks(), runs)
+	}
+	if lt := s.Lifetime(); lt.Running || lt.StopReason != StopReasonCompleted {
+		t.Fatalf("Expected the scheduler to be stopped once completed, got %+v", lt)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.RunUntil(ctx, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline error, got %v", err)
+	}
+	if lt := s.Lifetime(); lt.Running || lt.Starts != 2 || lt.StopReason != StopReasonContext {
+		t.Fatalf("Expected the scheduler to be stopped by its context, got %+v", lt)
+	}
+
+	go func() {
+		for !s.Lifetime().Running {
+			time.Sleep(time.Millisecond)
+		}
+		s.Stop()
+	}()
+	if err := s.RunUntil(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("Expected no error when stopped, got %v", err)
+	}
+}
+
+func TestHookHandles(t *testing.T) {
+	s := New()
+	var calls []string
+	hook := func(name string) Hook { return func(_ Scheduler) { calls = append(calls, name) } }
+	a := s.AddBeforeHook(hook("a"))
+	s.AddBeforeHook(hook("b"))
+	s.SetBefore(hook("legacy"))
+	s.AddAfterHook(hook("after"))
+	s.(*scheduler).tick()
+	if got := strings.Join(calls, ","); got != "a,b,legacy,after" {
+		t.Fatalf("Expected the hooks in registration order, got %s", got)
+	}
+
+	calls = nil
+	if !a.Remove() || a.Remove() {
+		t.Fatalf("Expected the hook to be removed once")
+	}
+	s.SetBefore(hook("replaced"))
+	s.(*scheduler).tick()
+	if got := strings.Join(calls, ","); got != "b,replaced,after" {
+		t.Fatalf("Expected the removed and replaced hooks not to run, got %s", got)
+	}
+}
+
+func TestTaskEnv(t *testing.T) {
+	s := New()
+	var buf strings.Builder
+	s.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	var got *TaskEnv
+	s.AddNamed("job", 1, TaskCtxFunc(func(ctx context.Context) error {
+		env, ok := EnvFromContext(ctx)
+		if !ok {
+			return errors.New("no environment")
+		}
+		got = env
+		env.Logger().Info("working")
+		env.Record("items", 42)
+		return nil
+	}))
+	s.(*scheduler).tick()
+	st := s.Status()
+	if got == nil || got.Name != "job" || got.Tick != 0 || got.Period != 1 || got.ID != st.Tasks[0].ID {
+		t.Fatalf("Unexpected environment %+v", got)
+	}
+	if !strings.Contains(buf.String(), "task=job") || !strings.Contains(buf.String(), "tick=0") {
+		t.Fatalf("Expected the log record to be tagged with the task, got %q", buf.String())
+	}
+	if st.Tasks[0].Metrics["items"] != 42 {
+		t.Fatalf("Expected the metric in the status, got %v", st.Tasks[0].Metrics)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	s := New()
+	before := NoopTask()
+	s.Add(1, before)
+	var calls []string
+	named := func(name string) Middleware {
+		return func(next Task) Task {
+			return TaskCtxFunc(func(ctx context.Context) error {
+				calls = append(calls, name)
+				return runTask(ctx, next)
+			})
+		}
+	}
+	s.Use(named("outer"), named("inner"))
+	s.Use(Recover)
+	var failed error
+	s.SetErrorHandler(func(_ Task, err error) { failed = err })
+	boom := TaskFunc(func() error { panic("boom") })
+	s.Add(2, boom)
+	s.(*scheduler).tick()
+	if got := strings.Join(calls, ","); got != "outer,inner" {
+		t.Fatalf("Expected the middleware chain to run once, in order, got %s", got)
+	}
+	if failed == nil || !strings.Contains(failed.Error(), "boom") {
+		t.Fatalf("Expected the panic to be recovered as an error, got %v", failed)
+	}
+	if len(s.Offsets(boom)) != 0 {
+		t.Fatalf("Expected the failed task to be removed")
+	}
+	wrapped := NoopTask()
+	s.Add(3, wrapped)
+	s.Remove(wrapped)
+	if len(s.Offsets(wrapped)) != 0 {
+		t.Fatalf("Expected a wrapped task to be removed by its registered value")
+	}
+}
+
+func TestTraceDecay(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := Trace(NoopTask())
+	tr.SetResetInterval(time.Hour)
+	tr.recordRun(start, time.Second, nil)
+	tr.recordRun(start.Add(time.Minute), 3*time.Second, nil)
+	if tr.Count() != 2 || tr.MaxDuration() != 3*time.Second {
+		t.Fatalf("Expected the runs of the window, got %d runs up to %v", tr.Count(), tr.MaxDuration())
+	}
+	tr.recordRun(start.Add(2*time.Hour), 2*time.Second, nil)
+	if tr.Count() != 1 || tr.MaxDuration() != 2*time.Second || tr.MinDuration() != 2*time.Second {
+		t.Fatalf("Expected the aggregates to be reset, got %d runs between %v and %v", tr.Count(), tr.MinDuration(), tr.MaxDuration())
+	}
+
+	tr = Trace(NoopTask())
+	tr.SetHalfLife(time.Hour)
+	for i := 0; i < 8; i++ {
+		tr.recordRun(start.Add(time.Duration(i)*time.Minute), 10*time.Second, nil)
+	}
+	tr.recordRun(start.Add(70*time.Minute), time.Second, nil)
+	if tr.Count() != 5 || tr.MaxDuration() != 10*time.Second || tr.MinDuration() != time.Second {
+		t.Fatalf("Expected the old runs to weigh half, got %d runs between %v and %v", tr.Count(), tr.MinDuration(), tr.MaxDuration())
+	}
+	tr.recordRun(start.Add(190*time.Minute), time.Second, nil)
+	if tr.MaxDuration() != time.Second {
+		t.Fatalf("Expected the max to forget the runs of older half-lives, got %v", tr.MaxDuration())
+	}
+	if sn := tr.Snapshot(); sn.StandardDeviation < 0 || sn.Count != 2 {
+		t.Fatalf("Unexpected snapshot %+v", sn)
+	}
+
+	tr = Trace(NoopTask())
+	tr.SetHalfLife(time.Hour)
+	for i := 0; i < 3; i++ {
+		tr.recordRun(start.Add(time.Duration(i)*time.Minute), 100*time.Millisecond, nil)
+	}
+	tr.recordRun(start.Add(130*time.Minute), 100*time.Millisecond, nil)
+	if avg, dev := tr.AverageDuration(), tr.StandardDeviationDuration(); avg != 100*time.Millisecond || dev > time.Microsecond {
+		t.Fatalf("Expected the decay to keep the average, got %v ± %v", avg, dev)
+	}
+}
+
+func TestComposite(t *testing.T) {
+	fast, slow := New(), New()
+	c := NewComposite(fast, slow).Route(func(period int, _ Task) int {
+		if period >= 10 {
+			return 1
+		}
+		return 0
+	})
+	short, long := 0, 0
+	shortTask, longTask := &countTask{runs: &short}, &countTask{runs: &long}
+	c.Add(1, shortTask)
+	c.Add(10, longTask)
+	if fast.Tasks() != 1 || slow.Tasks() != 1 || c.Tasks() != 2 {
+		t.Fatalf("Expected the tasks to be routed by period, got %d and %d", fast.Tasks(), slow.Tasks())
+	}
+
+	c.RunFor(10, time.Millisecond)
+	if short != 10 || long != 1 {
+		t.Fatalf("Expected both parts to run, got %d and %d runs", short, long)
+	}
+	if st := c.Stats(); st.Ticks != 10 || c.Ticks() != 10 || st.Runs != 11 {
+		t.Fatalf("Expected the aggregated statistics with the ticks of the primary, got %+v", st)
+	}
+	if o, ok := c.Outcomes(longTask); !ok || o.Successes != 1 || !c.Throttle(longTask, 2) || !c.Unthrottle(longTask) {
+		t.Fatalf("Expected the tasks of the other parts to be found, got %+v", o)
+	}
+	if st := c.Status(); len(st.Tasks) != 2 || st.Tasks[1].Period != 10 || len(c.List()) != 2 {
+		t.Fatalf("Expected the status to list the tasks of all the parts, got %+v", st.Tasks)
+	}
+
+	c.Remove(longTask)
+	if c.Tasks() != 1 {
+		t.Fatalf("Expected the task to be removed from its part, got %d tasks", c.Tasks())
+	}
+	c.SetResolution(1, time.Hour)
+	c.Start(time.Millisecond)
+	c.Stop()
+	if c.Lifetime().Running || slow.Lifetime().Running {
+		t.Fatalf("Expected all the parts to be stopped")
+	}
+}
+
+func TestCompositeSchedule(t *testing.T) {
+	route := func(period int, _ Task) int { return period / 10 }
+	c := NewComposite(New(), New()).Route(route)
+	if err := c.AddNamed("fast", 1, NoopTask()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddNamed("slow", 10, NoopTask()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddNamed("slow", 1, NoopTask()); err != ErrDuplicateName {
+		t.Fatalf("Expected names to be unique across the parts, got %v", err)
+	}
+	err := c.ApplyPlan(SchedulePlan{{Name: "a", Period: 2, Task: NoopTask()}, {Name: "b", Period: 10}})
+	if err == nil || c.Tasks() != 2 {
+		t.Fatalf("Expected the plan to be rolled back in all the parts, got %v and %d tasks", err, c.Tasks())
+	}
+	err = c.ApplyPlan(SchedulePlan{{Name: "twice", Period: 1, Task: NoopTask()}, {Name: "twice", Period: 10, Task: NoopTask()}})
+	if !errors.Is(err, ErrDuplicateName) || c.Tasks() != 2 {
+		t.Fatalf("Expected a name routed to two parts to be rejected, got %v and %d tasks", err, c.Tasks())
+	}
+
+	st := &MemoryStore{}
+	c.SetStore(st)
+	if err := c.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if specs, _ := st.LoadSpecs(); len(specs) != 2 {
+		t.Fatalf("Expected the tasks of all the parts to be saved, got %+v", specs)
+	}
+	var buf bytes.Buffer
+	if err := c.SaveSchedule(&buf); err != nil {
+		t.Fatal(err)
+	}
+	tasks := map[string]Task{"fast": NoopTask(), "slow": NoopTask()}
+	restored := NewComposite(New(), New()).Route(route)
+	if err := restored.LoadSchedule(&buf, TasksByName(tasks)); err != nil {
+		t.Fatal(err)
+	}
+	if p := restored.Parts(); p[0].Tasks() != 1 || p[1].Tasks() != 1 {
+		t.Fatalf("Expected the loaded tasks to be routed, got %d and %d", p[0].Tasks(), p[1].Tasks())
+	}
+	restored = NewComposite(New(), New()).Route(route)
+	restored.SetStore(st)
+	if err := restored.Restore(tasks); err != nil || restored.Parts()[1].Tasks() != 1 {
+		t.Fatalf("Expected the restored tasks to be routed, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := c.Watch(ctx)
+	var events atomic.Int32
+	obs := c.Observe(EventTick, ObserverFunc(func(Event) { events.Add(1) }), 0)
+	c.Add(20, NoopTask())
+	if ch := <-changes; ch.Kind != ChangeAdded || ch.Spec.Period != 20 {
+		t.Fatalf("Expected the changes of the other parts, got %+v", ch)
+	}
+	cancel()
+	for range changes {
+	}
+	c.Step()
+	obs.Close()
+	if events.Load() != 2 {
+		t.Fatalf("Expected the ticks of all the parts, got %d", events.Load())
+	}
+
+	target := NewComposite(New(), New())
+	if err := c.Handoff(New()); !errors.Is(err, ErrHandoffTarget) {
+		t.Fatalf("Expected a composite target, got %v", err)
+	}
+	if err := c.Handoff(target); err != nil || target.Tasks() != 3 || target.Parts()[1].Tasks() != 1 || c.Tasks() != 0 {
+		t.Fatalf("Expected each part to hand off to its peer, got %v", err)
+	}
+}
+
+func TestRetryTask(t *testing.T) {
+	calls := 0
+	flaky := TaskFunc(func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err := RetryTask(flaky, 3, ConstantBackoff(time.Millisecond)).Run(); err != nil || calls != 3 {
+		t.Fatalf("Expected success on the third attempt, got %v after %d calls", err, calls)
+	}
+	calls = 0
+	if err := RetryTask(flaky, 2, nil).Run(); err == nil || calls != 2 {
+		t.Fatalf("Expected the last error after 2 attempts, got %v after %d calls", err, calls)
+	}
+
+	exp := ExponentialBackoff(time.Millisecond, 5*time.Millisecond)
+	if exp(1) != time.Millisecond || exp(3) != 4*time.Millisecond || exp(10) != 5*time.Millisecond {
+		t.Fatalf("Unexpected exponential delays %v %v %v", exp(1), exp(3), exp(10))
+	}
+	jit := JitteredBackoff(exp)
+	for n := 1; n < 10; n++ {
+		if d := jit(n); d < 0 || d > exp(n) {
+			t.Fatalf("Expected a jittered delay up to %v, got %v", exp(n), d)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	if err := RetryTask(flaky, 5, ConstantBackoff(time.Hour)).(TaskCtx).RunContext(ctx); err == nil || calls != 1 {
+		t.Fatalf("Expected no retry once cancelled, got %v after %d calls", err, calls)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	s := New()
+	calls, failing := 0, true
+	b := CircuitBreaker(TaskFunc(func() error {
+		calls++
+		if failing {
+			return errors.New("down")
+		}
+		return nil
+	}), 2, 3)
+	s.AddWithPolicy(1, IgnoreErrors, b)
+
+	s.Step()
+	s.Step()
+	if b.State() != BreakerOpen || calls != 2 {
+		t.Fatalf("Expected the breaker to open after 2 failures, got %v after %d calls", b.State(), calls)
+	}
+	s.Step()
+	s.Step()
+	if calls != 2 {
+		t.Fatalf("Expected no call during the cooldown, got %d", calls)
+	}
+	s.Step() // probe, still failing
+	if b.State() != BreakerOpen || calls != 3 {
+		t.Fatalf("Expected a failed probe to open the breaker again, got %v after %d calls", b.State(), calls)
+	}
+	failing = false
+	for i := 0; i < 3; i++ {
+		s.Step()
+	}
+	if b.State() != BreakerClosed || calls != 4 || b.Failures() != 0 {
+		t.Fatalf("Expected a successful probe to close the breaker, got %v after %d calls", b.State(), calls)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	plan := NewBuilder().
+		Every(1).Named("poll").Run(NoopTask()).
+		Every(4).Named("export").Run(NoopTask()).
+		Build()
+	sc := Scenario{
+		Tick:      100 * time.Millisecond,
+		Durations: map[string]time.Duration{"poll": 10 * time.Millisecond, "export": 60 * time.Millisecond},
+	}
+	if sim := plan.Simulate(8, sc); sim.Overruns != 0 || sim.Runs != 10 || sim.MaxBusy != 70*time.Millisecond {
+		t.Fatalf("Expected no overrun in the baseline, got %+v", sim)
+	}
+
+	sc.Slowdown = map[string]float64{"export": 3}
+	if sim := plan.Simulate(8, sc); sim.Overruns != 2 || sim.OverrunTicks[0] != 0 || sim.OverrunTicks[1] != 4 {
+		t.Fatalf("Expected the slow export to overrun, got %+v", sim)
+	}
+
+	sc.Slowdown, sc.TickScale = nil, 0.5
+	sim := plan.Simulate(8, sc)
+	if sim.Overruns != 2 || sim.Load != 0.5 {
+		t.Fatalf("Expected a halved tick to overrun on exports, got %+v", sim)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	bucket := NewTokenBucket(2, 3)
+	bucket.now, bucket.last = clock.Now, clock.Now()
+	calls := 0
+	task := RateLimit(TaskOf(func() { calls++ }), bucket)
+	for i := 0; i < 5; i++ {
+		if err := task.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("Expected the burst to be allowed, got %d calls", calls)
+	}
+	clock.Advance(time.Second)
+	for i := 0; i < 5; i++ {
+		task.Run()
+	}
+	if calls != 5 {
+		t.Fatalf("Expected 2 more calls after a second, got %d calls", calls)
+	}
+}
+
+func TestErrorCauses(t *testing.T) {
+	for _, c := range []struct{ err, cause error }{
+		{ErrTaskTimeout, ErrTimeout},
+		{ErrHookTimeout, ErrTimeout},
+		{ErrTenantQuota, ErrScheduleFull},
+		{ErrNoDuration, ErrNotRunning},
+		{SchedulePlan{{Name: "x"}}.Bind(nil), ErrTaskNotFound},
+		{&StopError{Err: context.DeadlineExceeded}, ErrTimeout},
+		{New().AddNamed("x", 0, NoopTask()), ErrInvalid},
+		{New().AddBatch([]Spec{{Period: 1}})[0], ErrInvalid},
+		{New().DependsOn(NoopTask()), ErrTaskNotFound},
+		{New().Handoff(NewComposite(New())), ErrInvalid},
+		{ErrCronSyntax, ErrInvalid},
+		{ErrScopeClosed, ErrInvalid},
+		{ErrDependencyCycle, ErrInvalid},
+		{ErrNoStore, ErrInvalid},
+		{ErrNotCommitTask, ErrInvalid},
+		{ErrDuplicateName, ErrExists},
+		{ErrAlreadyRegistered, ErrExists},
+	} {
+		if !errors.Is(c.err, c.cause) {
+			t.Fatalf("Expected %v to match %v", c.err, c.cause)
+		}
+	}
+	if errors.Is(&StopError{Err: context.Canceled}, ErrTimeout) {
+		t.Fatalf("Expected a cancelled stop not to be a timeout")
+	}
+
+	s := New()
+	s.Start(time.Millisecond)
+	defer s.Stop()
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrAlreadyStarted) {
+			t.Fatalf("Expected starting twice to panic with ErrAlreadyStarted, got %v", err)
+		}
+	}()
+	s.Start(time.Millisecond)
+}
+
+func TestOnEvict(t *testing.T) {
+	s := New()
+	failing := errors.New("failing")
+	a := TaskFunc(func() error { return failing })
+	b := TaskFunc(func() error { return failing })
+	c := TaskFunc(func() error { return failing })
+	s.Add(1, a, b, c)
+
+	var evicted []error
+	s.OnEvict(func(t Task, reason error) (bool, int) {
+		evicted = append(evicted, reason)
+		switch t {
+		case a:
+			return true, 0 // veto
+		case b:
+			return true, 3 // requeue
+		}
+		return false, 0
+	})
+	s.Step()
+
+	if len(evicted) != 3 || !errors.Is(evicted[0], failing) {
+		t.Fatalf("Expected 3 evictions with the run error, got %v", evicted)
+	}
+	if s.Tasks() != 2 {
+		t.Fatalf("Expected the vetoed and requeued tasks to be kept, got %d tasks", s.Tasks())
+	}
+	in := s.(*scheduler)
+	if len(in.tasks[1]) != 1 || len(in.tasks[3]) != 1 || in.tasks[3][0].failures != 0 {
+		t.Fatalf("Expected the requeued task to have period 3 and no failures, got %v", in.tasks)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	for _, workers := range []int{0, 4} {
+		var opts []Option
+		if workers > 0 {
+			opts = append(opts, WithConcurrency(workers))
+		}
+		s := New(opts...)
+		s.SetErrorPolicy(IgnoreErrors)
+
+		var lock sync.Mutex
+		var order []string
+		fail := false
+		step := func(name string) Task {
+			return TaskFunc(func() error {
+				time.Sleep(time.Millisecond)
+				lock.Lock()
+				defer lock.Unlock()
+				order = append(order, name)
+				if name == "a" && fail {
+					return errors.New("failing")
+				}
+				return nil
+			})
+		}
+		a, b, c := step("a"), step("b"), step("c")
+		if _, err := s.AddChain(2, a, b); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.AddChain(2, b, a); !errors.Is(err, ErrDependencyCycle) {
+			t.Fatalf("Expected a reversed chain to be rejected, got %v", err)
+		}
+		if _, err := s.AddChain(2, c, NoopTask(), c); !errors.Is(err, ErrDependencyCycle) {
+			t.Fatalf("Expected a chain looping on itself to be rejected, got %v", err)
+		}
+		s.Add(2, c)
+		if err := s.DependsOn(c, b); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DependsOn(a, c); !errors.Is(err, ErrDependencyCycle) {
+			t.Fatalf("Expected a cycle to be rejected, got %v", err)
+		}
+		if err := s.DependsOn(NoopTask(), a); !errors.Is(err, ErrTaskNotFound) {
+			t.Fatalf("Expected an unknown task to be rejected, got %v", err)
+		}
+
+		s.Step()
+		if got := fmt.Sprint(order); got != "[a b c]" {
+			t.Fatalf("Expected the chain to run in order with %d workers, got %v", workers, got)
+		}
+
+		order, fail = nil, true
+		s.Step()
+		s.Step()
+		if got := fmt.Sprint(order); got != "[a]" {
+			t.Fatalf("Expected a failure to skip its dependents with %d workers, got %v", workers, got)
+		}
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	s := New(WithConcurrency(4)).(*scheduler)
+	runs := 0
+	a, b := countTask{runs: &runs}, countTask{runs: new(int)}
+	s.Add(1, a, b)
+	s.tasks[1][0].after, s.tasks[1][1].after = []Task{b}, []Task{a} // as if declared concurrently
+
+	done := make(chan struct{})
+	go func() {
+		s.Step()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a dependency cycle not to block the tick")
+	}
+	if runs != 1 {
+		t.Fatalf("Expected the tasks of the cycle to run, got %d runs", runs)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	s := New()
+	runs, other := 0, 0
+	g := s.Group("maintenance")
+	g.Add(1, countTask{runs: &runs}, countTask{runs: &runs})
+	s.Add(1, countTask{runs: &other})
+	if s.Group("maintenance") != g {
+		t.Fatalf("Expected the same group for the same name")
+	}
+
+	g.Pause()
+	s.Step()
+	if runs != 0 || other != 1 {
+		t.Fatalf("Expected the paused group not to run, got %d runs and %d other", runs, other)
+	}
+	g.Add(1, countTask{runs: &runs})
+	s.Step()
+	if runs != 0 {
+		t.Fatalf("Expected a task added to a paused group to be paused, got %d runs", runs)
+	}
+	g.Resume()
+	s.Step()
+	if runs != 3 {
+		t.Fatalf("Expected the resumed group to run, got %d runs", runs)
+	}
+
+	g.SetPriority(5)
+	if s.(*scheduler).tasks[1][0].priority != 5 {
+		t.Fatalf("Expected the group priority to be set")
+	}
+	g.Remove()
+	if g.Tasks() != 0 || s.Tasks() != 1 {
+		t.Fatalf("Expected only the group tasks to be removed, got %d and %d", g.Tasks(), s.Tasks())
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	s := New()
+	runs := 0
+	a := countTask{runs: &runs}
+	s.Add(3, NoopTask(), a)
+	s.Group("g").Add(2, NoopTask())
+	for i := 0; i < 4; i++ {
+		s.Step()
+	}
+	before := s.Offsets(a)
+
+	target := New()
+	if err := s.Handoff(target); err != nil {
+		t.Fatal(err)
+	}
+	if s.Tasks() != 0 || target.Tasks() != 3 {
+		t.Fatalf("Expected the tasks to be transferred, got %d and %d", s.Tasks(), target.Tasks())
+	}
+	if target.Ticks() != 4 || target.Stats().Runs != s.Stats().Runs {
+		t.Fatalf("Expected the statistics to be transferred, got %+v", target.Stats())
+	}
+	if after := target.Offsets(a); fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Fatalf("Expected the offsets to be kept, got %v instead of %v", after, before)
+	}
+	if target.Group("g").Tasks() != 1 {
+		t.Fatalf("Expected the groups to be transferred")
+	}
+
+	src, dst := New(WithHeartbeat(1)), New()
+	src.SetTenantBudget("team", TenantBudget{MaxTasks: 1})
+	src.AddTagged(1, []string{"billing"}, NoopTask())
+	src.Step()
+	if err := src.Handoff(dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.AddTenant("team", 1, NoopTask(), NoopTask()); !errors.Is(err, ErrTenantQuota) {
+		t.Fatalf("Expected the tenant budget to be transferred, got %v", err)
+	}
+	if st := dst.TagStats("billing"); st.Runs != 1 || st.Tasks != 1 {
+		t.Fatalf("Expected the tag statistics to be transferred, got %+v", st)
+	}
+	beat := dst.LastHeartbeat()
+	time.Sleep(time.Millisecond)
+	dst.Step()
+	if beat.IsZero() || !dst.LastHeartbeat().After(beat) || !src.LastHeartbeat().Equal(beat) {
+		t.Fatalf("Expected the heartbeat to be transferred, got %v", dst.LastHeartbeat())
+	}
+	var wg sync.WaitGroup // concurrent handoffs between two schedulers do not deadlock
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); src.Handoff(dst) }()
+		go func() { defer wg.Done(); dst.Handoff(src) }()
+	}
+	wg.Wait()
+
+	target.Start(time.Millisecond)
+	last := New()
+	if err := target.Handoff(last); err != nil {
+		t.Fatal(err)
+	}
+	defer last.Stop()
+	if lt := target.Lifetime(); lt.Running || lt.StopReason != StopReasonHandoff {
+		t.Fatalf("Expected a running scheduler to stop on handoff, got %+v", lt)
+	}
+	if !last.Lifetime().Running || last.Tasks() != 3 {
+		t.Fatalf("Expected the target to run the tasks, got %+v", last.Lifetime())
+	}
+	next := New()
+	next.Start(time.Millisecond)
+	defer next.Stop()
+	if err := s.Handoff(next); !errors.Is(err, ErrAlreadyStarted) {
+		t.Fatalf("Expected a running target to be rejected, got %v", err)
+	}
+}
+
+func TestHandoffScopes(t *testing.T) {
+	s, target := New(), New()
+	scope, tok := s.Child(), NewToken()
+	scope.Add(1, sleepTask(1))
+	s.AddOwned(tok, 1, sleepTask(2))
+	target.Add(2, sleepTask(3))
+	s.Add(2, sleepTask(4)) // at the same index of its period as the task of target
+	s.Step()
+	before := s.Offsets(sleepTask(4))
+	if err := s.Handoff(target); err != nil {
+		t.Fatal(err)
+	}
+	if after := target.Offsets(sleepTask(4)); len(after) != 1 || after[0].NextTick != before[0].NextTick {
+		t.Fatalf("Expected the phase to be kept, got %v instead of %v", after, before)
+	}
+
+	scope.Add(1, sleepTask(5))
+	if target.Tasks() != 5 || s.Tasks() != 0 {
+		t.Fatalf("Expected the scope to add to the target, got %d and %d tasks", target.Tasks(), s.Tasks())
+	}
+	scope.Close()
+	tok.Close()
+	time.Sleep(10 * time.Millisecond)
+	if target.Tasks() != 2 {
+		t.Fatalf("Expected the scope and owner tasks to be removed from the target, got %d tasks", target.Tasks())
+	}
+	target.(*scheduler).lockowners.Lock()
+	owners := len(target.(*scheduler).owners)
+	target.(*scheduler).lockowners.Unlock()
+	if owners != 0 {
+		t.Fatalf("Expected the owner to be released by the target")
+	}
+
+	g := target.Group("g")
+	var wg sync.WaitGroup // groups follow concurrent handoffs
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		go func() { defer wg.Done(); target.Handoff(s) }()
+		go func() { defer wg.Done(); s.Handoff(target) }()
+		go func() { defer wg.Done(); g.Add(1, NoopTask()) }()
+	}
+	wg.Wait()
+	if g.Tasks() != 50 || s.Tasks()+target.Tasks() != 52 {
+		t.Fatalf("Expected the group to keep its tasks, got %d", g.Tasks())
+	}
+}
+
+func TestAddIf(t *testing.T) {
+	s := New()
+	runs := 0
+	var on atomic.Bool
+	s.AddIf(1, on.Load, countTask{runs: &runs})
+	s.Step()
+	if runs != 0 {
+		t.Fatalf("Expected no run while the condition does not hold, got %d runs", runs)
+	}
+	on.Store(true)
+	s.Step()
+	if runs != 1 || s.Tasks() != 1 {
+		t.Fatalf("Expected a run once the condition holds, got %d runs", runs)
+	}
+}
+
+func TestAddTimes(t *testing.T) {
+	s := New()
+	runs := 0
+	h := s.AddTimes(1, 3, countTask{runs: &runs})
+	if s.AddTimes(1, 0, NoopTask()) != nil {
+		t.Fatalf("Expected a 0 count to be rejected")
+	}
+	s.Step()
+	if st := h.Stats(); st.Left != 2 {
+		t.Fatalf("Expected 2 runs left, got %d", st.Left)
+	}
+	for i := 0; i < 4; i++ {
+		s.Step()
+	}
+	if runs != 3 || s.Tasks() != 0 {
+		t.Fatalf("Expected the task to retire after 3 runs, got %d runs and %d tasks", runs, s.Tasks())
+	}
+}
+
+func TestAddWithTTL(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := New(WithClock(clock))
+	runs := 0
+	s.AddWithTTL(1, 3*time.Second, countTask{runs: &runs})
+	s.Add(1, NoopTask())
+	copied := s.New()
+	for i := 0; i < 5; i++ {
+		s.Step()
+		copied.Step()
+		clock.Advance(time.Second)
+	}
+	if runs != 6 || s.Tasks() != 1 || copied.Tasks() != 1 {
+		t.Fatalf("Expected the tasks to expire after 3s, got %d runs, %d and %d tasks", runs, s.Tasks(), copied.Tasks())
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	s := New()
+	failing := errors.New("failing")
+	task := ErrTask(failing)
+	h := s.Add(1, task)[0]
+	var got []Event
+	s.Subscribe(EventTaskError|EventTaskRemoved|EventTick, func(ev Event) { got = append(got, ev) })
+	s.Step()
+
+	if len(got) != 3 {
+		t.Fatalf("Expected an error, a removal and a tick event, got %v", got)
+	}
+	for i, kind := range []EventKind{EventTaskError, EventTaskRemoved, EventTick} {
+		if got[i].Kind != kind || got[i].Tick != 0 {
+			t.Fatalf("Expected event %d to be a %v at tick 0, got %+v", i, kind, got[i])
+		}
+	}
+	if got[1].Task != task || got[1].TaskID != h.ID() || !errors.Is(got[1].Err, failing) {
+		t.Fatalf("Expected the removal to carry the task and its error, got %+v", got[1])
+	}
+	if k := EventTaskError | EventTaskRemoved; k.String() != "TaskError|TaskRemoved" {
+		t.Fatalf("Unexpected kind names %v", k)
+	}
+}
+
+func TestOnTaskRemoved(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := New(WithClock(clock))
+	failing := errors.New("failing")
+	failed, retired, expired := ErrTask(failing), NoopTask(), NoopTask()
+	s.Add(1, failed)
+	s.AddTimes(1, 1, retired)
+	s.AddWithTTL(1, time.Second, expired)
+
+	reasons := map[Task]error{}
+	s.OnTaskRemoved(func(t Task, reason error) { reasons[t] = reason })
+	s.Step()
+	clock.Advance(time.Second)
+	s.Step()
+
+	if len(reasons) != 3 || !errors.Is(reasons[failed], failing) ||
+		reasons[retired] != ErrTaskRetired || reasons[expired] != ErrTaskExpired {
+		t.Fatalf("Expected the removals to be notified with their reason, got %v", reasons)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	s := New()
+	a, b := NoopTask(), NoopTask()
+	s.AddNamed("a", 3, a)
+	s.AddNamed("b", 5, b)
+	s.Add(1, NoopTask())
+	for i := 0; i < 4; i++ {
+		s.Step()
+	}
+	s.(*scheduler).named("b").left = 2
+	var buf strings.Builder
+	if err := s.SaveSchedule(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.String()
+
+	if err := New().LoadSchedule(strings.NewReader(saved), TasksByName(map[string]Task{"a": a})); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("Expected an unknown name to be rejected, got %v", err)
+	}
+	ss := New()
+	if err := ss.LoadSchedule(strings.NewReader(saved), TasksByName(map[string]Task{"a": a, "b": b})); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Ticks() != 4 || ss.Tasks() != 2 || ss.(*scheduler).named("b").left != 2 {
+		t.Fatalf("Expected the tick counter and the named tasks to be restored, got %d ticks, %d tasks", ss.Ticks(), ss.Tasks())
+	}
+	for _, task := range []Task{a, b} {
+		if got, want := ss.Offsets(task)[0].NextTick, s.Offsets(task)[0].NextTick; got != want {
+			t.Fatalf("Expected the task to run next at tick %d, got %d", want, got)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	s := New()
+	s.SetStore(NewFileStore(path))
+	a := NoopTask()
+	s.AddNamed("a", 3, a)
+	s.Step()
+	if err := s.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	st := NewFileStore(path) // as after a restart
+	if ok, err := st.BeginRun("k"); !ok || err != nil {
+		t.Fatalf("Expected a new run key, got %v, %v", ok, err)
+	}
+	if ok, _ := st.BeginRun("k"); ok {
+		t.Fatalf("Expected a recorded run key to be rejected")
+	}
+	if keys, _ := st.PendingRuns(); len(keys) != 1 {
+		t.Fatalf("Expected a pending run, got %v", keys)
+	}
+	ss := New()
+	ss.SetStore(st)
+	if err := ss.Restore(map[string]Task{"a": a}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ss.Offsets(a)[0].NextTick, 2; got != want {
+		t.Fatalf("Expected the task to run in %d ticks, got %d", want, got)
+	}
+}
+
+func TestLocker(t *testing.T) {
+	for _, l := range []Locker{NewMemoryLocker(), NewFileLocker(t.TempDir())} {
+		var runs [2]int
+		var instances [2]Scheduler
+		for i := range instances {
+			instances[i] = New(WithLocker(l))
+			instances[i].SetDuration(time.Hour)
+			instances[i].AddNamed("report", 1, countTask{runs: &runs[i]})
+			instances[i].Add(1, countTask{runs: &runs[i]}) // unnamed, always run
+		}
+		for tick := 0; tick < 3; tick++ {
+			for _, s := range instances {
+				s.Step()
+			}
+		}
+		if runs != [2]int{6, 3} {
+			t.Fatalf("Expected the first instance only to run the named task with %T, got %v", l, runs)
+		}
+	}
+}
+
+func TestLockerSkipped(t *testing.T) {
+	l := NewMemoryLocker()
+	a, b := New(WithLocker(l)), New(WithLocker(l))
+	var runs, after [2]int
+	for i, s := range []Scheduler{a, b} {
+		report, next := countTask{runs: &runs[i]}, countTask{runs: &after[i]}
+		s.AddNamed("report", 1, report)
+		s.Add(1, next)
+		s.DependsOn(next, report)
+		s.(*scheduler).named("report").left = 2
+	}
+	for tick := 0; tick < 3; tick++ {
+		a.Step() // no tick duration, the lock is still held
+		b.Step()
+	}
+	if runs != [2]int{2, 0} || after != [2]int{3, 0} {
+		t.Fatalf("Expected the first instance only to run the named task and its dependent, got %v, %v", runs, after)
+	}
+	if st := b.Stats(); st.Runs != 0 {
+		t.Fatalf("Expected the skipped runs not to be counted, got %d", st.Runs)
+	}
+	if o, ok := b.Outcomes(countTask{runs: &runs[1]}); !ok || o.Successes != 0 || o.Failures != 0 {
+		t.Fatalf("Expected the skipped runs to be neither successes nor failures, got %+v", o)
+	}
+	if e := b.(*scheduler).named("report"); e == nil || e.left != 2 {
+		t.Fatalf("Expected the skipped runs not to count against the runs left")
+	}
+}
+
+func TestFileLocker(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	var held atomic.Int32
+	for i := 0; i < 8; i++ { // as many processes, each with its own locker
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			if ok, err := NewFileLocker(dir).TryLock("job", owner, time.Hour); ok && err == nil {
+				held.Add(1)
+			}
+		}(fmt.Sprint("owner", i))
+	}
+	wg.Wait()
+	if held.Load() != 1 {
+		t.Fatalf("Expected a single owner to hold the lock, got %d", held.Load())
+	}
+
+	l := NewFileLocker(dir)
+	if ok, _ := l.TryLock("short", "a", time.Millisecond); !ok {
+		t.Fatal("Expected a free lock to be acquired")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if ok, _ := l.TryLock("short", "b", time.Hour); !ok {
+		t.Fatal("Expected an expired lock to be taken over")
+	}
+	if ok, _ := l.TryLock("short", "a", time.Hour); ok {
+		t.Fatal("Expected the lock taken over to be held")
+	}
+
+	os.WriteFile(filepath.Join(dir, "bad.lock"), []byte("garbage"), 0o644)
+	if ok, err := l.TryLock("bad", "a", time.Hour); ok || err == nil {
+		t.Fatalf("Expected an unreadable lock not to be acquired, got %v, %v", ok, err)
+	}
+}
+
+func TestLockerTTL(t *testing.T) {
+	l := NewMemoryLocker()
+	a, b := New(WithLocker(l)), New(WithLocker(l))
+	var runs [2]int
+	for i, s := range []Scheduler{a, b} {
+		s.SetDuration(time.Hour)
+		s.AddNamed("report", 3, countTask{runs: &runs[i]})
+	}
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	a.Step() // a runs, and holds the lock until its next run
+	now = now.Add(3 * time.Hour)
+	b.Step()
+	if runs != [2]int{1, 0} {
+		t.Fatalf("Expected the lock to be held for the period of the task, got %v", runs)
+	}
+}
+
+func TestSharding(t *testing.T) {
+	members := StaticMembership{"a", "b", "c"}
+	names := []string{"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7"}
+	runs := map[string]int{}
+	for _, self := range members {
+		s := New(WithSharding(self, members))
+		tasks := map[string]Task{}
+		for _, n := range names {
+			n := n
+			tasks[n] = TaskOf(func() { runs[n]++ })
+			s.AddNamed(n, 1, tasks[n])
+		}
+		s.Step()
+		owned := 0
+		for _, n := range names {
+			o, _ := s.Outcomes(tasks[n])
+			if want := ShardOwner(n, members) == self; o.Successes+o.Failures != 0 != want {
+				t.Fatalf("Expected %s on %s to have outcomes only if owned, got %+v", n, self, o)
+			} else if want {
+				owned++
+			}
+		}
+		if st := s.Stats(); st.Runs != owned {
+			t.Fatalf("Expected %s to count the %d runs of the tasks it owns, got %d", self, owned, st.Runs)
+		}
+	}
+	for _, n := range names {
+		if runs[n] != 1 {
+			t.Fatalf("Expected each task to run on a single instance, got %v", runs)
+		}
+	}
+
+	for _, n := range names {
+		before, after := ShardOwner(n, members), ShardOwner(n, []string{"c", "a"})
+		if before != "b" && before != after {
+			t.Fatalf("Expected %s to stay on %s when b leaves, got %s", n, before, after)
+		}
+	}
+	if ShardOwner("x", nil) != "" {
+		t.Fatalf("Expected no owner without members")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	fast, slow := New(), New()
+	if err := Register("test-fast", fast); err != nil {
+		t.Fatal(err)
+	}
+	defer Unregister("test-fast")
+	Register("test-slow", slow)
+	defer Unregister("test-slow")
+	if err := Register("test-fast", slow); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("Expected a taken name to be rejected, got %v", err)
+	}
+	if s, ok := Get("test-slow"); !ok || s != slow {
+		t.Fatalf("Expected to get the registered scheduler")
+	}
+
+	fast.Start(time.Millisecond)
+	slow.Start(time.Millisecond)
+	StopAll()
+	if fast.Lifetime().Running || slow.Lifetime().Running {
+		t.Fatalf("Expected all the registered schedulers to be stopped")
+	}
+	if !Unregister("test-fast") || Unregister("test-fast") {
+		t.Fatalf("Expected a name to be unregistered once")
+	}
+	if _, ok := Get("test-fast"); ok {
+		t.Fatalf("Expected an unregistered name not to be found")
+	}
+}
+
+func TestListenSignals(t *testing.T) {
+	s := New()
+	s.Start(time.Millisecond)
+	c := make(chan os.Signal, 1)
+	released := false
+	done := listen(context.Background(), s, time.Second, c, func() { released = true })
+	c <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if lt := s.Lifetime(); lt.Running || lt.StopReason != StopReasonSignal || !released {
+		t.Fatalf("Expected the scheduler to stop on a signal, got %+v", lt)
+	}
+
+	s.Start(time.Millisecond)
+	defer s.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	released = false
+	done = listen(ctx, s, time.Second, c, func() { released = true })
+	cancel()
+	if err, ok := <-done; ok || err != nil {
+		t.Fatalf("Expected the channel to be closed without a stop, got %v", err)
+	}
+	if !released || !s.Lifetime().Running {
+		t.Fatalf("Expected the signals to be released and the scheduler to keep running")
+	}
+}
+
+func TestDueNextRun(t *testing.T) {
+	s := New()
+	s.SetDuration(time.Second)
+	a, b, c := NoopTask(), NoopTask(), NoopTask()
+	s.Add(2, a, b) // a at even ticks, b at odd ticks
+	s.AddWithPriority(3, 1, c)
+	s.RunOnce(4, NoopTask())
+	s.Step()
+
+	ids := func(infos []TaskInfo) []Task {
+		var tasks []Task
+		for _, i := range infos {
+			tasks = append(tasks, i.Task)
+		}
+		return tasks
+	}
+	if got := ids(s.Due(3)); len(got) != 2 || got[0] != c || got[1] != b {
+		t.Fatalf("Expected c then b at tick 3, got %v", got)
+	}
+	if got := ids(s.Due(4)); len(got) != 2 || got[0] != a {
+		t.Fatalf("Expected a and the one-shot task at tick 4, got %v", got)
+	}
+	if s.Due(0) != nil {
+		t.Fatalf("Expected no task due in the past")
+	}
+	if info, ok := s.NextRun(a); !ok || info.NextTick != 2 || info.Slot != 0 || info.NextTime.IsZero() {
+		t.Fatalf("Expected a to run next at tick 2, got %+v", info)
+	}
+	if _, ok := s.NextRun(NoopTask()); ok {
+		t.Fatalf("Expected an unknown task to have no next run")
+	}
+}
+
+func TestList(t *testing.T) {
+	s := New()
+	s.SetErrorPolicy(IgnoreErrors)
+	failing := errors.New("failing")
+	s.AddNamed("slow", 2, NoopTask())
+	s.Add(1, ErrTask(failing))
+	s.RunOnce(5, NoopTask())
+	s.Step()
+	s.Step()
+
+	list := s.List()
+	if len(list) != 3 || list[0].Period != 0 || list[1].Period != 1 || list[2].Name != "slow" {
+		t.Fatalf("Expected the tasks sorted by period, got %+v", list)
+	}
+	if f := list[1]; f.Runs != 2 || f.LastError != "failing" || f.LastRun.IsZero() {
+		t.Fatalf("Expected the last run of the failing task, got %+v", f)
+	}
+	if slow := list[2]; slow.Runs != 1 || slow.LastError != "" || slow.NextTick != 2 {
+		t.Fatalf("Expected one run of the slow task, got %+v", slow)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	s := New()
+	runs := 0
+	task := countTask{runs: &runs}
+	s.Add(3, NoopTask())
+	h := s.Add(3, task)[0] // runs at ticks 1, 4, 7...
+	s.Step()
+	if s.Reschedule(task, 0) || s.Reschedule(NoopTask(), 5) {
+		t.Fatalf("Expected invalid reschedules to be rejected")
+	}
+	if !s.Reschedule(task, 5) {
+		t.Fatalf("Expected the task to be rescheduled")
+	}
+	if info, _ := s.NextRun(task); info.NextTick != 1 || info.Period != 5 || info.ID != h.ID() {
+		t.Fatalf("Expected the task to keep its phase and identity, got %+v", info)
+	}
+	for i := 0; i < 7; i++ {
+		s.Step()
+	}
+	if info, _ := s.NextRun(task); runs != 2 || info.NextTick != 11 || info.Runs != 2 {
+		t.Fatalf("Expected runs at ticks 1 and 6, got %d runs, next %+v", runs, info)
+	}
+
+	s = New()
+	s.Add(3, NoopTask())
+	h = s.Add(3, task)[0] // at phase 1 too
+	if !h.Reschedule(4) {
+		t.Fatalf("Expected the registration to be rescheduled")
+	}
+	var ticks []int
+	for i := 0; i < 6; i++ {
+		if o, _ := h.Offset(); o.NextTick == s.Ticks() {
+			ticks = append(ticks, s.Ticks())
+		}
+		s.Step()
+	}
+	if fmt.Sprint(ticks) != "[1 5]" {
+		t.Fatalf("Expected the handle to keep the phase as Scheduler.Reschedule, got runs at %v", ticks)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	s := New(WithAdaptive(0.9, 0.2, 2))
+	s.SetDuration(10 * time.Millisecond)
+	var heavy atomic.Bool
+	heavy.Store(true)
+	task := TaskOf(func() {
+		if heavy.Load() {
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+	s.Add(1, task)
+	s.AddExpress(1, NoopTask())
+
+	for i := 0; i < 5; i++ {
+		s.Step()
+	}
+	if s.Stretch() != 2 || s.Offsets(task)[0].Period != 2 || s.Tasks() != 2 {
+		t.Fatalf("Expected the periods to be stretched under load, got %v", s.Stretch())
+	}
+	heavy.Store(false)
+	for i := 0; i < 20 && s.Stretch() > 1; i++ {
+		s.Step()
+	}
+	if s.Stretch() != 1 || s.Offsets(task)[0].Period != 1 {
+		t.Fatalf("Expected the periods to be tightened once the load drops, got %v", s.Stretch())
+	}
+}
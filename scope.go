@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ErrScopeClosed is returned when adding tasks to a closed scope. It matches ErrInvalid.
+var ErrScopeClosed = newError("scope is closed", ErrInvalid)
+
+// Scope is a child of a scheduler, tracking the tasks added through it.
+// Its tasks run on the ticks of the parent scheduler, like any other task, and are all removed when the scope
+// is closed. Plugins or modules get a scope, and manage the lifecycle of their tasks without tracking them.
+type Scope struct {
+	s        atomic.Pointer[scheduler] // scheduler of the tasks, changed by Handoff
+	lock     sync.Mutex
+	entries  []*entry // registrations added through the scope
+	children []*Scope // nested scopes, closed with this one
+	closed   bool
+}
+
+// Create a child scope of the scheduler.
+func (s *scheduler) Child() *Scope {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	return s.child()
+}
+
+// unsafe creation of a scope, registered so that a Handoff moves it.
+func (s *scheduler) child() *Scope {
+	c := &Scope{}
+	c.s.Store(s)
+	s.scopes[c] = true
+	return c
+}
+
+// Lock the tasks of the scheduler of the scope, and return it.
+func (c *Scope) lockTasks() *scheduler {
+	return lockTasks(&c.s)
+}
+
+// Create a nested scope, closed when this scope is closed.
+// The nested scope of a closed scope is already closed.
+func (c *Scope) Child() *Scope {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		cc := &Scope{closed: true}
+		cc.s.Store(c.s.Load())
+		return cc
+	}
+	s := c.lockTasks()
+	defer s.locktasks.Unlock()
+
+	cc := s.child()
+	c.children = append(c.children, cc)
+	return cc
+}
+
+// Add tasks sheduled to run every 'period' ticks on the parent scheduler, as Scheduler.Add does.
+// Nothing is added to a closed scope.
+func (c *Scope) Add(period int, t ...Task) []*TaskHandle {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed || period <= 0 {
+		return nil
+	}
+	s := c.lockTasks()
+	defer s.locktasks.Unlock()
+
+	hs := s.add(period, t...)
+	for _, h := range hs {
+		c.entries = append(c.entries, h.e)
+	}
+	return hs
+}
+
+// Add the tasks described by specs to the parent scheduler, as Scheduler.AddBatch does.
+// All specs are rejected with ErrScopeClosed once the scope is closed.
+func (c *Scope) AddBatch(specs []Spec) []error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	errs := make([]error, len(specs))
+	if c.closed {
+		for i := range errs {
+			errs[i] = ErrScopeClosed
+		}
+		return errs
+	}
+
+	s := c.lockTasks()
+	defer s.locktasks.Unlock()
+
+	for i, sp := range specs {
+		var e *entry
+		if e, errs[i] = s.addSpec(sp); e != nil {
+			c.entries = append(c.entries, e)
+		}
+	}
+	return errs
+}
+
+// Remove the registrations of t added through the scope, leaving the other ones in the scheduler.
+func (c *Scope) Remove(t Task) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s := c.lockTasks()
+	defer s.locktasks.Unlock()
+
+	kept := c.entries[:0]
+	for _, e := range c.entries {
+		if sameTask(e.task, t) {
+			s.removeEntry(e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	c.entries = kept
+}
+
+// Number of tasks of the scope still scheduled, nested scopes excluded.
+func (c *Scope) Tasks() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s := c.lockTasks()
+	defer s.locktasks.Unlock()
+
+	nb := 0
+	s.each(func(e *entry) {
+		for _, ee := range c.entries {
+			if e == ee {
+				nb++
+				break
+			}
+		}
+	})
+	return nb
+}
+
+// Remove all the tasks of the scope and of its nested scopes from the scheduler.
+// Closing a closed scope does nothing.
+func (c *Scope) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for _, cc := range c.children {
+		cc.Close()
+	}
+	c.children = nil
+
+	s := c.lockTasks()
+	defer s.locktasks.Unlock()
+
+	for _, e := range c.entries {
+		s.removeEntry(e)
+	}
+	c.entries = nil
+	delete(s.scopes, c)
+}
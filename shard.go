@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"slices"
+)
+
+// Membership lists the live instances of a deployment sharding its named tasks. Implementations typically wrap a
+// service discovery, such as the members of a Kubernetes headless service or of an etcd prefix. Members is called
+// before each run of a named task, it should return quickly, from a cache if needed.
+type Membership interface {
+	// Identities of the live instances, including this one.
+	Members() ([]string, error)
+}
+
+// StaticMembership is a Membership with a fixed list of instances.
+type StaticMembership []string
+
+func (m StaticMembership) Members() ([]string, error) {
+	return m, nil
+}
+
+// sharding is the sharding configuration of a scheduler.
+type sharding struct {
+	self    string
+	members Membership
+}
+
+// WithSharding partitions the named tasks between the instances of members, this scheduler being the instance self :
+// each named task runs only on the instance owning it, see ShardOwner, so that heavy periodic workloads scale
+// horizontally. When the members change, only the tasks of the instances gone or added move. If the members cannot be
+// listed, or do not include self, the tasks run anyway : execution is at least once. Unnamed tasks always run.
+// A nil members disables the sharding, which is the default.
+func WithSharding(self string, members Membership) Option {
+	return func(s *scheduler) {
+		s.shard = nil
+		if members != nil {
+			s.shard = &sharding{self: self, members: members}
+		}
+	}
+}
+
+// Check whether e belongs to the shard of this instance.
+func (s *scheduler) inShard(e *entry) bool {
+	if e.name == "" {
+		return true
+	}
+	s.lockstore.Lock()
+	sh := s.shard
+	s.lockstore.Unlock()
+	if sh == nil {
+		return true
+	}
+	members, err := sh.members.Members()
+	if err != nil {
+		s.log(slog.LevelError, "unable to list members, running anyway", "task", e.name, "error", err)
+		return true
+	}
+	if !slices.Contains(members, sh.self) {
+		return true
+	}
+	return ShardOwner(e.name, members) == sh.self
+}
+
+// Return the member owning the task named name, by rendezvous hashing : the owner is the same whatever the order
+// of members, and only the tasks of a member leaving or joining change owner. It returns "" without members.
+func ShardOwner(name string, members []string) string {
+	var owner string
+	var best uint64
+	for _, m := range members {
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(m))
+		if w := h.Sum64(); owner == "" || w > best || (w == best && m < owner) {
+			owner, best = m, w
+		}
+	}
+	return owner
+}
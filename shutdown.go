@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StopError is returned when a stop gave up waiting for the running tasks.
+// The stop still completes in the background, once the pending tasks finish.
+type StopError struct {
+	Err     error  // error of the context that interrupted the wait
+	Pending []Task // tasks still running
+}
+
+func (e *StopError) Error() string {
+	names := make([]string, len(e.Pending))
+	for i, t := range e.Pending {
+		names[i] = fmt.Sprint(t)
+	}
+	return fmt.Sprintf("stop interrupted : %v, %d tasks still running : %s", e.Err, len(e.Pending), strings.Join(names, ", "))
+}
+
+func (e *StopError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrTimeout when the stop gave up after a deadline.
+func (e *StopError) Is(target error) bool {
+	return target == ErrTimeout && errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Stop the scheduler, waiting for the tasks of the current tick to finish until ctx is done.
+// If ctx is done first, a *StopError lists the tasks still running : the scheduler no longer ticks, but the stop
+// completes only when they finish, and a restart waits for it.
+func (s *scheduler) StopContext(ctx context.Context) error {
+	return s.stopContext(ctx, StopReasonStopped)
+}
+
+// Stop the scheduler, waiting for the tasks of the current tick to finish up to d. See StopContext.
+func (s *scheduler) StopWithTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	return s.StopContext(ctx)
+}
+
+// Count a run of e starting, with delta 1, or ending, with delta -1.
+func (s *scheduler) track(e *entry, delta int) {
+	s.lockflight.Lock()
+	defer s.lockflight.Unlock()
+
+	s.flight[e] += delta
+	if s.flight[e] <= 0 {
+		delete(s.flight, e)
+	}
+}
+
+// List the tasks with runs in flight.
+func (s *scheduler) inFlight() []Task {
+	s.lockflight.Lock()
+	defer s.lockflight.Unlock()
+
+	tasks := make([]Task, 0, len(s.flight))
+	for e := range s.flight {
+		tasks = append(tasks, e.task)
+	}
+	return tasks
+}
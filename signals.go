@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Reason of a stop by ListenSignals.
+const StopReasonSignal = "signal"
+
+// ListenSignals stops s when one of sig is received, SIGINT and SIGTERM by default, waiting for the tasks of the
+// current tick up to grace, or until they finish if grace is 0. The returned channel receives the outcome of the stop,
+// a *StopError if the grace period elapsed first, and is closed. Later signals are handled as usual by the process,
+// so a second Ctrl-C kills a program that does not exit. CLI tools and services wait on the channel before exiting.
+// Once ctx is done, the signals are released and the channel is closed without stopping s.
+func ListenSignals(ctx context.Context, s Scheduler, grace time.Duration, sig ...os.Signal) <-chan error {
+	if len(sig) == 0 {
+		sig = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sig...)
+	return listen(ctx, s, grace, c, func() { signal.Stop(c) })
+}
+
+// Stop s with a grace period on the first signal of c, unless ctx is done first, calling release in both cases.
+func listen(ctx context.Context, s Scheduler, grace time.Duration, c <-chan os.Signal, release func()) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		select {
+		case <-c:
+			release()
+		case <-ctx.Done():
+			release()
+			return
+		}
+
+		stop := context.Background()
+		if grace > 0 {
+			var cancel context.CancelFunc
+			stop, cancel = context.WithTimeout(stop, grace)
+			defer cancel()
+		}
+		if ss, ok := s.(*scheduler); ok {
+			done <- ss.stopContext(stop, StopReasonSignal)
+		} else {
+			done <- s.StopContext(stop)
+		}
+	}()
+	return done
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import "time"
+
+// Scenario describes the conditions a plan is simulated in, to assess the risk of a change before rolling it out,
+// such as "what if the export task takes 3x longer" or "what if the tick duration halves".
+type Scenario struct {
+	Tick      time.Duration            // tick duration
+	TickScale float64                  // factor applied to Tick, such as 0.5 to halve it, 0 for none
+	Durations map[string]time.Duration // expected duration of a run of the specs, by name
+	Default   time.Duration            // expected duration of a run of the specs not in Durations
+	Slowdown  map[string]float64       // factor applied to the duration of the specs, by name, such as 3
+}
+
+// Simulation is the projected outcome of a plan in a Scenario.
+type Simulation struct {
+	Ticks        int           // number of ticks simulated
+	Runs         int           // number of runs
+	Overruns     int           // number of ticks whose runs ended after the next tick
+	OverrunTicks []int         // ticks that overran
+	MaxBusy      time.Duration // longest time spent running the tasks of a tick
+	Load         float64       // time spent running tasks over the simulated time
+	Lag          time.Duration // delay of the last tick when the simulation ends, accumulated by the overruns
+}
+
+// Simulate ticks of the plan applied to an empty scheduler running its tasks serially, in the conditions of sc.
+// Runs are those of Preview, lasting the durations of the scenario. An overrunning tick delays the next ones,
+// as with OverrunQueue, the default overrun policy.
+func (p SchedulePlan) Simulate(ticks int, sc Scenario) Simulation {
+	tick := sc.Tick
+	if sc.TickScale > 0 {
+		tick = time.Duration(float64(tick) * sc.TickScale)
+	}
+	cost := make([]time.Duration, len(p))
+	for i, sp := range p {
+		d, ok := sc.Durations[sp.Name]
+		if !ok {
+			d = sc.Default
+		}
+		if f, ok := sc.Slowdown[sp.Name]; ok {
+			d = time.Duration(float64(d) * f)
+		}
+		cost[i] = d
+	}
+
+	sim := Simulation{Ticks: max(ticks, 0)}
+	var busy, end time.Duration // total time running tasks, and end of the runs of the last tick
+	for t, run := range p.Preview(ticks) {
+		var b time.Duration
+		for _, i := range run {
+			b += cost[i]
+		}
+		start := max(time.Duration(t)*tick, end) // queued behind an overrunning tick
+		end = start + b
+		if end > time.Duration(t+1)*tick {
+			sim.Overruns++
+			sim.OverrunTicks = append(sim.OverrunTicks, t)
+		}
+		sim.Runs += len(run)
+		sim.MaxBusy = max(sim.MaxBusy, b)
+		busy += b
+	}
+	if total := time.Duration(sim.Ticks) * tick; total > 0 {
+		sim.Load = float64(busy) / float64(total)
+		sim.Lag = max(end-total, 0)
+	}
+	return sim
+}
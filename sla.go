@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default number of runs over which the SLA compliance is computed.
+const DefaultSLAWindow = 100
+
+// SLA is a service level target for a task : runs should complete successfully within Within,
+// for at least a Target ratio of the last Window runs.
+type SLA struct {
+	Within time.Duration // maximum duration of a compliant run
+	Target float64       // minimum ratio of compliant runs, between 0 and 1, e.g. 0.99
+	Window int           // number of last runs considered, DefaultSLAWindow if 0
+}
+
+// slaState tracks the rolling compliance of an entry to its SLA.
+type slaState struct {
+	lock     sync.Mutex
+	sla      SLA
+	runs     ring // compliance of the last runs
+	breached bool // compliance currently below target
+}
+
+// Declare the SLA of a task, resetting its compliance history.
+// It returns false if the task is not scheduled.
+func (s *scheduler) SetSLA(t Task, sla SLA) bool {
+	if sla.Window <= 0 {
+		sla.Window = DefaultSLAWindow
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	found := false
+	for _, v := range s.tasks {
+		for _, e := range v {
+			if sameTask(e.task, t) {
+				e.sla = &slaState{sla: sla, runs: newRing(sla.Window)}
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// Get the SLA compliance of a task, as the ratio of compliant runs in its window.
+// It returns false if the task is not scheduled with an SLA. Compliance is 1 before the first run.
+func (s *scheduler) SLACompliance(t Task) (float64, bool) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, v := range s.tasks {
+		for _, e := range v {
+			if sameTask(e.task, t) && e.sla != nil {
+				return e.sla.compliance(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Record a run of duration d and error err. It returns true if the SLA just became breached.
+func (st *slaState) record(d time.Duration, err error) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	st.runs.add(err == nil && d <= st.sla.Within)
+	below := st.runs.ratio() < st.sla.Target
+	breach := below && !st.breached
+	st.breached = below
+	return breach
+}
+
+// Compliance ratio over the window.
+func (st *slaState) compliance() float64 {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	return st.runs.ratio()
+}
+
+// Record the run of an entry with an SLA, emitting an EventSLABreach when compliance falls below target.
+func (s *scheduler) recordSLA(e *entry, d time.Duration, err error) {
+	if e.sla == nil {
+		return
+	}
+	if e.sla.record(d, err) {
+		s.emit(Event{
+			Kind:     EventSLABreach,
+			Tick:     s.Ticks(),
+			Task:     e.task,
+			TaskID:   e.id,
+			Duration: d,
+			Err:      fmt.Errorf("compliance %.2f %% is below target %.2f %%", 100*e.sla.compliance(), 100*e.sla.sla.Target),
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+// WithSmoothing smooths the runs of the tasks of period minPeriod or more, through a leaky bucket : when they are
+// due, they are queued, and at most rate queued runs are started per tick, in the order they were due. A burst of
+// tasks due at once, such as thousands of tasks at the same offset of a long period, is spread over the following
+// ticks instead of a single spike. A 0 or negative rate disables smoothing, which is the default.
+func WithSmoothing(minPeriod, rate int) Option {
+	return func(s *scheduler) {
+		s.smoothPeriod, s.smoothRate = max(minPeriod, 1), max(rate, 0)
+	}
+}
+
+// Number of runs queued by smoothing, waiting for a tick.
+func (s *scheduler) SmoothingBacklog() int {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	return len(s.bucket)
+}
+
+// unsafe check that a due entry of period p is smoothed.
+func (s *scheduler) smoothed(p int) bool {
+	return s.smoothRate > 0 && p >= s.smoothPeriod
+}
+
+// unsafe queueing of a due entry in the bucket, unless it is already queued.
+func (s *scheduler) enqueue(e *entry) {
+	if !e.queued {
+		e.queued = true
+		s.bucket = append(s.bucket, e)
+	}
+}
+
+// unsafe addition to due of the next runs leaking from the bucket, all of them if smoothing is disabled.
+func (s *scheduler) leak(due []*entry) []*entry {
+	n := len(s.bucket)
+	if s.smoothRate > 0 {
+		n = min(n, s.smoothRate)
+	}
+	for _, e := range s.bucket[:n] {
+		e.queued = false
+		if s.runnable(e) { // paused meanwhile, the run is dropped
+			due = append(due, e)
+		}
+	}
+	s.bucket = s.bucket[n:]
+	if len(s.bucket) == 0 {
+		s.bucket = nil // release the backing array of a large burst
+	}
+	return due
+}
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TaskFactory creates the task bound to a name when a schedule is loaded, nil if the name is unknown.
+type TaskFactory func(name string) Task
+
+// Return a TaskFactory binding the names of tasks to their task.
+func TasksByName(tasks map[string]Task) TaskFactory {
+	return func(name string) Task {
+		return tasks[name]
+	}
+}
+
+// Snapshot is the serialized schedule written by SaveSchedule and read by LoadSchedule.
+type Snapshot struct {
+	Tick   int         `json:"tick"`   // tick counter when saved
+	Specs  []Spec      `json:"specs"`  // specs of the named periodic tasks, sorted by name
+	States []TaskState `json:"states"` // run states of the named periodic tasks
+}
+
+// Write the schedule as JSON to w, to resume it after a restart with LoadSchedule : the specs of the named periodic
+// tasks, including their periods and offsets, their run states, with the runs left of AddTimes, and the tick
+// counter. Unnamed tasks and policies are not saved, as with Checkpoint.
+func (s *scheduler) SaveSchedule(w io.Writer) error {
+	var snap Snapshot
+	snap.Specs, snap.States, snap.Tick = s.saved()
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// Read a schedule written by SaveSchedule from r, and add its tasks, created by name with factory, resuming their
+// run state : they run at the same number of ticks from now as they were due when saved. A scheduler that never
+// ticked also resumes the tick counter. All the tasks are added, or none of them if a name is unknown to factory,
+// with an error matching ErrTaskNotFound.
+func (s *scheduler) LoadSchedule(r io.Reader, factory TaskFactory) error {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("unable to read the schedule : %w", err)
+	}
+	tasks := map[string]Task{}
+	for _, sp := range snap.Specs {
+		if t := factory(sp.Name); t != nil {
+			tasks[sp.Name] = t
+		}
+	}
+
+	fresh := s.setTicks(0, snap.Tick)
+	err := s.resume(snap.Specs, snap.States, tasks)
+	if err != nil && fresh {
+		s.setTicks(snap.Tick, 0) // nothing added
+	}
+	return err
+}
+
+// Set the tick counter to tick if it is from, and the scheduler has no tick running, returning whether it was set.
+func (s *scheduler) setTicks(from, tick int) bool {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+	s.lockstats.Lock()
+	defer s.lockstats.Unlock()
+
+	if s.ticks != from || s.inflight != 0 {
+		return false
+	}
+	s.ticks = max(tick, 0)
+	return true
+}
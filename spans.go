@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Span is a span of a distributed trace, such as an OpenTelemetry span.
+type Span interface {
+	// End the span, with the attributes of the operation and its error, nil if it succeeded.
+	End(attrs []slog.Attr, err error)
+}
+
+// SpanTracer starts the spans of the ticks and task runs, such as an adapter of an OpenTelemetry tracer :
+//
+//	func (a otelAdapter) Start(ctx context.Context, name string) (context.Context, scheduler.Span) {
+//		ctx, span := a.tracer.Start(ctx, name)
+//		return ctx, otelSpan{span} // End sets the attributes, records the error and ends span
+//	}
+//
+// Task spans are children of the span of their tick. Tasks implementing TaskCtx receive the context of their
+// span, so the spans they start themselves are its children.
+type SpanTracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Names of the spans.
+const (
+	SpanTick = "scheduler.tick"
+	SpanTask = "scheduler.task"
+)
+
+// spanState holds the tracer set with SetSpanTracer, nil if spans are disabled.
+type spanState struct {
+	tracer SpanTracer
+}
+
+// Set the tracer starting a span for each tick, and a child span for each task run. Nil disables spans.
+func (s *scheduler) SetSpanTracer(t SpanTracer) {
+	s.spans.Store(&spanState{tracer: t})
+}
+
+// Start a span named name, child of ctx. The span is nil if spans are disabled.
+func (s *scheduler) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	st := s.spans.Load()
+	if st == nil || st.tracer == nil {
+		return ctx, nil
+	}
+	return st.tracer.Start(ctx, name)
+}
+
+// End the span of a tick.
+func endTickSpan(span Span, run *tickRun, busy time.Duration) {
+	if span == nil {
+		return
+	}
+	span.End([]slog.Attr{
+		slog.Int("scheduler.tick", run.tick),
+		slog.Int("scheduler.tick.runs", len(run.ran)),
+		slog.Int("scheduler.tick.errors", len(run.errs)),
+		slog.Duration("scheduler.tick.duration", busy),
+	}, nil)
+}
+
+// End the span of a task run.
+func endTaskSpan(span Span, e *entry, tick int, d time.Duration, err error) {
+	if span == nil {
+		return
+	}
+	span.End([]slog.Attr{
+		slog.String("scheduler.task.name", e.label()),
+		slog.Int("scheduler.task.period", e.period),
+		slog.Int("scheduler.tick", tick),
+		slog.Duration("scheduler.task.duration", d),
+	}, err)
+}
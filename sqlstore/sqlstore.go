@@ -0,0 +1,261 @@
+// Package sqlstore implements scheduler.Store on top of database/sql.
+//
+// It only uses portable SQL, and was written for SQLite and Postgres. The driver is up to the caller :
+//
+//	db, err := sql.Open("sqlite3", "schedule.db")
+//	st, err := sqlstore.New(db, sqlstore.SQLite)
+//	s.SetStore(st)
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xavier268/scheduler"
+)
+
+// Dialect adapts the queries to a database.
+type Dialect int
+
+const (
+	SQLite   Dialect = iota // ? placeholders, also suits MySQL
+	Postgres                // $1 placeholders
+)
+
+// Fixed width time layout, so that stored times sort as strings.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Store is a scheduler.RunStore persisting to a database, in tables sharing a prefix.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	prefix  string
+}
+
+var _ scheduler.RunStore = &Store{}
+
+// Create a store using db, creating its tables if needed, named with the "scheduler_" prefix.
+func New(db *sql.DB, d Dialect) (*Store, error) {
+	return NewWithPrefix(db, d, "scheduler_")
+}
+
+// Create a store using db, creating its tables if needed, named with prefix.
+// Several schedulers can share a database with different prefixes.
+func NewWithPrefix(db *sql.DB, d Dialect, prefix string) (*Store, error) {
+	st := &Store{db: db, dialect: d, prefix: prefix}
+	for _, ddl := range []string{
+		"CREATE TABLE IF NOT EXISTS %sspecs (name TEXT PRIMARY KEY, spec TEXT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS %sstates (name TEXT PRIMARY KEY, due INTEGER NOT NULL, failures INTEGER NOT NULL, " +
+			"runs INTEGER NOT NULL, left_runs INTEGER NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS %sdead_letters (name TEXT NOT NULL, tick INTEGER NOT NULL, at TEXT NOT NULL, err TEXT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS %sruns (run_key TEXT PRIMARY KEY, done INTEGER NOT NULL)",
+	} {
+		if _, err := db.Exec(fmt.Sprintf(ddl, prefix)); err != nil {
+			return nil, fmt.Errorf("creating tables : %w", err)
+		}
+	}
+	if err := st.migrate(); err != nil {
+		return nil, fmt.Errorf("migrating tables : %w", err)
+	}
+	return st, nil
+}
+
+// Columns added to the tables since their first version, with their definition.
+var migrations = []struct{ table, column, def string }{
+	{"states", "runs", "INTEGER NOT NULL DEFAULT 0"},
+	{"states", "left_runs", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// Add the columns missing from tables created by an older version.
+func (st *Store) migrate() error {
+	for _, m := range migrations {
+		probe := st.query("SELECT " + m.column + " FROM %s" + m.table + " WHERE 1 = 0")
+		rows, err := st.db.Query(probe)
+		if err == nil {
+			rows.Close()
+			continue
+		}
+		if _, err := st.db.Exec(st.query("ALTER TABLE %s" + m.table + " ADD COLUMN " + m.column + " " + m.def)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rewrite a query with ? placeholders for the dialect, and the table prefix for %s.
+func (st *Store) query(q string) string {
+	q = strings.ReplaceAll(q, "%s", st.prefix)
+	if st.dialect != Postgres {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Replace the content of a table with rows, in a transaction.
+func (st *Store) replace(table, insert string, rows [][]any) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(st.query("DELETE FROM %s" + table)); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if _, err := tx.Exec(st.query(insert), r...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Save specs, replacing the saved ones. Each spec is stored as JSON, without its task and policy.
+func (st *Store) SaveSpecs(specs []scheduler.Spec) error {
+	rows := make([][]any, len(specs))
+	for i, sp := range specs {
+		data, err := json.Marshal(sp)
+		if err != nil {
+			return err
+		}
+		rows[i] = []any{sp.Name, string(data)}
+	}
+	return st.replace("specs", "INSERT INTO %sspecs (name, spec) VALUES (?, ?)", rows)
+}
+
+// Load the saved specs, sorted by name.
+func (st *Store) LoadSpecs() ([]scheduler.Spec, error) {
+	rows, err := st.db.Query(st.query("SELECT spec FROM %sspecs ORDER BY name"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var specs []scheduler.Spec
+	for rows.Next() {
+		var data string
+		var sp scheduler.Spec
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &sp); err != nil {
+			return nil, err
+		}
+		specs = append(specs, sp)
+	}
+	return specs, rows.Err()
+}
+
+// Save the run states of the tasks, replacing the saved ones.
+func (st *Store) SaveStates(states []scheduler.TaskState) error {
+	rows := make([][]any, len(states))
+	for i, ts := range states {
+		rows[i] = []any{ts.Name, ts.Due, ts.Failures, ts.Runs, ts.Left}
+	}
+	return st.replace("states", "INSERT INTO %sstates (name, due, failures, runs, left_runs) VALUES (?, ?, ?, ?, ?)", rows)
+}
+
+// Load the saved run states, sorted by name.
+func (st *Store) LoadStates() ([]scheduler.TaskState, error) {
+	rows, err := st.db.Query(st.query("SELECT name, due, failures, runs, left_runs FROM %sstates ORDER BY name"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []scheduler.TaskState
+	for rows.Next() {
+		var ts scheduler.TaskState
+		if err := rows.Scan(&ts.Name, &ts.Due, &ts.Failures, &ts.Runs, &ts.Left); err != nil {
+			return nil, err
+		}
+		states = append(states, ts)
+	}
+	return states, rows.Err()
+}
+
+// Record the dead letter of a task removed by its error policy.
+func (st *Store) AddDeadLetter(d scheduler.DeadLetter) error {
+	_, err := st.db.Exec(st.query("INSERT INTO %sdead_letters (name, tick, at, err) VALUES (?, ?, ?, ?)"),
+		d.Name, d.Tick, d.Time.UTC().Format(timeLayout), d.Err)
+	return err
+}
+
+// List the dead letters recorded, oldest first.
+func (st *Store) DeadLetters() ([]scheduler.DeadLetter, error) {
+	rows, err := st.db.Query(st.query("SELECT name, tick, at, err FROM %sdead_letters ORDER BY at"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []scheduler.DeadLetter
+	for rows.Next() {
+		var d scheduler.DeadLetter
+		var at string
+		if err := rows.Scan(&d.Name, &d.Tick, &at, &d.Err); err != nil {
+			return nil, err
+		}
+		if d.Time, err = time.Parse(timeLayout, at); err != nil {
+			return nil, err
+		}
+		letters = append(letters, d)
+	}
+	return letters, rows.Err()
+}
+
+// Record the start of the run identified by key. It returns false if key was already recorded, by this store or
+// another one sharing the database.
+func (st *Store) BeginRun(key string) (bool, error) {
+	res, err := st.db.Exec(st.query("INSERT INTO %sruns (run_key, done) VALUES (?, 0) ON CONFLICT (run_key) DO NOTHING"), key)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Record the completion of the run identified by key, recording key if its start was not.
+func (st *Store) EndRun(key string) error {
+	res, err := st.db.Exec(st.query("UPDATE %sruns SET done = 1 WHERE run_key = ?"), key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+	_, err = st.db.Exec(st.query("INSERT INTO %sruns (run_key, done) VALUES (?, 1)"), key)
+	return err
+}
+
+// List the keys of the runs started and not completed, sorted.
+func (st *Store) PendingRuns() ([]string, error) {
+	rows, err := st.db.Query(st.query("SELECT run_key FROM %sruns WHERE done = 0 ORDER BY run_key"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
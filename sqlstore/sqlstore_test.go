@@ -0,0 +1,563 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xavier268/scheduler"
+)
+
+// fakeDriver is a database/sql driver understanding the few statements of the store, on in-memory tables.
+// Each name opened is a separate database, whose placeholders are "?" or, if the name starts with "pg:", "$1".
+type fakeDriver struct {
+	lock sync.Mutex
+	dbs  map[string]*fakeDB
+}
+
+// fakeDB is a database of a fakeDriver.
+type fakeDB struct {
+	lock     sync.Mutex
+	postgres bool
+	tables   map[string]*fakeTable
+	queries  []string // statements received, as written
+}
+
+// fakeTable is a table of a fakeDB.
+type fakeTable struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+var fake = &fakeDriver{dbs: map[string]*fakeDB{}}
+
+func init() {
+	sql.Register("fake", fake)
+}
+
+// Open a new database on the fake driver, returning it with its state.
+func openFake(t *testing.T, postgres bool) (*sql.DB, *fakeDB) {
+	name := t.Name()
+	if postgres {
+		name = "pg:" + name
+	}
+	db, err := sql.Open("fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fdb := &fakeDB{postgres: postgres, tables: map[string]*fakeTable{}}
+	fake.dbs[name] = fdb
+	return db, fdb
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	db, ok := d.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q", name)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+// fakeConn is a connection to a fakeDB. A transaction keeps a copy of the tables to restore on rollback.
+type fakeConn struct {
+	db     *fakeDB
+	backup map[string]*fakeTable
+}
+
+func (c *fakeConn) Prepare(q string) (driver.Stmt, error) { return &fakeStmt{c: c, q: q}, nil }
+func (c *fakeConn) Close() error                          { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.lock.Lock()
+	defer c.db.lock.Unlock()
+	c.backup = map[string]*fakeTable{}
+	for name, tb := range c.db.tables {
+		cp := &fakeTable{cols: append([]string(nil), tb.cols...)}
+		for _, r := range tb.rows {
+			cp.rows = append(cp.rows, append([]driver.Value(nil), r...))
+		}
+		c.backup[name] = cp
+	}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.backup = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.lock.Lock()
+	defer c.db.lock.Unlock()
+	c.db.tables, c.backup = c.backup, nil
+	return nil
+}
+
+// fakeStmt is a statement of a fakeConn.
+type fakeStmt struct {
+	c *fakeConn
+	q string
+}
+
+func (st *fakeStmt) Close() error  { return nil }
+func (st *fakeStmt) NumInput() int { return -1 }
+
+func (st *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, n, err := st.c.db.run(st.q, args)
+	return driver.RowsAffected(n), err
+}
+
+func (st *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := st.c.db.run(st.q, args)
+	return rows, err
+}
+
+// fakeRows are the rows returned by a query.
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var (
+	reCreate = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)$`)
+	reAlter  = regexp.MustCompile(`^ALTER TABLE (\w+) ADD COLUMN (\w+) \w+ NOT NULL DEFAULT (\S+)$`)
+	reDelete = regexp.MustCompile(`^DELETE FROM (\w+)$`)
+	reInsert = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) VALUES \(([^)]*)\)( ON CONFLICT \(\w+\) DO NOTHING)?$`)
+	reUpdate = regexp.MustCompile(`^UPDATE (\w+) SET (\w+) = (\S+) WHERE (\w+) = \?$`)
+	reSelect = regexp.MustCompile(`^SELECT (.+?) FROM (\w+)(?: WHERE (.+?))?(?: ORDER BY (\w+))?$`)
+	reParam  = regexp.MustCompile(`\$(\d+)`)
+)
+
+// Run the statement q with args, returning the rows selected or the number of rows affected.
+func (db *fakeDB) run(q string, args []driver.Value) (*fakeRows, int64, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.queries = append(db.queries, q)
+
+	if db.postgres { // $n placeholders, numbered in order, rewritten to ?
+		if strings.Contains(q, "?") {
+			return nil, 0, fmt.Errorf("syntax error at or near \"?\" in %q", q)
+		}
+		n := 0
+		var err error
+		q = reParam.ReplaceAllStringFunc(q, func(p string) string {
+			if n++; p != "$"+strconv.Itoa(n) {
+				err = fmt.Errorf("placeholder %s out of order in %q", p, q)
+			}
+			return "?"
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	} else if reParam.MatchString(q) {
+		return nil, 0, fmt.Errorf("unrecognized token \"$\" in %q", q)
+	}
+	if strings.Count(q, "?") != len(args) {
+		return nil, 0, fmt.Errorf("%d arguments for %q", len(args), q)
+	}
+	next := func() driver.Value {
+		v := args[0]
+		args = args[1:]
+		return v
+	}
+	value := func(token string) driver.Value {
+		if token == "?" {
+			return next()
+		}
+		if s, ok := strings.CutPrefix(token, "'"); ok {
+			return strings.TrimSuffix(s, "'")
+		}
+		n, _ := strconv.ParseInt(token, 10, 64)
+		return n
+	}
+	table := func(name string) (*fakeTable, error) {
+		if tb, ok := db.tables[name]; ok {
+			return tb, nil
+		}
+		return nil, fmt.Errorf("no such table: %s", name)
+	}
+
+	if m := reCreate.FindStringSubmatch(q); m != nil {
+		if _, ok := db.tables[m[1]]; !ok {
+			tb := &fakeTable{}
+			for _, def := range strings.Split(m[2], ", ") {
+				tb.cols = append(tb.cols, strings.Fields(def)[0])
+			}
+			db.tables[m[1]] = tb
+		}
+		return nil, 0, nil
+	}
+	if m := reAlter.FindStringSubmatch(q); m != nil {
+		tb, err := table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		if tb.index(m[2]) >= 0 {
+			return nil, 0, fmt.Errorf("duplicate column name: %s", m[2])
+		}
+		tb.cols = append(tb.cols, m[2])
+		for i := range tb.rows {
+			tb.rows[i] = append(tb.rows[i], value(m[3]))
+		}
+		return nil, 0, nil
+	}
+	if m := reDelete.FindStringSubmatch(q); m != nil {
+		tb, err := table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		n := int64(len(tb.rows))
+		tb.rows = nil
+		return nil, n, nil
+	}
+	if m := reInsert.FindStringSubmatch(q); m != nil {
+		tb, err := table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		cols, vals := strings.Split(m[2], ", "), strings.Split(m[3], ", ")
+		row := make([]driver.Value, len(tb.cols))
+		for i, c := range cols {
+			k := tb.index(c)
+			if k < 0 {
+				return nil, 0, fmt.Errorf("no column %s in %s", c, m[1])
+			}
+			row[k] = value(vals[i])
+		}
+		for i, c := range tb.cols {
+			if row[i] == nil {
+				return nil, 0, fmt.Errorf("NOT NULL constraint failed: %s.%s", m[1], c)
+			}
+		}
+		if k := tb.index("run_key"); k >= 0 {
+			for _, r := range tb.rows {
+				if r[k] == row[k] && m[4] != "" {
+					return nil, 0, nil
+				} else if r[k] == row[k] {
+					return nil, 0, errors.New("UNIQUE constraint failed: run_key")
+				}
+			}
+		}
+		tb.rows = append(tb.rows, row)
+		return nil, 1, nil
+	}
+	if m := reUpdate.FindStringSubmatch(q); m != nil {
+		tb, err := table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		set, where := tb.index(m[2]), tb.index(m[4])
+		v := value(m[3])
+		key := next()
+		var n int64
+		for _, r := range tb.rows {
+			if r[where] == key {
+				r[set], n = v, n+1
+			}
+		}
+		return nil, n, nil
+	}
+	if m := reSelect.FindStringSubmatch(q); m != nil {
+		tb, err := table(m[2])
+		if err != nil {
+			return nil, 0, err
+		}
+		cols := strings.Split(m[1], ", ")
+		for _, c := range cols {
+			if c != "COUNT(*)" && tb.index(c) < 0 {
+				return nil, 0, fmt.Errorf("no such column: %s", c)
+			}
+		}
+		match := func([]driver.Value) bool { return true }
+		switch w := m[3]; {
+		case w == "1 = 0":
+			match = func([]driver.Value) bool { return false }
+		case w != "":
+			c, v, _ := strings.Cut(w, " = ")
+			k := tb.index(c)
+			if k < 0 {
+				return nil, 0, fmt.Errorf("no such column: %s", c)
+			}
+			want := value(v)
+			match = func(r []driver.Value) bool { return r[k] == want }
+		}
+		var rows [][]driver.Value
+		for _, r := range tb.rows {
+			if match(r) {
+				rows = append(rows, r)
+			}
+		}
+		if m[4] != "" {
+			k := tb.index(m[4])
+			sort.SliceStable(rows, func(i, j int) bool { return fmt.Sprint(rows[i][k]) < fmt.Sprint(rows[j][k]) })
+		}
+		if m[1] == "COUNT(*)" {
+			return &fakeRows{cols: cols, rows: [][]driver.Value{{int64(len(rows))}}}, 0, nil
+		}
+		res := &fakeRows{cols: cols}
+		for _, r := range rows {
+			out := make([]driver.Value, len(cols))
+			for i, c := range cols {
+				out[i] = r[tb.index(c)]
+			}
+			res.rows = append(res.rows, out)
+		}
+		return res, 0, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported statement %q", q)
+}
+
+// Index of the column named c, -1 if none.
+func (tb *fakeTable) index(c string) int {
+	for i, col := range tb.cols {
+		if col == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestQuery(t *testing.T) {
+	st := &Store{dialect: SQLite, prefix: "p_"}
+	if q := st.query("SELECT a FROM %st WHERE b = ? AND c = ?"); q != "SELECT a FROM p_t WHERE b = ? AND c = ?" {
+		t.Fatalf("Unexpected SQLite query %q", q)
+	}
+	st.dialect = Postgres
+	if q := st.query("SELECT a FROM %st WHERE b = ? AND c = ?"); q != "SELECT a FROM p_t WHERE b = $1 AND c = $2" {
+		t.Fatalf("Unexpected Postgres query %q", q)
+	}
+}
+
+func TestStore(t *testing.T) {
+	for _, d := range []Dialect{SQLite, Postgres} {
+		t.Run(map[Dialect]string{SQLite: "SQLite", Postgres: "Postgres"}[d], func(t *testing.T) {
+			db, fdb := openFake(t, d == Postgres)
+			st, err := New(db, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			specs := []scheduler.Spec{{Name: "b", Period: 3}, {Name: "a", Period: 2, Offset: 1, FixedOffset: true}}
+			if err := st.SaveSpecs(specs); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.SaveSpecs(specs[:1]); err != nil { // replaces
+				t.Fatal(err)
+			}
+			got, err := st.LoadSpecs()
+			if err != nil || len(got) != 1 || got[0].Name != "b" || got[0].Period != 3 {
+				t.Fatalf("Unexpected specs %+v, %v", got, err)
+			}
+
+			states := []scheduler.TaskState{{Name: "b", Due: 2, Failures: 1, Runs: 7, Left: 4}, {Name: "a"}}
+			if err := st.SaveStates(states); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := st.LoadStates()
+			if err != nil || !reflect.DeepEqual(loaded, []scheduler.TaskState{states[1], states[0]}) {
+				t.Fatalf("Unexpected states %+v, %v", loaded, err)
+			}
+
+			at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+			letters := []scheduler.DeadLetter{{Name: "late", Tick: 9, Time: at.Add(time.Second), Err: "boom"},
+				{Name: "early", Tick: 4, Time: at.In(time.FixedZone("x", 3600)), Err: "bang"}}
+			for _, l := range letters {
+				if err := st.AddDeadLetter(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dl, err := st.DeadLetters()
+			if err != nil || len(dl) != 2 || dl[0].Name != "early" || !dl[0].Time.Equal(at) || dl[1].Err != "boom" {
+				t.Fatalf("Unexpected dead letters %+v, %v", dl, err)
+			}
+
+			if ok, err := st.BeginRun("a#1"); !ok || err != nil {
+				t.Fatalf("Expected a fresh run, got %v, %v", ok, err)
+			}
+			if ok, err := st.BeginRun("a#1"); ok || err != nil {
+				t.Fatalf("Expected a recorded run, got %v, %v", ok, err)
+			}
+			if _, err := st.BeginRun("a#2"); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.EndRun("a#1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := st.EndRun("a#3"); err != nil { // completion without start
+				t.Fatal(err)
+			}
+			if ok, _ := st.BeginRun("a#3"); ok {
+				t.Fatal("Expected a#3 to be recorded")
+			}
+			if keys, err := st.PendingRuns(); err != nil || !reflect.DeepEqual(keys, []string{"a#2"}) {
+				t.Fatalf("Unexpected pending runs %v, %v", keys, err)
+			}
+
+			for _, q := range fdb.queries {
+				if strings.Contains(q, "%s") || !strings.Contains(q, "scheduler_") {
+					t.Fatalf("Unexpected table names in %q", q)
+				}
+			}
+		})
+	}
+}
+
+func TestBeginRunConcurrent(t *testing.T) {
+	db, _ := openFake(t, false)
+	st, err := New(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var fresh atomic.Int32
+	for i := 0; i < 8; i++ { // as many instances sharing the database
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, err := st.BeginRun("a#1"); ok && err == nil {
+				fresh.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if fresh.Load() != 1 {
+		t.Fatalf("Expected a single run to start, got %d", fresh.Load())
+	}
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	db, _ := openFake(t, false)
+	st, err := NewWithPrefix(db, SQLite, "other_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := scheduler.NoopTask()
+	s := scheduler.New()
+	s.SetStore(st)
+	if errs := s.AddBatch([]scheduler.Spec{{Name: "t", Task: tk, Period: 3}}); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	if err := s.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := scheduler.New()
+	r.SetStore(st)
+	if err := r.Restore(map[string]scheduler.Task{"t": tk}); err != nil {
+		t.Fatal(err)
+	}
+	if info, ok := r.NextRun(tk); !ok || info.Period != 3 || info.Name != "t" {
+		t.Fatalf("Unexpected restored task %+v, %v", info, ok)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db, _ := openFake(t, true)
+	for _, ddl := range []string{ // first version of the states table
+		"CREATE TABLE IF NOT EXISTS scheduler_states (name TEXT PRIMARY KEY, due INTEGER NOT NULL, failures INTEGER NOT NULL)",
+		"INSERT INTO scheduler_states (name, due, failures) VALUES ('old', 1, 2)",
+	} {
+		if _, err := db.Exec(ddl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := New(db, Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states, err := st.LoadStates(); err != nil || len(states) != 1 || states[0].Runs != 0 || states[0].Left != 0 {
+		t.Fatalf("Unexpected migrated states %+v, %v", states, err)
+	}
+	want := []scheduler.TaskState{{Name: "t", Due: 1, Runs: 3, Left: 2}}
+	if err := st.SaveStates(want); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(db, Postgres); err != nil { // already migrated
+		t.Fatal(err)
+	}
+	if states, err := st.LoadStates(); err != nil || !reflect.DeepEqual(states, want) {
+		t.Fatalf("Unexpected states %+v, %v", states, err)
+	}
+}
+
+func TestRestoreRunKeys(t *testing.T) {
+	db, _ := openFake(t, false)
+	st, err := New(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs int
+	tk := scheduler.TaskOf(func() { runs++ })
+	s := scheduler.New()
+	s.SetStore(st)
+	if errs := s.AddBatch([]scheduler.Spec{{Name: "t", Task: tk, Period: 1, RunKeys: true}}); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	s.RunFor(3, time.Millisecond)
+	if err := s.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := scheduler.New()
+	r.SetStore(st)
+	if err := r.Restore(map[string]scheduler.Task{"t": tk}); err != nil {
+		t.Fatal(err)
+	}
+	r.RunFor(2, time.Millisecond)
+	if runs != 5 {
+		t.Fatalf("Expected the restored task to run with new run keys, got %d runs", runs)
+	}
+}
+
+func TestRestoreTimes(t *testing.T) {
+	db, _ := openFake(t, false)
+	st, err := New(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveSpecs([]scheduler.Spec{{Name: "t", Period: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveStates([]scheduler.TaskState{{Name: "t", Left: 2}}); err != nil { // saved after 1 of 3 runs
+		t.Fatal(err)
+	}
+
+	var runs int
+	r := scheduler.New()
+	r.SetStore(st)
+	if err := r.Restore(map[string]scheduler.Task{"t": scheduler.TaskOf(func() { runs++ })}); err != nil {
+		t.Fatal(err)
+	}
+	r.RunFor(4, time.Millisecond)
+	if runs != 2 {
+		t.Fatalf("Expected the restored task to retire after its 2 runs left, got %d runs", runs)
+	}
+}
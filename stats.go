@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// TaskStat summarizes the traced durations of a scheduled task.
+type TaskStat struct {
+	Task    Task          // scheduled task
+	Period  int           // period of the task, in ticks
+	Count   int64         // number of traced runs
+	Average time.Duration // average run duration
+	Max     time.Duration // maximum run duration
+	P50     time.Duration // median run duration
+	P99     time.Duration // 99th percentile of the run durations
+	Total   time.Duration // cumulative run duration
+}
+
+// Return the stats of the k traced tasks with the highest average duration, slowest first.
+// Only tasks traced with WithTracing or wrapped in a TaskTracer are considered. A negative k returns all of them.
+func (s *scheduler) TopSlow(k int) []TaskStat {
+	return topSlow(s.traceStats(), k, byAverage)
+}
+
+// Return the stats of the k traced tasks with the highest 99th percentile duration, slowest first, to find the
+// tasks with the worst tail latency. See TopSlow.
+func (s *scheduler) TopSlowP99(k int) []TaskStat {
+	return topSlow(s.traceStats(), k, byP99)
+}
+
+// Sort keys of the slowest tasks.
+func byAverage(st TaskStat) time.Duration { return st.Average }
+func byP99(st TaskStat) time.Duration     { return st.P99 }
+
+// Sort stats by decreasing key, keeping the k first ones, or all of them if k is negative.
+func topSlow(stats []TaskStat, k int, key func(TaskStat) time.Duration) []TaskStat {
+	sort.SliceStable(stats, func(i, j int) bool { return key(stats[i]) > key(stats[j]) })
+	if k >= 0 && k < len(stats) {
+		stats = stats[:k]
+	}
+	return stats
+}
+
+// Get the stats of the traced periodic tasks.
+func (s *scheduler) traceStats() []TaskStat {
+	s.locktasks.Lock()
+	var stats []TaskStat
+	for p, v := range s.tasks {
+		for _, e := range v {
+			if tr := e.tracer(); tr != nil {
+				stats = append(stats, tr.stat(e.task, p))
+			}
+		}
+	}
+	s.locktasks.Unlock()
+	return stats
+}
+
+// stat summarizes the tracer as a TaskStat for task, of the given period.
+func (t *TaskTracer) stat(task Task, period int) TaskStat {
+	return TaskStat{
+		Task:    task,
+		Period:  period,
+		Count:   t.Count(),
+		Average: t.AverageDuration(),
+		Max:     t.MaxDuration(),
+		P50:     t.Percentile(50),
+		P99:     t.Percentile(99),
+		Total:   t.CumulativeDuration(),
+	}
+}
+
+// Stats is a snapshot of the scheduler statistics, read at once.
+// Durations are in nanoseconds when marshalled to JSON.
+type Stats struct {
+	Ticks         int           `json:"ticks"`         // number of ticks since start
+	Elapsed       time.Duration `json:"elapsed"`       // calculated elapsed duration, see Elapsed
+	ActualElapsed time.Duration `json:"actualElapsed"` // actual elapsed time, see ActualElapsed
+	Load          float64       `json:"load"`          // load, see Load
+	LastTick      time.Duration `json:"lastTick"`      // time spent in the last tick
+	MaxTick       time.Duration `json:"maxTick"`       // maximum time spent in a tick
+	BeforeHooks   time.Duration `json:"beforeHooks"`   // time spent in the before hooks of the last tick
+	AfterHooks    time.Duration `json:"afterHooks"`    // time spent in the after hooks of the last tick
+	Overruns      int           `json:"overruns"`      // number of ticks longer than the tick duration
+	Runs          int           `json:"runs"`          // number of task runs
+	Errors        int           `json:"errors"`        // number of failed task runs
+}
+
+// Get a snapshot of the statistics, consistent with each other, instead of calling the getters one by one.
+// Statistics are counted since start, or since the last ResetStats.
+func (s *scheduler) Stats() Stats {
+	s.lockstats.RLock()
+	defer s.lockstats.RUnlock()
+
+	st := Stats{
+		Ticks:         s.ticks,
+		Elapsed:       s.elapsed(),
+		ActualElapsed: s.actualElapsed(),
+		LastTick:      s.lastTick,
+		MaxTick:       s.maxTick,
+		BeforeHooks:   s.lastHooks[0],
+		AfterHooks:    s.lastHooks[1],
+		Overruns:      s.Overruns(),
+		Runs:          s.runs,
+		Errors:        s.failures,
+	}
+	if s.ticks > 0 && s.elapsed() > 0 { // no load before the tick duration is known
+		st.Load = float64(s.load) / float64(s.elapsed())
+	}
+	return st
+}
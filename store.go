@@ -0,0 +1,297 @@
+package scheduler
+
+import (
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoStore is returned when persisting without a store. It matches ErrInvalid.
+var ErrNoStore = newError("no store set", ErrInvalid)
+
+// Store persists the schedule of a scheduler : the specs of its named tasks, their run state, and the dead letters
+// of the tasks removed after a failure. Saving replaces the previous specs or states.
+// MemoryStore is an in-memory implementation, the sqlstore package a database/sql one.
+type Store interface {
+	SaveSpecs(specs []Spec) error
+	LoadSpecs() ([]Spec, error)
+	SaveStates(states []TaskState) error
+	LoadStates() ([]TaskState, error)
+	AddDeadLetter(d DeadLetter) error
+	DeadLetters() ([]DeadLetter, error)
+}
+
+// TaskState is the run state of a named periodic task.
+type TaskState struct {
+	Name     string `json:"name"`
+	Due      int    `json:"due"`            // number of ticks before the next run, 0 for the next tick, including skipped runs
+	Failures int    `json:"failures"`       // number of consecutive failed runs
+	Runs     int    `json:"runs"`           // number of runs recorded with idempotency keys
+	Left     int    `json:"left,omitempty"` // runs left before the task retires, 0 if unlimited, see AddTimes
+}
+
+// DeadLetter records a task removed by its error policy.
+type DeadLetter struct {
+	Name string    `json:"name"` // name of the task, or its printed value if unnamed
+	Tick int       `json:"tick"` // tick of the failed run
+	Time time.Time `json:"time"` // time the task was removed
+	Err  string    `json:"err"`  // error of the failed run
+}
+
+// Set the store used by Checkpoint and Restore, and receiving the dead letters. nil removes it.
+func (s *scheduler) SetStore(st Store) {
+	s.lockstore.Lock()
+	defer s.lockstore.Unlock()
+
+	s.store = st
+}
+
+// Get the store, nil if none.
+func (s *scheduler) getStore() Store {
+	s.lockstore.Lock()
+	defer s.lockstore.Unlock()
+
+	return s.store
+}
+
+// Save the specs and run states of the named periodic tasks to the store.
+// Unnamed tasks cannot be bound again on restore, they are not saved. Policies are not saved either.
+func (s *scheduler) Checkpoint() error {
+	st := s.getStore()
+	if st == nil {
+		return ErrNoStore
+	}
+
+	specs, states, _ := s.saved()
+	if err := st.SaveSpecs(specs); err != nil {
+		return err
+	}
+	return st.SaveStates(states)
+}
+
+// Get the specs, sorted by name, and the run states of the named periodic tasks, with the current tick.
+func (s *scheduler) saved() ([]Spec, []TaskState, int) {
+	s.locktasks.Lock()
+	tick := s.ticks + s.inflight
+	var specs []Spec
+	var states []TaskState
+	for _, v := range s.tasks {
+		for i, e := range v {
+			if e.name == "" {
+				continue
+			}
+			specs = append(specs, e.spec())
+			states = append(states, TaskState{Name: e.name, Due: e.due(i, tick), Failures: e.failures, Runs: e.runs,
+				Left: e.left})
+		}
+	}
+	s.locktasks.Unlock()
+
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return specs, states, tick
+}
+
+// Add the tasks saved in the store, bound by name to tasks, resuming their run state.
+// Restored tasks run at the same number of ticks from now as they were due when saved.
+// All the tasks are added, or none of them if a spec is rejected or has no task.
+func (s *scheduler) Restore(tasks map[string]Task) error {
+	st := s.getStore()
+	if st == nil {
+		return ErrNoStore
+	}
+	specs, err := st.LoadSpecs()
+	if err != nil {
+		return err
+	}
+	states, err := st.LoadStates()
+	if err != nil {
+		return err
+	}
+	return s.resume(specs, states, tasks)
+}
+
+// Add the tasks of specs, bound by name to tasks, resuming their run states. See Restore.
+func (s *scheduler) resume(specs []Spec, states []TaskState, tasks map[string]Task) error {
+	plan := SchedulePlan(specs)
+	if err := plan.Bind(tasks); err != nil {
+		return err
+	}
+
+	due := map[string]TaskState{}
+	for _, ts := range states {
+		due[ts.Name] = ts
+	}
+	s.locktasks.Lock()
+	tick := s.ticks + s.inflight
+	for i := range plan {
+		if ts, ok := due[plan[i].Name]; ok { // resume the phase
+			plan[i].FixedOffset, plan[i].Offset, plan[i].Jitter = true, tick+ts.Due, 0
+		}
+	}
+	s.locktasks.Unlock()
+
+	if err := s.ApplyPlan(plan); err != nil {
+		return err
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+	for _, ts := range states {
+		if e := s.named(ts.Name); e != nil && e.period > 0 {
+			e.failures, e.skip = ts.Failures, ts.Due/e.period // runs skipped after errors
+			e.runs, e.left = ts.Runs, ts.Left
+		}
+	}
+	return nil
+}
+
+// Record the entries removed by their error policy as dead letters in the store, if any.
+func (s *scheduler) deadLetters(run *tickRun, decisions map[*entry]decision) {
+	st := s.getStore()
+	if st == nil {
+		return
+	}
+	for e, d := range decisions {
+		if !d.remove {
+			continue
+		}
+		name := e.label()
+		dl := DeadLetter{Name: name, Tick: run.tick, Time: s.now(), Err: run.errs[e].Error()}
+		if err := st.AddDeadLetter(dl); err != nil {
+			s.log(slog.LevelError, "unable to record dead letter", "task", name, "error", err)
+		}
+	}
+}
+
+// Spec describing the entry.
+func (e *entry) spec() Spec {
+	return Spec{
+		Period:      e.period,
+		Task:        e.task,
+		Name:        e.name,
+		Tenant:      e.tenant,
+		Timeout:     e.timeout,
+		Policy:      e.policy,
+		Express:     e.express,
+		Offset:      e.offset,
+		FixedOffset: e.fixed,
+		Priority:    e.priority,
+		Exempt:      e.exempt,
+		Jitter:      e.jitter,
+		RunKeys:     e.keyed,
+		Commit:      e.commit,
+		Tags:        slices.Clone(e.tags),
+		Key:         e.key,
+	}
+}
+
+// Number of ticks from tick before the next run of a periodic entry at index i of its period.
+func (e *entry) due(i, tick int) int {
+	slot := i
+	if e.fixed {
+		slot = e.offset
+	}
+	return phase(slot-tick, e.period) + e.skip*e.period
+}
+
+// MemoryStore is a RunStore keeping everything in memory, safe for concurrent use.
+// Like a persistent store, it does not keep the tasks and policies of the specs.
+type MemoryStore struct {
+	lock    sync.Mutex
+	specs   []Spec
+	states  []TaskState
+	letters []DeadLetter
+	runs    map[string]bool // run keys, true once completed
+}
+
+var _ RunStore = &MemoryStore{}
+
+func (m *MemoryStore) SaveSpecs(specs []Spec) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.specs = make([]Spec, len(specs))
+	for i, sp := range specs {
+		sp.Task, sp.Policy = nil, nil // as if serialized
+		m.specs[i] = sp
+	}
+	return nil
+}
+
+func (m *MemoryStore) LoadSpecs() ([]Spec, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]Spec(nil), m.specs...), nil
+}
+
+func (m *MemoryStore) SaveStates(states []TaskState) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.states = append([]TaskState(nil), states...)
+	return nil
+}
+
+func (m *MemoryStore) LoadStates() ([]TaskState, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]TaskState(nil), m.states...), nil
+}
+
+func (m *MemoryStore) AddDeadLetter(d DeadLetter) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.letters = append(m.letters, d)
+	return nil
+}
+
+func (m *MemoryStore) DeadLetters() ([]DeadLetter, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]DeadLetter(nil), m.letters...), nil
+}
+
+func (m *MemoryStore) BeginRun(key string) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.runs[key]; ok {
+		return false, nil
+	}
+	if m.runs == nil {
+		m.runs = map[string]bool{}
+	}
+	m.runs[key] = false
+	return true, nil
+}
+
+func (m *MemoryStore) EndRun(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.runs == nil {
+		m.runs = map[string]bool{}
+	}
+	m.runs[key] = true
+	return nil
+}
+
+func (m *MemoryStore) PendingRuns() ([]string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var keys []string
+	for k, done := range m.runs {
+		if !done {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
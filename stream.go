@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format of a written event stream.
+type Format int
+
+const (
+	// One JSON object per line.
+	FormatJSON Format = iota
+	// One human readable line per event.
+	FormatText
+)
+
+// eventRecord is the JSON representation of an Event.
+type eventRecord struct {
+	Kind     string    `json:"kind"`
+	Tick     int       `json:"tick"`
+	Time     time.Time `json:"time"`
+	Task     string    `json:"task,omitempty"`
+	TaskID   TaskID    `json:"taskId,omitempty"`
+	Err      string    `json:"error,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+}
+
+// Write all the events to w, one per line, in the given format : ticks, task runs, errors and the other events.
+// Events are written by an Observer with the default queue, so a slow writer never blocks the tick loop.
+// Close the returned observation to stop the stream. Write errors are ignored.
+func (s *scheduler) StreamEvents(w io.Writer, format Format) *Observation {
+	return s.Observe(EventAll, streamObserver(w, format), 0)
+}
+
+// Observer writing the events to w, one per line.
+func streamObserver(w io.Writer, format Format) Observer {
+	enc := json.NewEncoder(w)
+	return ObserverFunc(func(ev Event) {
+		rec := eventRecord{Kind: ev.Kind.String(), Tick: ev.Tick, Time: ev.Time, TaskID: ev.TaskID}
+		if ev.Task != nil {
+			rec.Task = fmt.Sprint(ev.Task)
+		}
+		if ev.Err != nil {
+			rec.Err = ev.Err.Error()
+		}
+		if ev.Duration != 0 {
+			rec.Duration = ev.Duration.String()
+		}
+		if format == FormatJSON {
+			enc.Encode(rec)
+			return
+		}
+		line := fmt.Sprintf("%s tick %d %s", rec.Time.Format(time.RFC3339Nano), rec.Tick, rec.Kind)
+		if rec.TaskID != 0 {
+			line += " id=" + rec.TaskID.String()
+		}
+		for _, f := range [][2]string{{"task", rec.Task}, {"duration", rec.Duration}, {"error", rec.Err}} {
+			if f[1] != "" {
+				line += fmt.Sprintf(" %s=%q", f[0], f[1])
+			}
+		}
+		fmt.Fprintln(w, line)
+	})
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// TagStats are the statistics aggregated over the tasks carrying a tag.
+type TagStats struct {
+	Tag       string        // tag the statistics are aggregated for
+	Tasks     int           // number of tasks currently scheduled with the tag
+	Runs      int           // number of runs of the tagged tasks
+	Errors    int           // number of failed runs of the tagged tasks
+	Busy      time.Duration // cumulative duration spent running the tagged tasks
+	ErrorRate float64       // failed runs as a fraction of all runs
+	Load      float64       // busy duration as a fraction of the elapsed duration
+}
+
+// rollup accumulates the runs of the tasks carrying a tag.
+type rollup struct {
+	runs, errors int
+	busy         time.Duration
+}
+
+// Add tasks carrying tags, sheduled to run every 'period' ticks.
+// A task may carry several tags, its runs are accounted in each of them. See TagStats.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddTagged(period int, tags []string, t ...Task) {
+	if period <= 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, tags: slices.Clone(tags)})
+	}
+}
+
+// Get the statistics aggregated over the tasks carrying tag.
+func (s *scheduler) TagStats(tag string) TagStats {
+	s.locktasks.Lock()
+	var r rollup
+	if p, ok := s.tags[tag]; ok {
+		r = *p
+	}
+	nb := 0
+	s.each(func(e *entry) {
+		if slices.Contains(e.tags, tag) {
+			nb++
+		}
+	})
+	s.locktasks.Unlock()
+
+	st := TagStats{Tag: tag, Tasks: nb, Runs: r.runs, Errors: r.errors, Busy: r.busy}
+	if r.runs > 0 {
+		st.ErrorRate = float64(r.errors) / float64(r.runs)
+	}
+	if el := s.Elapsed(); el > 0 {
+		st.Load = float64(r.busy) / float64(el)
+	}
+	return st
+}
+
+// Get the statistics of all the tags, of tasks currently scheduled or that ran since the statistics were reset,
+// sorted by decreasing busy duration.
+func (s *scheduler) TagRollups() []TagStats {
+	s.locktasks.Lock()
+	tags := map[string]bool{}
+	for tag := range s.tags {
+		tags[tag] = true
+	}
+	s.each(func(e *entry) {
+		for _, tag := range e.tags {
+			tags[tag] = true
+		}
+	})
+	s.locktasks.Unlock()
+
+	stats := make([]TagStats, 0, len(tags))
+	for tag := range tags {
+		stats = append(stats, s.TagStats(tag))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Busy != stats[j].Busy {
+			return stats[i].Busy > stats[j].Busy
+		}
+		return stats[i].Tag < stats[j].Tag
+	})
+	return stats
+}
+
+// Account a run of e in the tag rollups of run. The run lock must be held.
+func (run *tickRun) tagAccount(e *entry, d time.Duration, err error) {
+	for _, tag := range e.tags {
+		r, ok := run.tags[tag]
+		if !ok {
+			r = &rollup{}
+			run.tags[tag] = r
+		}
+		r.runs++
+		r.busy += d
+		if err != nil {
+			r.errors++
+		}
+	}
+}
+
+// unsafe merge of the tag rollups of a tick.
+func (s *scheduler) tagMerge(tags map[string]*rollup) {
+	for tag, tr := range tags {
+		r, ok := s.tags[tag]
+		if !ok {
+			r = &rollup{}
+			s.tags[tag] = r
+		}
+		r.runs += tr.runs
+		r.errors += tr.errors
+		r.busy += tr.busy
+	}
+}
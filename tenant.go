@@ -0,0 +1,129 @@
+package scheduler
+
+import "time"
+
+// ErrTenantQuota is returned when adding tasks would exceed the tenant budget. It matches ErrScheduleFull.
+var ErrTenantQuota = newError("tenant task quota exceeded", ErrScheduleFull)
+
+// TenantBudget limits the resources a tenant can use in the scheduler.
+// Zero values mean unlimited.
+type TenantBudget struct {
+	MaxTasks int     // maximum number of tasks scheduled for the tenant
+	MaxShare float64 // maximum share of each tick duration spent running the tenant tasks
+}
+
+// TenantStats are the statistics of a tenant.
+type TenantStats struct {
+	Budget TenantBudget  // current budget
+	Tasks  int           // number of tasks currently scheduled
+	Busy   time.Duration // cumulative duration spent running the tenant tasks
+	Load   float64       // busy duration as a percentage of the elapsed duration
+	Shed   int           // number of runs skipped because the tenant share was exhausted
+}
+
+// tenant holds the budget and the statistics of a tenant.
+type tenant struct {
+	budget TenantBudget
+	busy   time.Duration
+	shed   int
+}
+
+// Add tasks belonging to tenant, sheduled to run every 'period' ticks.
+// If the tenant budget does not allow all the tasks, none is added and ErrTenantQuota is returned.
+// Negative or 0 period tasks are not scheduled.
+func (s *scheduler) AddTenant(tenant string, period int, t ...Task) error {
+	if period <= 0 {
+		return nil
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	if max := s.tenant(tenant).budget.MaxTasks; max > 0 && s.tenantTasks(tenant)+len(t) > max {
+		return ErrTenantQuota
+	}
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, tenant: tenant})
+	}
+	return nil
+}
+
+// Set the budget of a tenant.
+// Tasks already scheduled are kept even if they exceed the new MaxTasks.
+func (s *scheduler) SetTenantBudget(tenant string, b TenantBudget) {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	s.tenant(tenant).budget = b
+}
+
+// Get the statistics of a tenant.
+func (s *scheduler) TenantStats(name string) TenantStats {
+	s.locktasks.Lock()
+	var tn tenant
+	if p, ok := s.tenants[name]; ok {
+		tn = *p
+	}
+	nb := s.tenantTasks(name)
+	s.locktasks.Unlock()
+
+	st := TenantStats{
+		Budget: tn.budget,
+		Tasks:  nb,
+		Busy:   tn.busy,
+		Shed:   tn.shed,
+	}
+	if el := s.Elapsed(); el > 0 {
+		st.Load = float64(tn.busy) / float64(el)
+	}
+	return st
+}
+
+// unsafe tenant access, creating it if needed.
+func (s *scheduler) tenant(name string) *tenant {
+	tn, ok := s.tenants[name]
+	if !ok {
+		tn = &tenant{}
+		s.tenants[name] = tn
+	}
+	return tn
+}
+
+// unsafe count of the tasks of a tenant.
+func (s *scheduler) tenantTasks(name string) int {
+	nb := 0
+	for _, v := range s.tasks {
+		for _, e := range v {
+			if e.tenant == name {
+				nb++
+			}
+		}
+	}
+	return nb
+}
+
+// unsafe computation of the maximum duration each tenant may use during a tick of the given duration.
+// Only tenants with a limited share are returned.
+func (s *scheduler) tenantShares(duration time.Duration) map[string]time.Duration {
+	shares := map[string]time.Duration{}
+	if duration <= 0 {
+		return shares
+	}
+	for n, tn := range s.tenants {
+		if n != "" && tn.budget.MaxShare > 0 {
+			shares[n] = time.Duration(tn.budget.MaxShare * float64(duration))
+		}
+	}
+	return shares
+}
+
+// unsafe accounting of a tick usage per tenant.
+func (s *scheduler) tenantAccount(used map[string]time.Duration, shed map[string]int) {
+	for n, d := range used {
+		if n != "" {
+			s.tenant(n).busy += d
+		}
+	}
+	for n, nb := range shed {
+		s.tenant(n).shed += nb
+	}
+}
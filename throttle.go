@@ -0,0 +1,94 @@
+package scheduler
+
+import "math"
+
+// Multiply the period of all the registrations of t by factor, until Unthrottle.
+// A factor above 1 slows the task down, for instance to back off polling during incidents, a factor below 1
+// speeds it up, down to a period of 1 tick. Throttling again applies the new factor to the original period.
+// It returns false if factor is negative or 0, or if t is not scheduled.
+func (s *scheduler) Throttle(t Task, factor float64) bool {
+	if factor <= 0 {
+		return false
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	found := false
+	for _, e := range s.periodic(t) {
+		if e.base == 0 {
+			e.base = e.period
+		}
+		s.move(e, max(1, int(math.Round(float64(e.base)*factor))))
+		found = true
+	}
+	return found
+}
+
+// Restore the original period of all the registrations of t.
+// It returns false if t is not scheduled or not throttled.
+func (s *scheduler) Unthrottle(t Task) bool {
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	found := false
+	for _, e := range s.periodic(t) {
+		if e.base != 0 {
+			s.move(e, e.base)
+			e.base = 0
+			found = true
+		}
+	}
+	return found
+}
+
+// Change the period of all the periodic registrations of t, atomically with respect to the ticks, keeping their
+// history, statistics and IDs. Each registration next runs at the tick it was due, then every period ticks :
+// unlike a Remove followed by an Add, the phase is kept. The throttling of a throttled registration is cancelled.
+// It returns false if period is negative or 0, or if t is not scheduled.
+func (s *scheduler) Reschedule(t Task, period int) bool {
+	if period <= 0 {
+		return false
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	ee := s.periodic(t)
+	s.reschedule(ee, period)
+	return len(ee) > 0
+}
+
+// unsafe change of the period of periodic entries, each next running at the tick it was due.
+func (s *scheduler) reschedule(ee []*entry, period int) {
+	next := make([]int, len(ee))
+	for i, e := range ee {
+		next[i] = s.offset(e, s.index(e)).NextTick - e.skip*e.period // skipped runs are kept below
+	}
+	for i, e := range ee {
+		s.move(e, period)
+		e.fixed, e.offset, e.base = true, phase(next[i], period), 0
+	}
+}
+
+// unsafe list of the periodic registrations of t.
+func (s *scheduler) periodic(t Task) []*entry {
+	var ee []*entry
+	for _, v := range s.tasks {
+		for _, e := range v {
+			if sameTask(e.task, t) {
+				ee = append(ee, e)
+			}
+		}
+	}
+	return ee
+}
+
+// unsafe change of the period of a periodic entry, keeping its history.
+func (s *scheduler) move(e *entry, period int) {
+	if period == e.period {
+		return
+	}
+	s.unlink(e)
+	e.period = period
+	s.tasks[period] = append(s.tasks[period], e)
+	s.changed(ChangeRescheduled, e)
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrTaskTimeout is the error of a task run abandoned after its timeout. It matches ErrTimeout.
+var ErrTaskTimeout = newError("task timed out", ErrTimeout)
+
+// Add tasks sheduled to run every 'period' ticks, with a runtime budget of timeout for each run.
+// A TaskCtx sees its context cancelled when the timeout expires. Whether it honours the cancellation or not,
+// the scheduler stops waiting for a run exceeding its timeout: the run is abandoned, logged, and counts as
+// a failed run returning ErrTaskTimeout.
+// Negative or 0 period tasks are not scheduled. A 0 or negative timeout means no limit.
+func (s *scheduler) AddWithTimeout(period int, timeout time.Duration, t ...Task) {
+	if period <= 0 {
+		return
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	for _, tt := range t {
+		s.addEntry(period, &entry{task: tt, timeout: max(timeout, 0)})
+	}
+}
+
+// WithPeriodDeadline gives each run of a periodic task without timeout a context deadline derived from its period :
+// the run should be over when the task is next due, period times the tick duration after it started.
+// The deadline is only a hint for TaskCtx tasks, runs exceeding it are not abandoned. Use AddWithTimeout for that.
+// Deadlines apply only while the tick duration is known, that is once the scheduler is started.
+func WithPeriodDeadline() Option {
+	return func(s *scheduler) {
+		s.periodDeadline.Store(true)
+	}
+}
+
+// Context of a run without timeout, with a deadline derived from the period if enabled.
+func (s *scheduler) periodContext(ctx context.Context, e *entry) (context.Context, context.CancelFunc) {
+	s.lockstats.RLock()
+	d := s.duration
+	s.lockstats.RUnlock()
+
+	if !s.periodDeadline.Load() || e.period <= 0 || d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(e.period)*d)
+}
+
+// Run the task t of an entry with a context derived from parent, and with the entry timeout,
+// abandoning it if the timeout expires.
+func (s *scheduler) runTimeout(parent context.Context, e *entry, t Task) error {
+	ctx, cancel := context.WithTimeout(parent, e.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- runTask(ctx, t) }()
+
+	timer := time.NewTimer(e.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+		// the task returned because its deadline expired, it is a timeout too
+	case <-timer.C:
+	}
+	err := fmt.Errorf("%w after %v", ErrTaskTimeout, e.timeout)
+	s.log(slog.LevelWarn, "abandoning task", "task", e.label(), "error", err)
+	s.emit(Event{Kind: EventTaskTimeout, Tick: s.Ticks(), Task: e.task, TaskID: e.id, Err: err, Duration: e.timeout})
+	return err
+}
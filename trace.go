@@ -1,24 +1,60 @@
 package scheduler
 
 import (
+	"context"
+	"encoding/json"
 	"math"
+	"math/rand"
+	"slices"
 	"sync"
 	"time"
 )
 
+// Number of run durations sampled by a TaskTracer to compute percentiles.
+const TraceSamples = 1024
+
+// Default number of runs kept in the history of a TaskTracer.
+const DefaultTraceHistory = 32
+
+// RunRecord describes a run in the history of a TaskTracer.
+type RunRecord struct {
+	Start    time.Time     // time the run started
+	Duration time.Duration // duration of the run
+	Err      error         // error of the run, nil if it succeeded
+}
+
 // TaskTracer is a wrapper around a Task that allows the Task stats to be traced.
-// TaskTracer is itself a Task.
+// TaskTracer is itself a Task. Its runs are timed with the clock of the scheduler it is added to, see WithClock,
+// or with the system clock if it was never added.
 type TaskTracer struct {
-	task  Task         // underlying Task
-	count int64        // nb of calls to Run
-	d     int64        // cumulative duration in nanoseconds
-	d2    int64        // cumulative  duration squared
-	max   int64        // max duration
-	min   int64        // min duration
-	lock  sync.RWMutex // lock for the stats
+	task  Task             // underlying Task
+	count float64          // nb of calls to Run, their weight if the aggregates decay
+	d     float64          // cumulative duration in nanoseconds, weighted as count
+	d2    float64          // cumulative  duration squared, weighted as count
+	max   int64            // max duration
+	min   int64            // min duration
+	last  int64            // duration of the last run
+	lock  sync.RWMutex     // lock for the stats
+	now   func() time.Time // clock timing the runs, the one of the scheduler the tracer was last added to
+
+	samples []int64     // uniform sample of the run durations, at most TraceSamples
+	history []RunRecord // ring buffer of the last runs
+	next    int         // next position in history
+	runs    int         // number of runs in history, up to len(history)
+
+	errors  int64 // number of failed runs
+	streak  int64 // number of consecutive failed runs, up to the last one
+	lastErr error // error of the last failed run
+
+	resetEvery time.Duration // duration of the windows the aggregates are reset after, 0 if never
+	halfLife   time.Duration // half-life of the aggregates, 0 if they do not decay
+	window     time.Time     // start of the current window or half-life
+	prev       bool          // prevMax and prevMin are set
+	prevMax    int64         // max duration of the previous half-life
+	prevMin    int64         // min duration of the previous half-life
 }
 
-var _ Task = &TaskTracer{} // TaskTracer implements Task
+var _ TaskCtx = &TaskTracer{} // TaskTracer implements TaskCtx
 
 // Return a TaskTracer to be registered in the scheduler as a normal Task.
 func Trace(t Task) *TaskTracer {
@@ -30,25 +66,310 @@ func Trace(t Task) *TaskTracer {
 		max:   0,
 		min:   math.MaxInt64,
 		lock:  sync.RWMutex{},
+		now:   time.Now,
+
+		history: make([]RunRecord, DefaultTraceHistory),
 	}
 }
 
+// Return a new tracer of the same task, with the same settings and no statistics.
+func (t *TaskTracer) fresh() *TaskTracer {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	tt := Trace(t.task)
+	tt.history = make([]RunRecord, max(len(t.history), 1))
+	tt.resetEvery, tt.halfLife, tt.now = t.resetEvery, t.halfLife, t.now
+	return tt
+}
+
 func (t *TaskTracer) Run() error {
 
-	start := time.Now()
+	now := t.clock()
+	start := now()
 	err := t.task.Run()
-	dur := int64(time.Now().Sub(start))
+	t.recordRun(start, now().Sub(start), err)
+
+	return err
+}
+
+// RunContext runs the underlying task with ctx, if it is a TaskCtx, or without it otherwise.
+func (t *TaskTracer) RunContext(ctx context.Context) error {
+
+	now := t.clock()
+	start := now()
+	err := runTask(ctx, t.task)
+	t.recordRun(start, now().Sub(start), err)
+
+	return err
+}
+
+// Get the clock timing the runs.
+func (t *TaskTracer) clock() func() time.Time {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.now == nil { // zero value tracer
+		return time.Now
+	}
+	return t.now
+}
+
+// Set the clock timing the runs.
+func (t *TaskTracer) setClock(now func() time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.now = now
+}
+
+// record a run in the history, and its duration
+func (t *TaskTracer) recordRun(start time.Time, d time.Duration, err error) {
+	t.lock.Lock()
+	if len(t.history) == 0 { // zero value tracer
+		t.history = make([]RunRecord, DefaultTraceHistory)
+	}
+	t.history[t.next] = RunRecord{Start: start, Duration: d, Err: err}
+	t.next = (t.next + 1) % len(t.history)
+	t.runs = min(t.runs+1, len(t.history))
+	if err != nil {
+		t.errors++
+		t.streak++
+		t.lastErr = err
+	} else {
+		t.streak = 0
+	}
+	t.measure(start.Add(d), d)
+	t.lock.Unlock()
+}
+
+// ErrorCount is the number of failed runs of the task
+func (t *TaskTracer) ErrorCount() int64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.errors
+}
+
+// ConsecutiveErrors is the number of runs that failed in a row, up to the last one, 0 if it succeeded
+func (t *TaskTracer) ConsecutiveErrors() int64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.streak
+}
+
+// LastError is the error of the last failed run, even if later runs succeeded, nil if none failed
+func (t *TaskTracer) LastError() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
 
+	return t.lastErr
+}
+
+// Get the last runs of the task, oldest first, up to the history size.
+func (t *TaskTracer) History() []RunRecord {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	h := make([]RunRecord, 0, t.runs)
+	for i := t.next - t.runs; i < t.next; i++ {
+		h = append(h, t.history[(i+len(t.history))%len(t.history)])
+	}
+	return h
+}
+
+// Set the number of runs kept in the history, DefaultTraceHistory by default. The current history is cleared.
+func (t *TaskTracer) SetHistory(n int) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
+	t.history = make([]RunRecord, max(n, 1))
+	t.next, t.runs = 0, 0
+}
+
+// record a run duration, ended now on the clock of the tracer
+func (t *TaskTracer) record(d time.Duration) {
+	at := t.clock()()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.measure(at, d)
+}
+
+// unsafe recording of a run duration ended at, in the stats
+func (t *TaskTracer) measure(at time.Time, d time.Duration) {
+	t.age(at)
+	dur := int64(d)
 	t.count += 1
-	t.d += dur
-	t.d2 += dur * dur
+	t.d += float64(dur)
+	t.d2 += float64(dur) * float64(dur)
 	t.max = max(t.max, dur)
 	t.min = min(t.min, dur)
+	t.last = dur
 
-	return err
+	// reservoir sampling : each run has the same probability to be in the sample, or its weight once decayed
+	if len(t.samples) < TraceSamples { // the sample is never larger than count
+		t.samples = append(t.samples, dur)
+	} else if i := rand.Int63n(int64(t.count)); i < TraceSamples {
+		t.samples[i] = dur
+	}
+}
+
+// Reset the duration aggregates every d : count, cumulative, average, standard deviation, min, max and percentiles
+// then describe the runs since the start of the current window of d, instead of the whole life of the tracer.
+// It replaces a decay set with SetHalfLife. 0 or a negative d disables the resets.
+// The windows start with the first run recorded, and are checked when runs are recorded.
+func (t *TaskTracer) SetResetInterval(d time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.resetEvery, t.halfLife = max(d, 0), 0
+}
+
+// Decay the duration aggregates exponentially : the weight of the runs in the count, the cumulative, the average
+// and the standard deviation halves every d, and the min and max cover the current and previous half-lives.
+// Recent runs are also more likely in the sample of the percentiles. It replaces the resets of SetResetInterval.
+// 0 or a negative d disables the decay. Decay is applied when runs are recorded.
+func (t *TaskTracer) SetHalfLife(d time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.halfLife, t.resetEvery = max(d, 0), 0
+}
+
+// unsafe aging of the aggregates, at the time of a run.
+func (t *TaskTracer) age(at time.Time) {
+	if t.window.IsZero() {
+		t.window = at
+		return
+	}
+	elapsed := at.Sub(t.window)
+	switch {
+	case t.resetEvery > 0 && elapsed >= t.resetEvery:
+		t.clear()
+		t.window = at
+	case t.halfLife > 0 && elapsed >= t.halfLife:
+		n := elapsed / t.halfLife
+		t.window = t.window.Add(n * t.halfLife)
+		if n >= 63 {
+			t.clear()
+			return
+		}
+		f := math.Ldexp(1, -int(n)) // the same factor for all, to keep the average
+		t.count, t.d, t.d2 = t.count*f, t.d*f, t.d2*f
+		rand.Shuffle(len(t.samples), func(i, j int) { t.samples[i], t.samples[j] = t.samples[j], t.samples[i] })
+		t.samples = t.samples[:int(float64(len(t.samples))*f)] // a random part of the sample, decayed as the count
+		t.prev = n == 1 && t.max >= t.min                      // the last half-life had runs
+		t.prevMax, t.prevMin = t.max, t.min
+		t.max, t.min = 0, math.MaxInt64
+	}
+}
+
+// unsafe reset of the duration aggregates.
+func (t *TaskTracer) clear() {
+	t.count = 0
+	t.d = 0
+	t.d2 = 0
+	t.max = 0
+	t.min = math.MaxInt64
+	t.samples = nil
+	t.prev = false
+}
+
+// unsafe max duration, including the previous half-life
+func (t *TaskTracer) maxDuration() int64 {
+	if t.prev {
+		return max(t.max, t.prevMax)
+	}
+	return t.max
+}
+
+// unsafe min duration, including the previous half-life
+func (t *TaskTracer) minDuration() int64 {
+	if t.prev {
+		return min(t.min, t.prevMin)
+	}
+	return t.min
+}
+
+// unsafe standard deviation
+func (t *TaskTracer) deviation() time.Duration {
+	if t.count <= 1 {
+		return 0
+	}
+	avg := t.d / t.count
+	return time.Duration(math.Sqrt(max(t.d2/t.count-avg*avg, 0))) // rounding errors
+}
+
+// Percentile is the duration below which p percent of the runs of the task fall, p being between 0 and 100,
+// such as 99 for the 99th percentile. It is estimated from a uniform sample of TraceSamples runs.
+func (t *TaskTracer) Percentile(p float64) time.Duration {
+	t.lock.RLock()
+	sorted := slices.Clone(t.samples)
+	t.lock.RUnlock()
+
+	slices.Sort(sorted)
+	return percentile(sorted, p)
+}
+
+// Percentile p of sorted durations, 0 if there are none.
+func percentile(sorted []int64, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(min(max(p, 0), 100)/100*float64(len(sorted)))) - 1 // nearest rank
+	return time.Duration(sorted[max(i, 0)])
+}
+
+// TraceSnapshot are the statistics of a TaskTracer, read at once.
+type TraceSnapshot struct {
+	Count             int64         `json:"count"`               // number of runs
+	Errors            int64         `json:"errors"`              // number of failed runs
+	ConsecutiveErrors int64         `json:"consecutiveErrors"`   // number of runs failed in a row, up to the last one
+	LastError         string        `json:"lastError,omitempty"` // error of the last failed run, if any
+	Total             time.Duration `json:"total"`               // cumulative duration
+	Average           time.Duration `json:"average"`             // average duration
+	StandardDeviation time.Duration `json:"standardDeviation"`   // standard deviation of the durations
+	Min               time.Duration `json:"min"`                 // minimum duration
+	Max               time.Duration `json:"max"`                 // maximum duration
+	Last              time.Duration `json:"last"`                // duration of the last run
+	P50               time.Duration `json:"p50"`                 // median duration
+	P90               time.Duration `json:"p90"`                 // 90th percentile of the durations
+	P99               time.Duration `json:"p99"`                 // 99th percentile of the durations
+}
+
+// Snapshot of the statistics, consistent with each other, instead of calling the getters one by one.
+// Durations are in nanoseconds when marshalled to JSON.
+func (t *TaskTracer) Snapshot() TraceSnapshot {
+	t.lock.RLock()
+	sn := TraceSnapshot{
+		Count:             int64(math.Round(t.count)),
+		Errors:            t.errors,
+		ConsecutiveErrors: t.streak,
+		Total:             time.Duration(t.d),
+		Max:               time.Duration(t.maxDuration()),
+		Last:              time.Duration(t.last),
+	}
+	if t.lastErr != nil {
+		sn.LastError = t.lastErr.Error()
+	}
+	if t.count > 0 {
+		sn.Min = time.Duration(t.minDuration())
+		sn.Average = time.Duration(t.d / t.count)
+	}
+	sn.StandardDeviation = t.deviation()
+	sorted := slices.Clone(t.samples)
+	t.lock.RUnlock()
+
+	slices.Sort(sorted)
+	sn.P50, sn.P90, sn.P99 = percentile(sorted, 50), percentile(sorted, 90), percentile(sorted, 99)
+	return sn
+}
+
+// MarshalJSON marshals the Snapshot of the tracer.
+func (t *TaskTracer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Snapshot())
 }
 
 // Count is the nb of calls to Run
@@ -56,7 +377,7 @@ func (t *TaskTracer) Count() int64 {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
-	return t.count
+	return int64(math.Round(t.count))
 }
 
 // CumulativeDuration is the cumulative duration of the task
@@ -75,7 +396,15 @@ func (t *TaskTracer) AverageDuration() time.Duration {
 	if t.count == 0 {
 		return 0
 	}
-	return time.Duration(float64(t.d) / float64(t.count))
+	return time.Duration(t.d / t.count)
+}
+
+// LastDuration is the duration of the last run of the task
+func (t *TaskTracer) LastDuration() time.Duration {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return time.Duration(t.last)
 }
 
 // MaxDuration is the maximum duration of the task
@@ -83,7 +412,7 @@ func (t *TaskTracer) MaxDuration() time.Duration {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
-	return time.Duration(t.max)
+	return time.Duration(t.maxDuration())
 }
 
 // MinDuration is the minimum duration of the task
@@ -94,7 +423,7 @@ func (t *TaskTracer) MinDuration() time.Duration {
 		return 0
 	}
 
-	return time.Duration(t.min)
+	return time.Duration(t.minDuration())
 }
 
 // StandardDeviationDuration is the standard deviation of the duration of the task
@@ -102,20 +431,17 @@ func (t *TaskTracer) StandardDeviationDuration() time.Duration {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
-	if t.count <= 1 {
-		return 0
-	}
-	return time.Duration(math.Sqrt(float64(t.d2)/float64(t.count) - float64(t.d)/float64(t.count)*float64(t.d)/float64(t.count)))
+	return t.deviation()
 }
 
-// Reset stats
+// Reset stats. The reset interval and half-life are kept.
 func (t *TaskTracer) Reset() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	t.count = 0
-	t.d = 0
-	t.d2 = 0
-	t.max = 0
-	t.min = math.MaxInt64
+	t.clear()
+	t.last = 0
+	t.window = time.Time{}
+	t.next, t.runs = 0, 0
+	t.errors, t.streak, t.lastErr = 0, 0, nil
 }
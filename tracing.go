@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// WithTracing traces every task added to the scheduler, as if it was wrapped in a TaskTracer.
+// The tasks themselves are scheduled, so they are still removed or looked up as added.
+// Their statistics are retrieved with TaskStats and AllStats.
+func WithTracing() Option {
+	return func(s *scheduler) {
+		s.tracing = true
+	}
+}
+
+// WithTraceHalfLife decays the statistics of the tracers created by WithTracing with a half-life of d,
+// see TaskTracer.SetHalfLife, so that they describe the recent runs rather than the whole life of the tasks.
+func WithTraceHalfLife(d time.Duration) Option {
+	return func(s *scheduler) {
+		s.traceHalfLife = max(d, 0)
+	}
+}
+
+// unsafe tracing of a new entry, if the scheduler traces all tasks and the task is not already traced.
+// Tracers then time the runs with the clock of the scheduler.
+func (s *scheduler) traced(e *entry) {
+	tr, ok := e.task.(*TaskTracer)
+	if s.tracing && !ok && e.trace == nil {
+		e.trace = Trace(e.task)
+		e.trace.SetHalfLife(s.traceHalfLife)
+	}
+	if ok {
+		tr.setClock(s.now)
+	}
+	if e.trace != nil {
+		e.trace.setClock(s.now)
+	}
+}
+
+// Tracer of the entry, set by WithTracing or wrapping its task, nil if the entry is not traced.
+func (e *entry) tracer() *TaskTracer {
+	if e.trace != nil {
+		return e.trace
+	}
+	tr, _ := e.task.(*TaskTracer)
+	return tr
+}
+
+// Get the stats of a traced task, false if it is not scheduled or not traced.
+func (s *scheduler) TaskStats(t Task) (TaskStat, bool) {
+	var st TaskStat
+	found := false
+	s.locktasks.Lock()
+	s.each(func(e *entry) {
+		if tr := e.tracer(); !found && tr != nil && (sameTask(e.task, t) || sameTask(tr.task, t)) {
+			st, found = tr.stat(e.task, e.period), true
+		}
+	})
+	s.locktasks.Unlock()
+	return st, found
+}
+
+// Get the stats of all the traced tasks, by increasing period. One-shot and cron tasks have a 0 period.
+func (s *scheduler) AllStats() []TaskStat {
+	var stats []TaskStat
+	s.locktasks.Lock()
+	s.each(func(e *entry) {
+		if tr := e.tracer(); tr != nil {
+			stats = append(stats, tr.stat(e.task, e.period))
+		}
+	})
+	s.locktasks.Unlock()
+
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Period < stats[j].Period })
+	return stats
+}
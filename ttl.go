@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTaskExpired is the reason of the removal of a task added with AddWithTTL, once its lifetime elapsed.
+var ErrTaskExpired = errors.New("task expired")
+
+// Add a task sheduled to run every 'period' ticks, removed automatically once ttl elapsed, such as a temporary
+// monitoring probe or a trial feature. The lifetime is measured with the scheduler clock, and checked at each tick.
+// Negative or 0 period or ttl tasks are not scheduled.
+func (s *scheduler) AddWithTTL(period int, ttl time.Duration, t Task) *TaskHandle {
+	if period <= 0 || ttl <= 0 {
+		return nil
+	}
+	s.locktasks.Lock()
+	defer s.locktasks.Unlock()
+
+	e := &entry{task: t, expires: s.now().Add(ttl)}
+	s.addEntry(period, e)
+	return &TaskHandle{s: s, e: e}
+}
+
+// unsafe removal of the entries expired at now, returning them.
+func (s *scheduler) expire(now time.Time) []*entry {
+	var expired []*entry
+	kept := s.expiring[:0]
+	for _, e := range s.expiring {
+		if !now.Before(e.expires) {
+			if s.unlink(e) {
+				s.changed(ChangeRemoved, e)
+				expired = append(expired, e)
+			}
+			continue
+		}
+		if s.index(e) >= 0 { // forget the entries removed otherwise
+			kept = append(kept, e)
+		}
+	}
+	s.expiring = kept
+	return expired
+}
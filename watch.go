@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+)
+
+// ChangeKind identifies the kind of a ScheduleChange.
+type ChangeKind int
+
+const (
+	// A registration was added.
+	ChangeAdded ChangeKind = iota + 1
+	// A registration was removed, explicitly, by its error policy, or because it ran once and is over.
+	ChangeRemoved
+	// The period of a registration changed, see TaskHandle.Reschedule and Throttle.
+	ChangeRescheduled
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "Added"
+	case ChangeRemoved:
+		return "Removed"
+	case ChangeRescheduled:
+		return "Rescheduled"
+	default:
+		return "ChangeKind(?)"
+	}
+}
+
+// ScheduleChange describes a change of the schedule.
+type ScheduleChange struct {
+	Kind ChangeKind // kind of change
+	ID   TaskID     // registration changed
+	Tick int        // tick count when the change happened
+	Spec Spec       // spec of the registration after the change, or before its removal
+}
+
+// A watcher queues the changes for a Watch channel, so that notifying never blocks.
+type watcher struct {
+	lock    sync.Mutex
+	pending []ScheduleChange
+	wake    chan struct{} // signals pending changes
+}
+
+// Watch the changes of the schedule until ctx is done, when the channel is closed.
+// Changes are delivered in the order they happened, none is dropped : they are queued while the receiver is slow.
+// The current schedule is not sent, a mirror should start from Status or the handles, then apply the changes.
+func (s *scheduler) Watch(ctx context.Context) <-chan ScheduleChange {
+	w := &watcher{wake: make(chan struct{}, 1)}
+	ch := make(chan ScheduleChange)
+	s.lockwatch.Lock()
+	s.watchers = append(s.watchers, w)
+	s.lockwatch.Unlock()
+
+	go func() {
+		defer close(ch)
+		defer s.unwatch(w)
+		for {
+			w.lock.Lock()
+			changes := w.pending
+			w.pending = nil
+			w.lock.Unlock()
+			for _, c := range changes {
+				select {
+				case ch <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-w.wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Remove a watcher.
+func (s *scheduler) unwatch(w *watcher) {
+	s.lockwatch.Lock()
+	defer s.lockwatch.Unlock()
+
+	for i, ww := range s.watchers {
+		if ww == w {
+			s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+// unsafe notification of a change of e to the watchers.
+func (s *scheduler) changed(kind ChangeKind, e *entry) {
+	s.lockwatch.Lock()
+	defer s.lockwatch.Unlock()
+
+	if len(s.watchers) == 0 {
+		return
+	}
+	c := ScheduleChange{Kind: kind, ID: e.id, Tick: s.ticks + s.inflight, Spec: e.spec()}
+	for _, w := range s.watchers {
+		w.lock.Lock()
+		w.pending = append(w.pending, c)
+		w.lock.Unlock()
+		select {
+		case w.wake <- struct{}{}:
+		default: // already signalled
+		}
+	}
+}